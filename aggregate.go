@@ -0,0 +1,226 @@
+package elorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Aggregate functions for EntityDef.Aggregate
+const (
+	AggregateCount = 100
+	AggregateSum   = 200
+	AggregateMin   = 300
+	AggregateMax   = 400
+	AggregateAvg   = 500
+)
+
+var aggregateFuncsMap = map[int]string{
+	AggregateCount: "count",
+	AggregateSum:   "sum",
+	AggregateMin:   "min",
+	AggregateMax:   "max",
+	AggregateAvg:   "avg",
+}
+
+// Aggregate describes aggregate function over field of entity. Results are returned in AggregateRow.Values by Name:
+// int64 for count, float64 for sum and avg, value of field type for min and max (string, int64, bool, float64,
+// time.Time, ref string).
+type Aggregate struct {
+	Func  int
+	Field *FieldDef // nil for count(*)
+	Name  string    // name of result, default is function name + field name, e.g. "SumPrice" or "Count"
+}
+
+func (T *Aggregate) resultName() string {
+	if T.Name != "" {
+		return T.Name
+	}
+	fn := aggregateFuncsMap[T.Func]
+	res := strings.ToUpper(fn[:1]) + fn[1:]
+	if T.Field != nil {
+		res += T.Field.Name
+	}
+	return res
+}
+
+// AggregateRow is a row of aggregate query result.
+type AggregateRow struct {
+	Groups map[string]IFieldValue // values of group by fields by field name
+	Values map[string]any         // results of aggregates by Aggregate.Name
+}
+
+// Float returns aggregate result as float64 (zero for missing or non-numeric results).
+func (T *AggregateRow) Float(name string) float64 {
+	switch v := T.Values[name].(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
+
+// Int returns aggregate result as int64 (zero for missing or non-numeric results).
+func (T *AggregateRow) Int(name string) int64 {
+	switch v := T.Values[name].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Aggregate calculates aggregates over entities grouped by fields. Filters are the same as for SelectEntities
+// (including paths and soft delete auto-filter), rows are sorted by group fields.
+func (T *EntityDef) Aggregate(filters []*Filter, groupBy []*FieldDef, aggregates []*Aggregate) ([]*AggregateRow, error) {
+	return T.AggregateContext(context.Background(), filters, groupBy, aggregates)
+}
+
+// AggregateContext is like Aggregate, but it reads database inside the transaction carried by ctx (see Factory.BeginTranContext), if any.
+func (T *EntityDef) AggregateContext(ctx context.Context, filters []*Filter, groupBy []*FieldDef, aggregates []*Aggregate) ([]*AggregateRow, error) {
+	if len(groupBy) == 0 && len(aggregates) == 0 {
+		return nil, fmt.Errorf("EntityDef.Aggregate: no group fields and aggregates")
+	}
+	filters = slices.DeleteFunc(slices.Clone(filters), func(f *Filter) bool { return f == nil })
+	args := make([]any, 0)
+	joins := newSqlJoins(T, filters, nil)
+	where, err := T.renderWhere(filters, &args, joins)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.Aggregate: %w", err)
+	}
+
+	columns := make([]string, 0, len(groupBy)+len(aggregates))
+	groupColumns := make([]string, 0, len(groupBy))
+	for _, fd := range groupBy {
+		if !slices.Contains(T.FieldDefs, fd) {
+			return nil, fmt.Errorf("EntityDef.Aggregate: group field is not field of %s", T.ObjectName)
+		}
+		coln, err := joins.column(nil, fd)
+		if err != nil {
+			return nil, fmt.Errorf("EntityDef.Aggregate: %w", err)
+		}
+		groupColumns = append(groupColumns, coln)
+	}
+	columns = append(columns, groupColumns...)
+	names := make(map[string]bool, len(aggregates))
+	for _, a := range aggregates {
+		fn, ok := aggregateFuncsMap[a.Func]
+		if !ok {
+			return nil, fmt.Errorf("EntityDef.Aggregate: unknown aggregate function %d", a.Func)
+		}
+		if names[a.resultName()] {
+			return nil, fmt.Errorf("EntityDef.Aggregate: duplicate aggregate name %s", a.resultName())
+		}
+		names[a.resultName()] = true
+		if a.Field == nil {
+			if a.Func != AggregateCount {
+				return nil, fmt.Errorf("EntityDef.Aggregate: field is required for %s", fn)
+			}
+			columns = append(columns, "count(*)")
+			continue
+		}
+		if !slices.Contains(T.FieldDefs, a.Field) {
+			return nil, fmt.Errorf("EntityDef.Aggregate: field %s is not field of %s", a.Field.Name, T.ObjectName)
+		}
+		if (a.Func == AggregateSum || a.Func == AggregateAvg) && a.Field.Type != FieldDefTypeInt && a.Field.Type != FieldDefTypeNumeric {
+			return nil, fmt.Errorf("EntityDef.Aggregate: %s isn't supported for non-numeric field %s", fn, a.Field.Name)
+		}
+		coln, err := joins.column(nil, a.Field)
+		if err != nil {
+			return nil, fmt.Errorf("EntityDef.Aggregate: %w", err)
+		}
+		columns = append(columns, fmt.Sprintf("%s(%s)", fn, coln))
+	}
+
+	tablename, err := T.SqlTableName()
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.Aggregate: %w", err)
+	}
+	query := fmt.Sprintf("select %s from %s%s", strings.Join(columns, ", "), joins.from(tablename), where)
+	if len(groupColumns) > 0 {
+		query += fmt.Sprintf(" group by %s order by %s", strings.Join(groupColumns, ", "), strings.Join(groupColumns, ", "))
+	}
+
+	rows, err := T.Factory.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.Aggregate: failed to execute query '%s': %w", query, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	result := make([]*AggregateRow, 0)
+	for rows.Next() {
+		row := &AggregateRow{Groups: make(map[string]IFieldValue, len(groupBy)), Values: make(map[string]any, len(aggregates))}
+		dest := make([]any, 0, len(columns))
+		for _, fd := range groupBy {
+			fv, err := fd.CreateFieldValue(nil)
+			if err != nil {
+				return nil, fmt.Errorf("EntityDef.Aggregate: %w", err)
+			}
+			row.Groups[fd.Name] = fv
+			dest = append(dest, fv)
+		}
+		raw := make([]any, len(aggregates))
+		for i, a := range aggregates {
+			switch a.Func {
+			case AggregateCount:
+				raw[i] = new(int64)
+			case AggregateSum, AggregateAvg:
+				raw[i] = new(sql.NullFloat64)
+			default:
+				raw[i] = new(any)
+			}
+			dest = append(dest, raw[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("EntityDef.Aggregate: failed to scan row: %w", err)
+		}
+		for i, a := range aggregates {
+			switch v := raw[i].(type) {
+			case *int64:
+				row.Values[a.resultName()] = *v
+			case *sql.NullFloat64:
+				row.Values[a.resultName()] = v.Float64
+			case *any:
+				fv, err := a.Field.CreateFieldValue(nil)
+				if err != nil {
+					return nil, fmt.Errorf("EntityDef.Aggregate: %w", err)
+				}
+				if s, ok := (*v).(string); ok && a.Field.Type != FieldDefTypeString && a.Field.Type != FieldDefTypeRef {
+					*v = []byte(s) // e.g. min/max over expression is returned as text by SQLite
+				}
+				if err = fv.Scan(*v); err != nil {
+					return nil, fmt.Errorf("EntityDef.Aggregate: failed to scan %s: %w", a.resultName(), err)
+				}
+				row.Values[a.resultName()] = fieldValueTyped(fv)
+			}
+		}
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("EntityDef.Aggregate: rows error: %w", err)
+	}
+	return result, nil
+}
+
+// fieldValueTyped returns value of field as Go value of field type, refs are returned as strings
+func fieldValueTyped(fv IFieldValue) any {
+	switch vt := fv.(type) {
+	case *FieldValueString:
+		return vt.Get()
+	case *FieldValueInt:
+		return vt.Get()
+	case *FieldValueBool:
+		return vt.Get()
+	case *FieldValueNumeric:
+		return vt.Get()
+	case *FieldValueDateTime:
+		return vt.Get()
+	default:
+		return fv.AsString()
+	}
+}
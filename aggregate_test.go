@@ -0,0 +1,106 @@
+package elorm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntityDef_Aggregate(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "aggregate.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	shopDef, _ := factory.CreateEntityDef("AgShop", "AgShops")
+	shopCaption, _ := shopDef.AddStringFieldDef("Caption", 50)
+	goodDef, _ := factory.CreateEntityDef("AgGood", "AgGoods")
+	goodDef.UseSoftDelete = true
+	goodShop, _ := goodDef.AddRefFieldDef("OwnerShop", shopDef)
+	goodPrice, _ := goodDef.AddNumericFieldDef("Price", 10, 2)
+	goodQty, _ := goodDef.AddIntFieldDef("Qty")
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	shop := func(caption string) *Entity {
+		e, _ := factory.CreateEntity(shopDef)
+		e.Values["Caption"].(*FieldValueString).Set(caption)
+		if err := e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return e
+	}
+	good := func(shop *Entity, price float64, qty int64, deleted bool) {
+		e, _ := factory.CreateEntity(goodDef)
+		_ = e.Values["OwnerShop"].(*FieldValueRef).Set(shop)
+		e.Values["Price"].(*FieldValueNumeric).Set(price)
+		e.Values["Qty"].(*FieldValueInt).Set(qty)
+		e.SetIsDeleted(deleted)
+		if err := e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	alpha, beta := shop("Alpha"), shop("Beta")
+	good(alpha, 10.5, 1, false)
+	good(alpha, 20, 2, false)
+	good(alpha, 1000, 5, true)
+	good(beta, 7.25, 4, false)
+
+	rows, err := goodDef.Aggregate(nil, []*FieldDef{goodShop}, []*Aggregate{
+		{Func: AggregateCount},
+		{Func: AggregateSum, Field: goodPrice},
+		{Func: AggregateAvg, Field: goodQty},
+		{Func: AggregateMax, Field: goodPrice, Name: "Top"},
+		{Func: AggregateMin, Field: goodQty},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Aggregate() returned %d rows, want 2", len(rows))
+	}
+	byShop := make(map[string]*AggregateRow)
+	for _, r := range rows {
+		byShop[r.Groups["OwnerShop"].AsString()] = r
+	}
+	a := byShop[alpha.RefString()]
+	if a == nil || a.Int("Count") != 2 || a.Float("SumPrice") != 30.5 || a.Float("AvgQty") != 1.5 {
+		t.Errorf("Aggregate() alpha row = %+v", a)
+	}
+	if top, ok := a.Values["Top"].(float64); !ok || top != 20 {
+		t.Errorf("Aggregate() alpha Top = %#v, want 20", a.Values["Top"])
+	}
+	if minQty, ok := a.Values["MinQty"].(int64); !ok || minQty != 1 {
+		t.Errorf("Aggregate() alpha MinQty = %#v, want 1", a.Values["MinQty"])
+	}
+	if b := byShop[beta.RefString()]; b == nil || b.Int("Count") != 1 || b.Float("SumPrice") != 7.25 {
+		t.Errorf("Aggregate() beta row = %+v", b)
+	}
+
+	// totals with filter through ref and deleted rows
+	rows, err = goodDef.Aggregate([]*Filter{AddFilterEQ(shopCaption, "Alpha").Through(goodShop), AddFilterEQ(goodDef.IsDeletedField, true)},
+		nil, []*Aggregate{{Func: AggregateSum, Field: goodQty}})
+	if err != nil {
+		t.Fatalf("Aggregate() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].Int("SumQty") != 5 {
+		t.Errorf("Aggregate() deleted totals = %+v", rows)
+	}
+
+	// empty set
+	rows, err = goodDef.Aggregate([]*Filter{AddFilterGT(goodPrice, 5000.0)}, nil, []*Aggregate{{Func: AggregateCount}, {Func: AggregateSum, Field: goodPrice}})
+	if err != nil {
+		t.Fatalf("Aggregate() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].Int("Count") != 0 || rows[0].Float("SumPrice") != 0 {
+		t.Errorf("Aggregate() empty totals = %+v", rows)
+	}
+
+	if _, err = goodDef.Aggregate(nil, nil, []*Aggregate{{Func: AggregateSum, Field: shopCaption}}); err == nil {
+		t.Errorf("Aggregate() over field of other entity should fail")
+	}
+	if _, err = goodDef.Aggregate(nil, nil, []*Aggregate{{Func: AggregateSum}}); err == nil {
+		t.Errorf("Aggregate() sum without field should fail")
+	}
+}
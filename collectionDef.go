@@ -0,0 +1,114 @@
+package elorm
+
+import (
+	"context"
+	"fmt"
+)
+
+// CollectionDef describes one-to-many inverse navigation collection: entities of ItemDef which reference the owner entity by Field.
+// E.g. "Lines via OrderLine.Order" collection of Order.
+type CollectionDef struct {
+	Name    string
+	Owner   *EntityDef  // entity definition the collection belongs to
+	ItemDef *EntityDef  // entity definition of collection items
+	Field   *FieldDef   // ref field of ItemDef pointing to Owner
+	Sorts   []*SortItem // items order, by Ref if empty
+}
+
+// AddCollectionDef adds inverse navigation collection of itemDef entities referencing this entity by field.
+func (T *EntityDef) AddCollectionDef(name string, itemDef *EntityDef, field *FieldDef) (*CollectionDef, error) {
+	if err := T.checkName(name); err != nil {
+		return nil, fmt.Errorf("EntityDef.AddCollectionDef: %w", err)
+	}
+	if itemDef == nil || field == nil {
+		return nil, fmt.Errorf("EntityDef.AddCollectionDef: itemDef and field are required for collection %s", name)
+	}
+	if field.Type != FieldDefTypeRef || field.Name == RefFieldName || field.EntityDef != T {
+		return nil, fmt.Errorf("EntityDef.AddCollectionDef: field %s should be ref to %s", field.Name, T.ObjectName)
+	}
+	if itemDef.FieldDefByName(field.Name) != field {
+		return nil, fmt.Errorf("EntityDef.AddCollectionDef: field %s doesn't belong to %s", field.Name, itemDef.ObjectName)
+	}
+	nr := &CollectionDef{Name: name, Owner: T, ItemDef: itemDef, Field: field}
+	T.CollectionDefs = append(T.CollectionDefs, nr)
+	return nr, nil
+}
+
+// CollectionDefByName returns the collection definition with the specified name.
+func (T *EntityDef) CollectionDefByName(name string) *CollectionDef {
+	for _, v := range T.CollectionDefs {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Collection returns items of inverse navigation collection. Items are loaded on first access and cached in the entity,
+// until the entity or one of items is saved or deleted (see ResetCollections).
+func (T *Entity) Collection(ctx context.Context, name string) ([]*Entity, error) {
+	cd := T.entityDef.CollectionDefByName(name)
+	if cd == nil {
+		return nil, fmt.Errorf("Entity.Collection: collection %s is not defined for %s", name, T.entityDef.ObjectName)
+	}
+
+	T.collectionsLock.Lock()
+	defer T.collectionsLock.Unlock()
+
+	if items, ok := T.collections[cd]; ok {
+		return items, nil
+	}
+	if T.isNew {
+		return []*Entity{}, nil
+	}
+	items, _, err := cd.ItemDef.SelectEntitiesContext(ctx, []*Filter{AddFilterEQ(cd.Field, T.RefString())}, cd.Sorts, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Entity.Collection: failed to load collection %s: %w", name, err)
+	}
+	if T.collections == nil {
+		T.collections = make(map[*CollectionDef][]*Entity)
+	}
+	T.collections[cd] = items
+	return items, nil
+}
+
+// ResetCollections drops loaded collections, they will be reloaded on next access.
+func (T *Entity) ResetCollections() {
+	T.collectionsLock.Lock()
+	defer T.collectionsLock.Unlock()
+
+	T.collections = nil
+}
+
+// resetOwnerCollections drops loaded collections of cached owners which contain (or contained before changes) entity as item.
+func (T *Entity) resetOwnerCollections() {
+	for _, v := range T.Values {
+		fv, ok := v.(*FieldValueRef)
+		if !ok || fv.def.Name == RefFieldName || len(fv.def.EntityDef.CollectionDefs) == 0 {
+			continue
+		}
+		fv.lock.Lock()
+		refs := []string{fv.v, fv.old}
+		fv.lock.Unlock()
+		for _, ref := range refs {
+			if ref == "" {
+				continue
+			}
+			if owner, ok := T.Factory.loadedEntities.Peek(ref); ok {
+				owner.ResetCollections()
+			}
+		}
+	}
+}
+
+// hasCollectionsOf returns true when any entity definition has collection of itemDef entities
+func (T *Factory) hasCollectionsOf(itemDef *EntityDef) bool {
+	for _, ed := range T.EntityDefs {
+		for _, cd := range ed.CollectionDefs {
+			if cd.ItemDef == itemDef {
+				return true
+			}
+		}
+	}
+	return false
+}
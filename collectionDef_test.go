@@ -0,0 +1,94 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntity_Collection(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "collections.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	orderDef, _ := factory.CreateEntityDef("ColOrder", "ColOrders")
+	lineDef, _ := factory.CreateEntityDef("ColLine", "ColLines")
+	lineOrder, _ := lineDef.AddRefFieldDef("Doc", orderDef)
+	lineNo, _ := lineDef.AddIntFieldDef("LineNo")
+	otherDef, _ := factory.CreateEntityDef("ColOther", "ColOthers")
+
+	if _, err = orderDef.AddCollectionDef("Lines", otherDef, lineOrder); err == nil {
+		t.Errorf("AddCollectionDef() with field of another entity should fail")
+	}
+	if _, err = orderDef.AddCollectionDef("Lines", lineDef, lineNo); err == nil {
+		t.Errorf("AddCollectionDef() with non-ref field should fail")
+	}
+	lines, err := orderDef.AddCollectionDef("Lines", lineDef, lineOrder)
+	if err != nil {
+		t.Fatalf("AddCollectionDef() error = %v", err)
+	}
+	lines.Sorts = []*SortItem{{Field: lineNo, Asc: false}}
+	if _, err = orderDef.AddStringFieldDef("Lines", 10); err == nil {
+		t.Errorf("AddStringFieldDef() with collection name should fail")
+	}
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	order, _ := factory.CreateEntity(orderDef)
+	if items, err := order.Collection(ctx, "Lines"); err != nil || len(items) != 0 {
+		t.Errorf("Collection() of new entity = %v, %v", items, err)
+	}
+	if err = order.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	addLine := func(no int64) *Entity {
+		line, _ := factory.CreateEntity(lineDef)
+		_ = line.Values["Doc"].(*FieldValueRef).Set(order)
+		line.Values["LineNo"].(*FieldValueInt).Set(no)
+		if err := line.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return line
+	}
+	addLine(1)
+	addLine(2)
+
+	items, err := order.Collection(ctx, "Lines")
+	if err != nil {
+		t.Fatalf("Collection() error = %v", err)
+	}
+	if len(items) != 2 || items[0].Values["LineNo"].(*FieldValueInt).Get() != 2 {
+		t.Fatalf("Collection() = %v, want 2 lines sorted by LineNo desc", items)
+	}
+	if _, err = order.Collection(ctx, "Unknown"); err == nil {
+		t.Errorf("Collection() with unknown name should fail")
+	}
+
+	// saving and deleting items resets cached collection of owner
+	line3 := addLine(3)
+	if items, _ = order.Collection(ctx, "Lines"); len(items) != 3 {
+		t.Errorf("Collection() after item save has %d items, want 3", len(items))
+	}
+	if err = factory.DeleteEntity(ctx, line3.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	if items, _ = order.Collection(ctx, "Lines"); len(items) != 2 {
+		t.Errorf("Collection() after item delete has %d items, want 2", len(items))
+	}
+
+	orderDef.AutoExpandCollectionsForJSON = map[*CollectionDef]bool{lines: true}
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var parsed map[string]any
+	if err = json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if arr, ok := parsed["Lines"].([]any); !ok || len(arr) != 2 {
+		t.Errorf("MarshalJSON() Lines = %v, want array of 2 items", parsed["Lines"])
+	}
+}
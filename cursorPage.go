@@ -0,0 +1,209 @@
+package elorm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CursorPage is a page of entities selected by SelectEntitiesPage.
+type CursorPage struct {
+	Data       []*Entity
+	NextCursor string // cursor of the next page, empty on the last page
+	PrevCursor string // cursor of the previous page, empty on the first page
+	Total      int    // number of entities matching filters, -1 when count wasn't requested
+}
+
+// pageCursor is the content of opaque cursor: sort key of the boundary row and direction
+type pageCursor struct {
+	Sorts  string `json:"s"` // sort fields, cursor isn't valid for other sorts
+	Values []any  `json:"v"`
+	Back   bool   `json:"b,omitempty"`
+}
+
+// cursorSorts returns sorts with Ref added as the last key, so sort key is unique
+func (T *EntityDef) cursorSorts(sorts []*SortItem) []*SortItem {
+	res := slices.DeleteFunc(slices.Clone(sorts), func(s *SortItem) bool { return s == nil || s.Field == nil })
+	if !slices.ContainsFunc(res, func(s *SortItem) bool { return s.Field == T.RefField && len(s.Path) == 0 }) {
+		res = append(res, &SortItem{Field: T.RefField, Asc: true})
+	}
+	return res
+}
+
+func cursorSortsKey(sorts []*SortItem) string {
+	keys := make([]string, 0, len(sorts))
+	for _, s := range sorts {
+		key := s.Field.Name
+		for i := len(s.Path) - 1; i >= 0; i-- {
+			key = s.Path[i].Name + "." + key
+		}
+		if !s.Asc {
+			key += " desc"
+		}
+		keys = append(keys, key)
+	}
+	return strings.Join(keys, ",")
+}
+
+// encodeCursor builds cursor from sort key values of entity
+func (T *EntityDef) encodeCursor(e *Entity, sorts []*SortItem, back bool) (string, error) {
+	c := pageCursor{Sorts: cursorSortsKey(sorts), Values: make([]any, 0, len(sorts)), Back: back}
+	for _, s := range sorts {
+		owner := e
+		for _, p := range s.Path {
+			if owner == nil {
+				break
+			}
+			ref := owner.Values[p.Name].AsString()
+			if ref == "" {
+				owner = nil
+				break
+			}
+			next, err := T.Factory.LoadEntity(ref)
+			if err != nil {
+				return "", fmt.Errorf("EntityDef.encodeCursor: %w", err)
+			}
+			owner = next
+		}
+		if owner == nil {
+			return "", fmt.Errorf("EntityDef.encodeCursor: empty ref in sort path to %s", s.Field.Name)
+		}
+		c.Values = append(c.Values, fieldValueTyped(owner.Values[s.Field.Name]))
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("EntityDef.encodeCursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses cursor and converts its values to types of sort fields
+func decodeCursor(cursor string, sorts []*SortItem) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	c := &pageCursor{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err = dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if c.Sorts != cursorSortsKey(sorts) || len(c.Values) != len(sorts) {
+		return nil, fmt.Errorf("invalid cursor: it was created for other sorting")
+	}
+	for i, s := range sorts {
+		v, ok := c.Values[i], false
+		switch s.Field.Type {
+		case FieldDefTypeString, FieldDefTypeRef:
+			c.Values[i], ok = v.(string)
+		case FieldDefTypeBool:
+			c.Values[i], ok = v.(bool)
+		case FieldDefTypeInt:
+			if n, isNum := v.(json.Number); isNum {
+				c.Values[i], err = n.Int64()
+				ok = err == nil
+			}
+		case FieldDefTypeNumeric:
+			if n, isNum := v.(json.Number); isNum {
+				c.Values[i], err = n.Float64()
+				ok = err == nil
+			}
+		case FieldDefTypeDateTime:
+			if str, isStr := v.(string); isStr {
+				c.Values[i], err = time.Parse(time.RFC3339Nano, str)
+				ok = err == nil
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor: wrong value for %s", s.Field.Name)
+		}
+	}
+	return c, nil
+}
+
+// keysetFilter renders condition for rows after (before when back is true) the cursor:
+// (k1 > v1) or (k1 = v1 and k2 > v2) or ...
+func keysetFilter(sorts []*SortItem, values []any, back bool) *Filter {
+	or := AddOrGroup()
+	for i, s := range sorts {
+		and := AddAndGroup()
+		for j := range i {
+			and.Childs = append(and.Childs, AddFilterEQ(sorts[j].Field, values[j]).Through(sorts[j].Path...))
+		}
+		if s.Asc != back {
+			and.Childs = append(and.Childs, AddFilterGT(s.Field, values[i]).Through(s.Path...))
+		} else {
+			and.Childs = append(and.Childs, AddFilterLT(s.Field, values[i]).Through(s.Path...))
+		}
+		or.Childs = append(or.Childs, and)
+	}
+	return or
+}
+
+// SelectEntitiesPage selects page of entities by cursor (keyset pagination). Empty cursor means the first page,
+// NextCursor and PrevCursor of result are used to get adjacent pages. Unlike SelectEntities with page number,
+// rows inserted meanwhile don't shift pages. Ref is added to sorts as the last key, so sorting is stable.
+// Sort fields should have no NULL values (e.g. joined by empty refs). Total is counted only when count is true.
+func (T *EntityDef) SelectEntitiesPage(ctx context.Context, filters []*Filter, sorts []*SortItem, cursor string, pageSize int, count bool) (*CursorPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("EntityDef.SelectEntitiesPage: invalid pageSize")
+	}
+	filters = slices.DeleteFunc(slices.Clone(filters), func(f *Filter) bool { return f == nil })
+	sorts = T.cursorSorts(sorts)
+
+	back := false
+	querySorts := sorts
+	queryFilters := filters
+	if cursor != "" {
+		c, err := decodeCursor(cursor, sorts)
+		if err != nil {
+			return nil, fmt.Errorf("EntityDef.SelectEntitiesPage: %w", err)
+		}
+		back = c.Back
+		queryFilters = append(slices.Clone(filters), keysetFilter(sorts, c.Values, back))
+	}
+	if back {
+		querySorts = make([]*SortItem, 0, len(sorts))
+		for _, s := range sorts {
+			querySorts = append(querySorts, &SortItem{Field: s.Field, Path: s.Path, Asc: !s.Asc})
+		}
+	}
+
+	// one more row tells that there is a page after this one
+	data, err := T.fetchEntities(ctx, nil, queryFilters, querySorts, 1, pageSize+1)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.SelectEntitiesPage: %w", err)
+	}
+	more := len(data) > pageSize
+	if more {
+		data = data[:pageSize]
+	}
+	if back {
+		slices.Reverse(data)
+	}
+	res := &CursorPage{Data: data, Total: -1}
+	if len(data) > 0 {
+		if (back && more) || (!back && cursor != "") {
+			if res.PrevCursor, err = T.encodeCursor(data[0], sorts, true); err != nil {
+				return nil, fmt.Errorf("EntityDef.SelectEntitiesPage: %w", err)
+			}
+		}
+		if back || more {
+			if res.NextCursor, err = T.encodeCursor(data[len(data)-1], sorts, false); err != nil {
+				return nil, fmt.Errorf("EntityDef.SelectEntitiesPage: %w", err)
+			}
+		}
+	}
+	if count {
+		if res.Total, err = T.countEntities(ctx, filters); err != nil {
+			return nil, fmt.Errorf("EntityDef.SelectEntitiesPage: %w", err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,170 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEntityDef_SelectEntitiesPage(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "cursor.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	goodDef, _ := factory.CreateEntityDef("CrGood", "CrGoods")
+	caption, _ := goodDef.AddStringFieldDef("Caption", 50)
+	qty, _ := goodDef.AddIntFieldDef("Qty")
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	for i := range 25 {
+		e, _ := factory.CreateEntity(goodDef)
+		e.Values["Caption"].(*FieldValueString).Set(fmt.Sprintf("c%d", i%4)) // ties are ordered by ref
+		e.Values["Qty"].(*FieldValueInt).Set(int64(i))
+		if err = e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	sorts := []*SortItem{{Field: caption, Asc: false}}
+	all, _, err := goodDef.SelectEntities(nil, []*SortItem{{Field: caption, Asc: false}, {Field: goodDef.RefField, Asc: true}}, 0, 0)
+	if err != nil {
+		t.Fatalf("SelectEntities() error = %v", err)
+	}
+	refs := func(list []*Entity) []string {
+		res := make([]string, 0, len(list))
+		for _, e := range list {
+			res = append(res, e.RefString())
+		}
+		return res
+	}
+
+	// forward
+	pages := make([]*CursorPage, 0)
+	cursor := ""
+	for {
+		page, err := goodDef.SelectEntitiesPage(ctx, nil, sorts, cursor, 10, len(pages) == 0)
+		if err != nil {
+			t.Fatalf("SelectEntitiesPage() error = %v", err)
+		}
+		pages = append(pages, page)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(pages) != 3 || pages[0].Total != 25 || pages[1].Total != -1 || pages[0].PrevCursor != "" {
+		t.Fatalf("SelectEntitiesPage() pages = %d, total = %d", len(pages), pages[0].Total)
+	}
+	got := make([]*Entity, 0)
+	for _, p := range pages {
+		got = append(got, p.Data...)
+	}
+	if !slices.Equal(refs(got), refs(all)) {
+		t.Errorf("SelectEntitiesPage() forward order differs from SelectEntities")
+	}
+
+	// backward
+	back, err := goodDef.SelectEntitiesPage(ctx, nil, sorts, pages[2].PrevCursor, 10, false)
+	if err != nil {
+		t.Fatalf("SelectEntitiesPage() back error = %v", err)
+	}
+	if !slices.Equal(refs(back.Data), refs(pages[1].Data)) || back.PrevCursor == "" || back.NextCursor == "" {
+		t.Errorf("SelectEntitiesPage() back page differs from the second page")
+	}
+	first, _ := goodDef.SelectEntitiesPage(ctx, nil, sorts, back.PrevCursor, 10, false)
+	if !slices.Equal(refs(first.Data), refs(pages[0].Data)) || first.PrevCursor != "" {
+		t.Errorf("SelectEntitiesPage() back to the first page = %v, prev cursor %q", refs(first.Data), first.PrevCursor)
+	}
+
+	// inserted rows don't shift the next page
+	for range 3 {
+		e, _ := factory.CreateEntity(goodDef)
+		e.Values["Caption"].(*FieldValueString).Set("c9")
+		_ = e.Save(ctx)
+	}
+	second, _ := goodDef.SelectEntitiesPage(ctx, nil, sorts, pages[0].NextCursor, 10, false)
+	if !slices.Equal(refs(second.Data), refs(pages[1].Data)) {
+		t.Errorf("SelectEntitiesPage() page is shifted by inserted rows")
+	}
+
+	// filter (4 seeded and 3 inserted rows) and cursor of other sorting
+	filtered, _ := goodDef.SelectEntitiesPage(ctx, []*Filter{AddFilterLT(qty, int64(4))}, []*SortItem{{Field: qty, Asc: true}}, "", 3, true)
+	if filtered.Total != 7 || len(filtered.Data) != 3 || filtered.NextCursor == "" {
+		t.Errorf("SelectEntitiesPage() with filter: total = %d, rows = %d", filtered.Total, len(filtered.Data))
+	}
+	if _, err = goodDef.SelectEntitiesPage(ctx, nil, sorts, filtered.NextCursor, 3, false); err == nil {
+		t.Errorf("SelectEntitiesPage() with cursor of other sorting should fail")
+	}
+	if _, err = goodDef.SelectEntitiesPage(ctx, nil, sorts, "garbage!", 3, false); err == nil {
+		t.Errorf("SelectEntitiesPage() with invalid cursor should fail")
+	}
+
+	// REST
+	config := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	config.SelectEntitiesPageFunc = goodDef.SelectEntitiesPage
+	config.CursorPaging = true
+	handler := HandleRestApi(config)
+	type restPage struct {
+		Data       []map[string]any
+		NextCursor string
+		PrevCursor string
+		Total      *int
+	}
+	get := func(query url.Values) restPage {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/goods?"+query.Encode(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET status = %d: %s", w.Code, w.Body.String())
+		}
+		var res restPage
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		return res
+	}
+	p1 := get(url.Values{"pagesize": {"20"}, "count": {"true"}, "sortby": {"Qty desc"}})
+	if len(p1.Data) != 20 || p1.NextCursor == "" || p1.Total == nil || *p1.Total != 28 {
+		t.Fatalf("GET first page: rows = %d, next = %q, total = %v", len(p1.Data), p1.NextCursor, p1.Total)
+	}
+	p2 := get(url.Values{"pagesize": {"20"}, "sortby": {"Qty desc"}, "cursor": {p1.NextCursor}})
+	if len(p2.Data) != 8 || p2.NextCursor != "" || p2.PrevCursor == "" || p2.Total != nil {
+		t.Errorf("GET second page: rows = %d, next = %q, prev = %q", len(p2.Data), p2.NextCursor, p2.PrevCursor)
+	}
+
+	// restricted lists don't fall back to unrestricted cursor pagination
+	restricted := func(filters []*Filter) []*Filter { return append(filters, AddFilterLT(qty, int64(5))) }
+	config = CreateStdRestApiConfig(goodDef, factory.LoadEntity,
+		func(filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) ([]*Entity, int, error) {
+			return goodDef.SelectEntities(restricted(filters), sorts, pageNo, pageSize)
+		},
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	handler = HandleRestApi(config)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/goods?cursor=", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET with cursor without SelectEntitiesPageFunc = %d, want 400", w.Code)
+	}
+	config.CursorPaging = true
+	w = httptest.NewRecorder()
+	HandleRestApi(config)(w, httptest.NewRequest(http.MethodGet, "/goods", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("GET with CursorPaging without SelectEntitiesPageFunc = %d, want 500", w.Code)
+	}
+	config.SelectEntitiesPageFunc = func(ctx context.Context, filters []*Filter, sorts []*SortItem, cursor string, pageSize int, count bool) (*CursorPage, error) {
+		return goodDef.SelectEntitiesPage(ctx, restricted(filters), sorts, cursor, pageSize, count)
+	}
+	handler = HandleRestApi(config)
+	want, _, _ := goodDef.SelectEntities(restricted(nil), nil, 0, 0)
+	if p := get(url.Values{"count": {"true"}}); len(p.Data) != len(want) || p.Total == nil || *p.Total != len(want) {
+		t.Errorf("GET restricted page: rows = %d, total = %v, want %d", len(p.Data), p.Total, len(want))
+	}
+}
@@ -0,0 +1,143 @@
+package elorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const deleteRulesMaxListed = 10 // max referencing entities listed in restrict error
+
+type deletingRefsContextKey struct{}
+
+type referencingField struct {
+	owner *EntityDef
+	fd    *FieldDef
+}
+
+// referencingFields returns ref fields of all entity definitions which point to def and have delete rule.
+// FieldDef.EntityDef of ref field is the referenced definition, so owner definition is returned with the field.
+func (T *Factory) referencingFields(def *EntityDef) []referencingField {
+	res := make([]referencingField, 0)
+	seen := make(map[*EntityDef]bool, len(T.EntityDefs))
+	for _, ed := range T.EntityDefs {
+		if seen[ed] {
+			continue
+		}
+		seen[ed] = true
+		for _, fd := range ed.FieldDefs {
+			if fd.Type == FieldDefTypeRef && fd.Name != RefFieldName && fd.EntityDef == def && fd.DeleteRule != DeleteRuleNone {
+				res = append(res, referencingField{owner: ed, fd: fd})
+			}
+		}
+	}
+	return res
+}
+
+// runBeforeDeleteHandlers runs before delete handlers of def for ref. Any handler error vetoes the delete.
+func (T *Factory) runBeforeDeleteHandlers(ctx context.Context, def *EntityDef, ref string) error {
+	for _, handler := range def.beforeDeleteHandlerByRefs {
+		err := handler(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("Factory.runBeforeDeleteHandlers: BeforeDeleteHandlerByRef failed: %w", err)
+		}
+	}
+	if len(def.beforeDeleteHandlers) > 0 {
+		loaded, err := T.LoadEntityContext(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("Factory.runBeforeDeleteHandlers: failed to load entity for deletion (for running BeforeDeleteHandler): %w", err)
+		}
+		for _, handler := range def.beforeDeleteHandlers {
+			err = handler(ctx, loaded)
+			if err != nil {
+				return fmt.Errorf("Factory.runBeforeDeleteHandlers: BeforeDeleteHandler failed: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// softDeleteEntity marks entity with UseSoftDelete as deleted for DeleteEntity. It runs the same before delete handlers
+// and delete rules as hard delete, so soft delete can be vetoed and cascades further.
+func (T *Factory) softDeleteEntity(ctx context.Context, e *Entity) error {
+	ref := e.RefString()
+	ctx, tx, err := T.BeginTranContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Factory.softDeleteEntity: failed to begin transaction: %w", err)
+	}
+	if err = T.runBeforeDeleteHandlers(ctx, e.entityDef, ref); err != nil {
+		_ = T.RollbackTran(tx)
+		return fmt.Errorf("Factory.softDeleteEntity: %w", err)
+	}
+	if ctx, err = T.applyDeleteRules(ctx, e.entityDef, ref); err != nil {
+		_ = T.RollbackTran(tx)
+		return fmt.Errorf("Factory.softDeleteEntity: %w", err)
+	}
+	e.SetIsDeleted(true)
+	if err = e.Save(ctx); err != nil {
+		_ = T.RollbackTran(tx)
+		return fmt.Errorf("Factory.softDeleteEntity: %w", err)
+	}
+	return T.CommitTran(tx)
+}
+
+// applyDeleteRules enforces delete rules of fields referencing ref. It is called by DeleteEntity inside its transaction.
+// ctx carries refs being deleted by the current DeleteEntity chain, so cycles of cascade rules don't loop.
+// Soft deleted referencing entities are ignored.
+func (T *Factory) applyDeleteRules(ctx context.Context, def *EntityDef, ref string) (context.Context, error) {
+	deleting, _ := ctx.Value(deletingRefsContextKey{}).(map[string]bool)
+	if deleting == nil {
+		deleting = make(map[string]bool)
+		ctx = context.WithValue(ctx, deletingRefsContextKey{}, deleting)
+	}
+	deleting[ref] = true
+
+	for _, rf := range T.referencingFields(def) {
+		owner, fd := rf.owner, rf.fd
+		found, _, err := owner.SelectEntitiesContext(ctx, []*Filter{AddFilterEQ(fd, ref)}, nil, 0, 0)
+		if err != nil {
+			return ctx, fmt.Errorf("Factory.applyDeleteRules: failed to select %s referencing %s: %w", owner.ObjectName, ref, err)
+		}
+		referencing := make([]*Entity, 0, len(found))
+		for _, e := range found {
+			if !deleting[e.RefString()] {
+				referencing = append(referencing, e)
+			}
+		}
+		if len(referencing) == 0 {
+			continue
+		}
+
+		switch fd.DeleteRule {
+		case DeleteRuleRestrict:
+			refs := make([]string, 0, deleteRulesMaxListed)
+			for i, e := range referencing {
+				if i == deleteRulesMaxListed {
+					refs = append(refs, fmt.Sprintf("and %d more", len(referencing)-deleteRulesMaxListed))
+					break
+				}
+				refs = append(refs, e.RefString())
+			}
+			return ctx, fmt.Errorf("Factory.applyDeleteRules: %w: %s by %s.%s: %s", ErrReferenced, ref, owner.ObjectName, fd.Name, strings.Join(refs, ", "))
+		case DeleteRuleCascade:
+			for _, e := range referencing {
+				if err = T.DeleteEntity(ctx, e.RefString()); err != nil {
+					return ctx, fmt.Errorf("Factory.applyDeleteRules: failed to cascade delete %s: %w", e.RefString(), err)
+				}
+			}
+		case DeleteRuleClear:
+			for _, e := range referencing {
+				err = e.Values[fd.Name].(*FieldValueRef).Set(nil)
+				if err == nil {
+					err = e.Save(ctx)
+				}
+				if err != nil {
+					return ctx, fmt.Errorf("Factory.applyDeleteRules: failed to clear %s.%s of %s: %w", owner.ObjectName, fd.Name, e.RefString(), err)
+				}
+			}
+		default:
+			return ctx, fmt.Errorf("Factory.applyDeleteRules: unknown delete rule %d for field %s.%s", fd.DeleteRule, owner.ObjectName, fd.Name)
+		}
+	}
+	return ctx, nil
+}
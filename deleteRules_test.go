@@ -0,0 +1,218 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFactory_DeleteRules(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	shopDef, _ := factory.CreateEntityDef("RuleShop", "RuleShops")
+	goodDef, _ := factory.CreateEntityDef("RuleGood", "RuleGoods")
+	noteDef, _ := factory.CreateEntityDef("RuleNote", "RuleNotes")
+	orderDef, _ := factory.CreateEntityDef("RuleOrder", "RuleOrders")
+	goodShop, _ := goodDef.AddRefFieldDef("Shop", shopDef)
+	goodShop.DeleteRule = DeleteRuleCascade
+	noteShop, _ := noteDef.AddRefFieldDef("Shop", shopDef)
+	noteShop.DeleteRule = DeleteRuleClear
+	orderGood, _ := orderDef.AddRefFieldDef("Good", goodDef)
+	orderGood.DeleteRule = DeleteRuleRestrict
+	orderDef.UseSoftDelete = true
+	parent, _ := shopDef.AddRefFieldDef("Parent", shopDef) // cycle
+	parent.DeleteRule = DeleteRuleCascade
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	create := func(def *EntityDef, field string, ref IEntity) *Entity {
+		e, _ := factory.CreateEntity(def)
+		if ref != nil {
+			_ = e.Values[field].(*FieldValueRef).Set(ref)
+		}
+		if err := e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return e
+	}
+	exists := func(e *Entity) bool {
+		factory.loadedEntities.Purge()
+		_, err := factory.LoadEntity(e.RefString())
+		return err == nil
+	}
+
+	deletedGoods := 0
+	err = factory.AddBeforeDeleteHandler(goodDef, func(ctx context.Context, entity any) error {
+		deletedGoods++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("AddBeforeDeleteHandler() error = %v", err)
+	}
+
+	shop := create(shopDef, "", nil)
+	good := create(goodDef, "Shop", shop)
+	note := create(noteDef, "Shop", shop)
+	order := create(orderDef, "Good", good)
+
+	// restrict
+	err = factory.DeleteEntity(ctx, shop.RefString())
+	if err == nil || !strings.Contains(err.Error(), order.RefString()) {
+		t.Fatalf("DeleteEntity() error = %v, want restrict error listing %s", err, order.RefString())
+	}
+	if !exists(shop) || !exists(good) {
+		t.Errorf("restricted delete is not rolled back")
+	}
+
+	// soft deleted referencing entity doesn't restrict
+	order.SetIsDeleted(true)
+	if err = order.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// cascade and clear
+	deletedGoods = 0
+	if err = factory.DeleteEntity(ctx, shop.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	if exists(shop) || exists(good) {
+		t.Errorf("cascade delete failed")
+	}
+	if deletedGoods != 1 {
+		t.Errorf("before delete handler of cascaded entity runs %d times, want 1", deletedGoods)
+	}
+	loaded, err := factory.LoadEntity(note.RefString())
+	if err != nil {
+		t.Fatalf("LoadEntity() error = %v", err)
+	}
+	if got := loaded.Values["Shop"].(*FieldValueRef).AsString(); got != "" {
+		t.Errorf("cleared ref = %s, want empty", got)
+	}
+
+	// cascade to soft delete entity
+	orderGood.DeleteRule = DeleteRuleCascade
+	shop = create(shopDef, "", nil)
+	good = create(goodDef, "Shop", shop)
+	order = create(orderDef, "Good", good)
+	if err = factory.DeleteEntity(ctx, good.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	factory.loadedEntities.Purge()
+	loaded, err = factory.LoadEntity(order.RefString())
+	if err != nil {
+		t.Fatalf("soft deleted entity should stay in database: %v", err)
+	}
+	if !loaded.IsDeleted() {
+		t.Errorf("cascade to UseSoftDelete entity should set IsDeleted")
+	}
+
+	// before delete handler of soft deleted entity vetoes cascade
+	err = factory.AddBeforeDeleteHandler(orderDef, func(ctx context.Context, entity any) error {
+		return errors.New("order is locked")
+	})
+	if err != nil {
+		t.Fatalf("AddBeforeDeleteHandler() error = %v", err)
+	}
+	good = create(goodDef, "Shop", shop)
+	order = create(orderDef, "Good", good)
+	if err = factory.DeleteEntity(ctx, good.RefString()); err == nil || !strings.Contains(err.Error(), "order is locked") {
+		t.Errorf("DeleteEntity() error = %v, want veto of before delete handler", err)
+	}
+	if !exists(good) {
+		t.Errorf("vetoed cascade deleted entity")
+	}
+	if loaded, err = factory.LoadEntity(order.RefString()); err != nil || loaded.IsDeleted() {
+		t.Errorf("vetoed cascade soft deleted entity: %v", err)
+	}
+	orderDef.beforeDeleteHandlers = nil
+
+	// cycle
+	child := create(shopDef, "Parent", shop)
+	_ = shop.Values["Parent"].(*FieldValueRef).Set(child)
+	if err = shop.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err = factory.DeleteEntity(ctx, shop.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() with cycle error = %v", err)
+	}
+	if exists(shop) || exists(child) {
+		t.Errorf("cascade delete with cycle failed")
+	}
+}
+
+func TestRestApi_SoftDeleteRules(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "softrules.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	shopDef, _ := factory.CreateEntityDef("SrShop", "SrShops")
+	shopDef.UseSoftDelete = true
+	orderDef, _ := factory.CreateEntityDef("SrOrder", "SrOrders")
+	orderShop, _ := orderDef.AddRefFieldDef("Shop", shopDef)
+	orderShop.DeleteRule = DeleteRuleRestrict
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	ctx := context.Background()
+	shop, _ := factory.CreateEntity(shopDef)
+	if err = shop.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	order, _ := factory.CreateEntity(orderDef)
+	_ = order.Values["Shop"].(*FieldValueRef).Set(shop)
+	if err = order.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	deleted := func() bool {
+		loaded, err := factory.LoadEntity(shop.RefString())
+		if err != nil {
+			t.Fatalf("LoadEntity() error = %v", err)
+		}
+		return loaded.IsDeleted()
+	}
+
+	config := CreateStdRestApiConfig(shopDef, factory.LoadEntity, shopDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(shopDef) })
+	config.EnableBatch = true
+	del := func() int {
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, httptest.NewRequest(http.MethodDelete, "/shops?ref="+shop.RefString(), nil))
+		return w.Code
+	}
+	batchDel := func() int {
+		w := httptest.NewRecorder()
+		body := `[{"Op": "delete", "Ref": "` + shop.RefString() + `"}]`
+		HandleRestApi(config)(w, httptest.NewRequest(http.MethodPost, "/shops?batch", strings.NewReader(body)))
+		var results []RestApiBatchResult
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != 1 {
+			t.Fatalf("batch response %d %s", w.Code, w.Body.String())
+		}
+		return results[0].Status
+	}
+
+	if code := del(); code != http.StatusConflict || deleted() {
+		t.Errorf("DELETE of restricted soft delete entity = %d, deleted = %v", code, deleted())
+	}
+	if code := batchDel(); code != http.StatusConflict || deleted() {
+		t.Errorf("batch delete of restricted soft delete entity = %d, deleted = %v", code, deleted())
+	}
+
+	if err = factory.DeleteEntity(ctx, order.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	if code := del(); code != http.StatusOK || !deleted() {
+		t.Errorf("DELETE of soft delete entity = %d, deleted = %v", code, deleted())
+	}
+	if code := batchDel(); code != http.StatusOK {
+		t.Errorf("batch delete of soft deleted entity = %d, want 200", code)
+	}
+}
@@ -0,0 +1,229 @@
+package elorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Querier is the subset of *sql.DB, *sql.Tx and *Factory methods dialects use to inspect database structure.
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// DbColumn describes a table column as it exists in the database. Type is in the same notation as Dialect.ColumnType returns.
+type DbColumn struct {
+	Name string
+	Type string
+}
+
+// DbForeignKey describes a foreign key constraint of Ref-typed column to Ref column of another table.
+type DbForeignKey struct {
+	Name     string
+	Column   string
+	RefTable string
+	OnDelete int // ForeignKeyRestrict, ForeignKeyCascade or ForeignKeySetNull
+}
+
+// DbIndex describes a database index as it exists (or should exist) in the database.
+type DbIndex struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// Dialect encapsulates all database-specific behavior: SQL syntax, column types and database structure introspection.
+// Factory is created with a Dialect, see CreateFactory and CreateFactoryWithDialect.
+// Built-in dialects are PostgresDialect, MSSQLDialect, MySQLDialect and SQLiteDialect. Custom dialects can embed one of them
+// to override only the differences and be registered by RegisterDialect.
+type Dialect interface {
+
+	// Name returns dialect name, e.g. "postgres"
+	Name() string
+
+	// DriverName returns database/sql driver name used to open database connections
+	DriverName() string
+
+	// Placeholder returns query parameter placeholder for n-th (starting from 1) parameter, e.g. "$1" or "?"
+	Placeholder(n int) string
+
+	// SingleWriter returns true when database supports only one writing transaction at the same time (e.g. SQLite)
+	SingleWriter() bool
+
+	// TransactionalDDL returns true when database structure changes are rolled back with transaction. MySQL commits
+	// each of them implicitly, so ApplyDBStructure can leave changes applied partially there.
+	TransactionalDDL() bool
+
+	// SavepointSql returns statement to create savepoint inside transaction, used for nested transactions
+	SavepointSql(name string) string
+
+	// ReleaseSavepointSql returns statement to release savepoint, or empty string when dialect doesn't release savepoints
+	ReleaseSavepointSql(name string) string
+
+	// RollbackToSavepointSql returns statement to roll back transaction to savepoint
+	RollbackToSavepointSql(name string) string
+
+	// ColumnType returns SQL column type for field definition
+	ColumnType(fd *FieldDef) (string, error)
+
+	// BoolLiteral returns SQL literal for boolean value
+	BoolLiteral(v bool) string
+
+	// LikeClause returns case-insensitive LIKE condition for column and parameter placeholder. Pattern bound to placeholder
+	// is already lowered in Go (strings.ToLower), so dialect has to compare it with lowered column only.
+	LikeClause(column string, placeholder string) string
+
+	// PagingClause returns clause to add after "order by" to fetch limit rows starting from offset
+	PagingClause(limit int, offset int) string
+
+	// RefTypeSql returns statements to create user-defined type for Ref columns. Returns nothing when type exists or isn't needed.
+	RefTypeSql(q Querier) ([]string, error)
+
+	// CreateTableSql returns statement to create table (if it doesn't exist) with Ref primary key column
+	CreateTableSql(table string) string
+
+	// TableColumns returns existing table columns, or empty slice when table doesn't exist
+	TableColumns(q Querier, table string) ([]*DbColumn, error)
+
+	// RenameTableSql returns statement to rename table
+	RenameTableSql(table string, newName string) string
+
+	// RenameColumnSql returns statement to rename column
+	RenameColumnSql(table string, column string, newName string) string
+
+	// AddColumnSql returns statement to add column to existing table
+	AddColumnSql(table string, column string, colType string) string
+
+	// AlterColumnTypeSql returns statement to change type of existing column, or empty string when table should be rebuilt instead
+	AlterColumnTypeSql(table string, column string, colType string) string
+
+	// DropColumnSql returns statement to drop column, or empty string when table should be rebuilt instead
+	DropColumnSql(table string, column string) string
+
+	// RebuildTableSql returns statements to recreate table with columns (first one is Ref) and foreign keys and copy data of copyColumns
+	// from the old table. When copyColumns is empty, table doesn't exist yet and it is just created.
+	// Indexes of the table are dropped. Returns nil when dialect doesn't need table rebuilds.
+	RebuildTableSql(table string, columns []*DbColumn, foreignKeys []*DbForeignKey, copyColumns []string) []string
+
+	// PrepareSchemaConn prepares dedicated connection used to apply database structure changes (e.g. SQLite disables foreign keys
+	// for table rebuilds) and returns function to restore connection state.
+	PrepareSchemaConn(ctx context.Context, conn *sql.Conn) (restore func() error, err error)
+
+	// TableForeignKeys returns existing foreign keys of the table
+	TableForeignKeys(q Querier, table string) ([]*DbForeignKey, error)
+
+	// AddForeignKeySql returns statement to add foreign key, or empty string when table should be rebuilt instead
+	AddForeignKeySql(table string, fk *DbForeignKey) string
+
+	// DropForeignKeySql returns statement to drop foreign key, or empty string when table should be rebuilt instead
+	DropForeignKeySql(table string, fk *DbForeignKey) string
+
+	// TableIndexes returns existing table indexes, except primary key
+	TableIndexes(q Querier, table string) ([]*DbIndex, error)
+
+	// DropIndexSql returns statement to drop index
+	DropIndexSql(table string, index string) string
+}
+
+var dialectsLock sync.Mutex
+var dialects = map[string]Dialect{
+	"postgres": PostgresDialect{},
+	"mssql":    MSSQLDialect{},
+	"mysql":    MySQLDialect{},
+	"sqlite":   SQLiteDialect{},
+	"sqlite3":  SQLiteDialect{Driver: "sqlite3"},
+}
+
+// RegisterDialect registers dialect under the name, so it can be used by CreateFactory. It can redefine built-in dialects.
+func RegisterDialect(name string, dialect Dialect) error {
+	if name == "" {
+		return fmt.Errorf("RegisterDialect: name is empty")
+	}
+	if dialect == nil {
+		return fmt.Errorf("RegisterDialect: dialect is nil")
+	}
+	dialectsLock.Lock()
+	defer dialectsLock.Unlock()
+	dialects[strings.ToLower(name)] = dialect
+	return nil
+}
+
+// DialectByName returns registered dialect by its name.
+func DialectByName(name string) (Dialect, error) {
+	dialectsLock.Lock()
+	defer dialectsLock.Unlock()
+	d, ok := dialects[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("DialectByName: unsupported db dialect: %s", name)
+	}
+	return d, nil
+}
+
+// scanStrings reads first column of all rows as strings
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]string, 0)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+// onDeleteSql returns ON DELETE action for foreign key mode
+func onDeleteSql(mode int) string {
+	switch mode {
+	case ForeignKeyCascade:
+		return "cascade"
+	case ForeignKeySetNull:
+		return "set null"
+	default:
+		return "restrict"
+	}
+}
+
+// onDeleteMode parses ON DELETE action reported by database, e.g. "SET NULL", "SET_NULL" or "NO ACTION"
+func onDeleteMode(action string) int {
+	switch strings.ToLower(strings.ReplaceAll(action, "_", " ")) {
+	case "cascade":
+		return ForeignKeyCascade
+	case "set null":
+		return ForeignKeySetNull
+	default:
+		return ForeignKeyRestrict
+	}
+}
+
+const foreignKeyNameInfix = "_fk_"
+
+// foreignKeyName returns name of foreign key constraint for the column
+func foreignKeyName(table string, column string) string {
+	return table + foreignKeyNameInfix + column
+}
+
+func noSchemaConnRestore() error {
+	return nil
+}
+
+// sameColumnType compares column types ignoring case and spaces
+func sameColumnType(a string, b string) bool {
+	return strings.EqualFold(strings.ReplaceAll(a, " ", ""), strings.ReplaceAll(b, " ", ""))
+}
+
+// appendIndexColumn adds column to index with specified name or creates a new index item
+func appendIndexColumn(indexes []*DbIndex, name string, unique bool, column string) []*DbIndex {
+	for _, v := range indexes {
+		if v.Name == name {
+			v.Columns = append(v.Columns, column)
+			return indexes
+		}
+	}
+	return append(indexes, &DbIndex{Name: name, Unique: unique, Columns: []string{column}})
+}
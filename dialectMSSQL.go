@@ -0,0 +1,243 @@
+package elorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MSSQLDialect implements Dialect for Microsoft SQL Server.
+type MSSQLDialect struct {
+	Driver string // database/sql driver name, "mssql" if empty
+}
+
+func (T MSSQLDialect) Name() string {
+	return "mssql"
+}
+
+func (T MSSQLDialect) DriverName() string {
+	if T.Driver != "" {
+		return T.Driver
+	}
+	return "mssql"
+}
+
+func (T MSSQLDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (T MSSQLDialect) SingleWriter() bool {
+	return false
+}
+
+func (T MSSQLDialect) TransactionalDDL() bool {
+	return true
+}
+
+func (T MSSQLDialect) SavepointSql(name string) string {
+	return fmt.Sprintf("save transaction %s", name)
+}
+
+// ReleaseSavepointSql returns empty string, MSSQL doesn't release savepoints
+func (T MSSQLDialect) ReleaseSavepointSql(name string) string {
+	return ""
+}
+
+func (T MSSQLDialect) RollbackToSavepointSql(name string) string {
+	return fmt.Sprintf("rollback transaction %s", name)
+}
+
+func (T MSSQLDialect) ColumnType(fd *FieldDef) (string, error) {
+	switch fd.Type {
+	case FieldDefTypeString:
+		return fmt.Sprintf("nvarchar(%d)", fd.Len), nil
+	case FieldDefTypeInt:
+		return "bigint", nil
+	case FieldDefTypeBool:
+		return "bit", nil
+	case FieldDefTypeRef:
+		return fmt.Sprintf("nvarchar(%d)", refFieldLength), nil
+	case FieldDefTypeDateTime:
+		return "datetime", nil
+	case FieldDefTypeNumeric:
+		return fmt.Sprintf("decimal(%d,%d)", fd.Precision, fd.Scale), nil
+	default:
+		return "", fmt.Errorf("MSSQLDialect.ColumnType: unknown field type: %d", fd.Type)
+	}
+}
+
+func (T MSSQLDialect) BoolLiteral(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+func (T MSSQLDialect) LikeClause(column string, placeholder string) string {
+	return fmt.Sprintf("LOWER(%s) LIKE %s", column, placeholder)
+}
+
+func (T MSSQLDialect) PagingClause(limit int, offset int) string {
+	return fmt.Sprintf(" offset %d rows fetch next %d rows only", offset, limit)
+}
+
+func (T MSSQLDialect) RefTypeSql(q Querier) ([]string, error) {
+	rows, err := q.Query("SELECT name FROM sys.types WHERE name = $1", refTypeName)
+	if err != nil {
+		return nil, fmt.Errorf("MSSQLDialect.RefTypeSql: failed to query sys.types: %w", err)
+	}
+	found, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("MSSQLDialect.RefTypeSql: failed to scan sys.types: %w", err)
+	}
+	if len(found) > 0 {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("CREATE TYPE %s FROM nvarchar(%d)", refTypeName, refFieldLength)}, nil
+}
+
+func (T MSSQLDialect) CreateTableSql(table string) string {
+	return fmt.Sprintf("if not exists (select * from sysobjects where name='%s' and xtype='U') create table %s (ref nvarchar(%d) primary key)", table, table, refFieldLength)
+}
+
+func (T MSSQLDialect) TableColumns(q Querier, table string) ([]*DbColumn, error) {
+	rows, err := q.Query(`
+		select c.name, t.name, c.max_length, c.precision, c.scale
+		from sys.columns c inner join sys.types t on t.user_type_id = c.user_type_id
+		where c.object_id = object_id($1)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("MSSQLDialect.TableColumns: failed to query columns: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbColumn, 0)
+	for rows.Next() {
+		var name, typeName string
+		var maxLength, precision, scale int
+		if err := rows.Scan(&name, &typeName, &maxLength, &precision, &scale); err != nil {
+			return nil, fmt.Errorf("MSSQLDialect.TableColumns: failed to scan column: %w", err)
+		}
+		colType := typeName
+		switch typeName {
+		case "nvarchar":
+			colType = fmt.Sprintf("nvarchar(%d)", maxLength/2) // max_length is in bytes
+		case "varchar":
+			colType = fmt.Sprintf("varchar(%d)", maxLength)
+		case "decimal", "numeric":
+			colType = fmt.Sprintf("decimal(%d,%d)", precision, scale)
+		}
+		res = append(res, &DbColumn{Name: name, Type: colType})
+	}
+	return res, rows.Err()
+}
+
+func (T MSSQLDialect) RenameTableSql(table string, newName string) string {
+	return fmt.Sprintf("exec sp_rename '%s', '%s'", table, newName)
+}
+
+func (T MSSQLDialect) RenameColumnSql(table string, column string, newName string) string {
+	return fmt.Sprintf("exec sp_rename '%s.%s', '%s', 'COLUMN'", table, column, newName)
+}
+
+func (T MSSQLDialect) AddColumnSql(table string, column string, colType string) string {
+	return fmt.Sprintf("alter table %s add %s %s", table, column, colType)
+}
+
+func (T MSSQLDialect) AlterColumnTypeSql(table string, column string, colType string) string {
+	return fmt.Sprintf("alter table %s alter column %s %s", table, column, colType)
+}
+
+func (T MSSQLDialect) DropColumnSql(table string, column string) string {
+	return fmt.Sprintf("alter table %s drop column %s", table, column)
+}
+
+func (T MSSQLDialect) RebuildTableSql(table string, columns []*DbColumn, foreignKeys []*DbForeignKey, copyColumns []string) []string {
+	return nil
+}
+
+func (T MSSQLDialect) PrepareSchemaConn(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	return noSchemaConnRestore, nil
+}
+
+func (T MSSQLDialect) TableForeignKeys(q Querier, table string) ([]*DbForeignKey, error) {
+	rows, err := q.Query(`
+		select fk.name, c.name, rt.name, fk.delete_referential_action_desc
+		from sys.foreign_keys fk
+			inner join sys.foreign_key_columns fkc on fkc.constraint_object_id = fk.object_id
+			inner join sys.columns c on c.object_id = fkc.parent_object_id and c.column_id = fkc.parent_column_id
+			inner join sys.tables rt on rt.object_id = fk.referenced_object_id
+		where fk.parent_object_id = object_id($1)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("MSSQLDialect.TableForeignKeys: failed to query foreign keys: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbForeignKey, 0)
+	for rows.Next() {
+		fk := &DbForeignKey{}
+		var action string
+		if err := rows.Scan(&fk.Name, &fk.Column, &fk.RefTable, &action); err != nil {
+			return nil, fmt.Errorf("MSSQLDialect.TableForeignKeys: failed to scan foreign key: %w", err)
+		}
+		fk.OnDelete = onDeleteMode(action)
+		res = append(res, fk)
+	}
+	return res, rows.Err()
+}
+
+// AddForeignKeySql uses "no action" for ForeignKeyRestrict, MSSQL doesn't support "restrict"
+func (T MSSQLDialect) AddForeignKeySql(table string, fk *DbForeignKey) string {
+	return fmt.Sprintf("alter table %s add constraint %s foreign key (%s) references %s (ref) on delete %s",
+		table, fk.Name, fk.Column, fk.RefTable, strings.Replace(onDeleteSql(fk.OnDelete), "restrict", "no action", 1))
+}
+
+func (T MSSQLDialect) DropForeignKeySql(table string, fk *DbForeignKey) string {
+	return fmt.Sprintf("alter table %s drop constraint %s", table, fk.Name)
+}
+
+func (T MSSQLDialect) TableIndexes(q Querier, table string) ([]*DbIndex, error) {
+	query := `
+		SELECT
+			i.name AS iname,
+			i.is_unique AS uni,
+			c.name AS cname
+		FROM
+			sys.indexes i
+			INNER JOIN sys.index_columns ic ON i.object_id = ic.object_id AND i.index_id = ic.index_id
+			INNER JOIN sys.columns c ON ic.object_id = c.object_id AND ic.column_id = c.column_id
+			INNER JOIN sys.tables t ON i.object_id = t.object_id
+		WHERE
+			t.name = $1
+			AND i.is_primary_key = 0
+		ORDER BY
+			i.name, ic.index_column_id
+	`
+	rows, err := q.Query(query, table)
+	if err != nil {
+		return nil, fmt.Errorf("MSSQLDialect.TableIndexes: failed to query existing indexes: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]*DbIndex, 0)
+	for rows.Next() {
+		var iname, cname string
+		var uni bool
+		if err := rows.Scan(&iname, &uni, &cname); err != nil {
+			return nil, fmt.Errorf("MSSQLDialect.TableIndexes: failed to scan index row: %w", err)
+		}
+		if len(iname) == 0 || len(cname) == 0 {
+			continue
+		}
+		res = appendIndexColumn(res, iname, uni, cname)
+	}
+	return res, nil
+}
+
+func (T MSSQLDialect) DropIndexSql(table string, index string) string {
+	return fmt.Sprintf("drop index %s on %s", index, table)
+}
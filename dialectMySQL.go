@@ -0,0 +1,222 @@
+package elorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MySQLDialect implements Dialect for MySQL.
+type MySQLDialect struct {
+	Driver string // database/sql driver name, "mysql" if empty
+}
+
+func (T MySQLDialect) Name() string {
+	return "mysql"
+}
+
+func (T MySQLDialect) DriverName() string {
+	if T.Driver != "" {
+		return T.Driver
+	}
+	return "mysql"
+}
+
+func (T MySQLDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (T MySQLDialect) SingleWriter() bool {
+	return false
+}
+
+func (T MySQLDialect) TransactionalDDL() bool {
+	return false
+}
+
+func (T MySQLDialect) SavepointSql(name string) string {
+	return fmt.Sprintf("savepoint %s", name)
+}
+
+func (T MySQLDialect) ReleaseSavepointSql(name string) string {
+	return fmt.Sprintf("release savepoint %s", name)
+}
+
+func (T MySQLDialect) RollbackToSavepointSql(name string) string {
+	return fmt.Sprintf("rollback to savepoint %s", name)
+}
+
+func (T MySQLDialect) ColumnType(fd *FieldDef) (string, error) {
+	switch fd.Type {
+	case FieldDefTypeString:
+		return fmt.Sprintf("varchar(%d)", fd.Len), nil
+	case FieldDefTypeInt:
+		return "int", nil
+	case FieldDefTypeBool:
+		return "tinyint(1)", nil
+	case FieldDefTypeRef:
+		return fmt.Sprintf("varchar(%d)", refFieldLength), nil
+	case FieldDefTypeDateTime:
+		return "datetime", nil
+	case FieldDefTypeNumeric:
+		return fmt.Sprintf("decimal(%d,%d)", fd.Precision, fd.Scale), nil
+	default:
+		return "", fmt.Errorf("MySQLDialect.ColumnType: unknown field type: %d", fd.Type)
+	}
+}
+
+func (T MySQLDialect) BoolLiteral(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+func (T MySQLDialect) LikeClause(column string, placeholder string) string {
+	return fmt.Sprintf("LOWER(%s) LIKE %s", column, placeholder)
+}
+
+func (T MySQLDialect) PagingClause(limit int, offset int) string {
+	return fmt.Sprintf(" limit %d, %d", offset, limit)
+}
+
+func (T MySQLDialect) RefTypeSql(q Querier) ([]string, error) {
+	// MySQL does not support custom domains/types like Postgres/MSSQL, so just ensure columns use VARCHAR(107)
+	return nil, nil
+}
+
+func (T MySQLDialect) CreateTableSql(table string) string {
+	return fmt.Sprintf("create table if not exists %s (ref varchar(%d) primary key)", table, refFieldLength)
+}
+
+func (T MySQLDialect) TableColumns(q Querier, table string) ([]*DbColumn, error) {
+	rows, err := q.Query("select column_name, column_type from information_schema.columns where table_schema=database() and table_name=?", table)
+	if err != nil {
+		return nil, fmt.Errorf("MySQLDialect.TableColumns: failed to query columns: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbColumn, 0)
+	for rows.Next() {
+		var name, colType string
+		if err := rows.Scan(&name, &colType); err != nil {
+			return nil, fmt.Errorf("MySQLDialect.TableColumns: failed to scan column: %w", err)
+		}
+		// MySQL before 8.0.19 reports display width for int columns, e.g. int(11)
+		if strings.HasPrefix(colType, "int(") {
+			colType = "int"
+		}
+		res = append(res, &DbColumn{Name: name, Type: colType})
+	}
+	return res, rows.Err()
+}
+
+func (T MySQLDialect) RenameTableSql(table string, newName string) string {
+	return fmt.Sprintf("rename table %s to %s", table, newName)
+}
+
+func (T MySQLDialect) RenameColumnSql(table string, column string, newName string) string {
+	return fmt.Sprintf("alter table %s rename column %s to %s", table, column, newName)
+}
+
+func (T MySQLDialect) AddColumnSql(table string, column string, colType string) string {
+	return fmt.Sprintf("alter table %s add column %s %s", table, column, colType)
+}
+
+func (T MySQLDialect) AlterColumnTypeSql(table string, column string, colType string) string {
+	return fmt.Sprintf("alter table %s modify column %s %s", table, column, colType)
+}
+
+func (T MySQLDialect) DropColumnSql(table string, column string) string {
+	return fmt.Sprintf("alter table %s drop column %s", table, column)
+}
+
+func (T MySQLDialect) RebuildTableSql(table string, columns []*DbColumn, foreignKeys []*DbForeignKey, copyColumns []string) []string {
+	return nil
+}
+
+func (T MySQLDialect) PrepareSchemaConn(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	return noSchemaConnRestore, nil
+}
+
+func (T MySQLDialect) TableForeignKeys(q Querier, table string) ([]*DbForeignKey, error) {
+	rows, err := q.Query(`
+		select rc.constraint_name, kcu.column_name, rc.referenced_table_name, rc.delete_rule
+		from information_schema.referential_constraints rc
+			inner join information_schema.key_column_usage kcu on kcu.constraint_schema = rc.constraint_schema
+				and kcu.constraint_name = rc.constraint_name and kcu.table_name = rc.table_name
+		where rc.constraint_schema = database() and rc.table_name = ?`, table)
+	if err != nil {
+		return nil, fmt.Errorf("MySQLDialect.TableForeignKeys: failed to query foreign keys: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbForeignKey, 0)
+	for rows.Next() {
+		fk := &DbForeignKey{}
+		var rule string
+		if err := rows.Scan(&fk.Name, &fk.Column, &fk.RefTable, &rule); err != nil {
+			return nil, fmt.Errorf("MySQLDialect.TableForeignKeys: failed to scan foreign key: %w", err)
+		}
+		fk.OnDelete = onDeleteMode(rule)
+		res = append(res, fk)
+	}
+	return res, rows.Err()
+}
+
+func (T MySQLDialect) AddForeignKeySql(table string, fk *DbForeignKey) string {
+	return fmt.Sprintf("alter table %s add constraint %s foreign key (%s) references %s (ref) on delete %s",
+		table, fk.Name, fk.Column, fk.RefTable, onDeleteSql(fk.OnDelete))
+}
+
+func (T MySQLDialect) DropForeignKeySql(table string, fk *DbForeignKey) string {
+	return fmt.Sprintf("alter table %s drop foreign key %s", table, fk.Name)
+}
+
+func (T MySQLDialect) TableIndexes(q Querier, table string) ([]*DbIndex, error) {
+	rows, err := q.Query(fmt.Sprintf("show index from %s where Key_name!='PRIMARY'", table))
+	if err != nil {
+		return nil, fmt.Errorf("MySQLDialect.TableIndexes: failed to query existing indexes: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	rescols, _ := rows.Columns()
+	key_name_colidx := slices.Index(rescols, "Key_name")
+	column_name_colidx := slices.Index(rescols, "Column_name")
+	nonUnique_colidx := slices.Index(rescols, "Non_unique")
+	if key_name_colidx < 0 || column_name_colidx < 0 || nonUnique_colidx < 0 {
+		return nil, fmt.Errorf("MySQLDialect.TableIndexes: missing required columns in index query result")
+	}
+
+	res := make([]*DbIndex, 0)
+	for rows.Next() {
+		buf := make([]any, len(rescols))
+		for i := range buf {
+			buf[i] = new(any)
+		}
+		var key_name string
+		var column_name string
+		var nonUnique int
+		buf[key_name_colidx] = &key_name
+		buf[column_name_colidx] = &column_name
+		buf[nonUnique_colidx] = &nonUnique
+		if err := rows.Scan(buf...); err != nil {
+			return nil, fmt.Errorf("MySQLDialect.TableIndexes: failed to scan index row: %w", err)
+		}
+		if len(key_name) == 0 || len(column_name) == 0 || strings.Contains(key_name, foreignKeyNameInfix) { // index created by MySQL for foreign key
+			continue
+		}
+		res = appendIndexColumn(res, key_name, nonUnique == 0, column_name)
+	}
+	return res, nil
+}
+
+func (T MySQLDialect) DropIndexSql(table string, index string) string {
+	return fmt.Sprintf("drop index %s on %s", index, table)
+}
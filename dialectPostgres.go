@@ -0,0 +1,256 @@
+package elorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const refTypeName = "elorm_ref_type" // user-defined type for Ref columns (Postgres, MSSQL)
+
+// PostgresDialect implements Dialect for PostgreSQL.
+type PostgresDialect struct {
+	Driver string // database/sql driver name, "postgres" if empty
+}
+
+func (T PostgresDialect) Name() string {
+	return "postgres"
+}
+
+func (T PostgresDialect) DriverName() string {
+	if T.Driver != "" {
+		return T.Driver
+	}
+	return "postgres"
+}
+
+func (T PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (T PostgresDialect) SingleWriter() bool {
+	return false
+}
+
+func (T PostgresDialect) TransactionalDDL() bool {
+	return true
+}
+
+func (T PostgresDialect) SavepointSql(name string) string {
+	return fmt.Sprintf("savepoint %s", name)
+}
+
+func (T PostgresDialect) ReleaseSavepointSql(name string) string {
+	return fmt.Sprintf("release savepoint %s", name)
+}
+
+func (T PostgresDialect) RollbackToSavepointSql(name string) string {
+	return fmt.Sprintf("rollback to savepoint %s", name)
+}
+
+func (T PostgresDialect) ColumnType(fd *FieldDef) (string, error) {
+	switch fd.Type {
+	case FieldDefTypeString:
+		return fmt.Sprintf("varchar(%d)", fd.Len), nil
+	case FieldDefTypeInt:
+		return "int", nil
+	case FieldDefTypeBool:
+		return "bool", nil
+	case FieldDefTypeRef:
+		return refTypeName, nil
+	case FieldDefTypeDateTime:
+		return "timestamp without time zone", nil
+	case FieldDefTypeNumeric:
+		return fmt.Sprintf("decimal(%d,%d)", fd.Precision, fd.Scale), nil
+	default:
+		return "", fmt.Errorf("PostgresDialect.ColumnType: unknown field type: %d", fd.Type)
+	}
+}
+
+func (T PostgresDialect) BoolLiteral(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (T PostgresDialect) LikeClause(column string, placeholder string) string {
+	return fmt.Sprintf("%s ILIKE %s", column, placeholder)
+}
+
+func (T PostgresDialect) PagingClause(limit int, offset int) string {
+	return fmt.Sprintf(" limit %d offset %d", limit, offset)
+}
+
+func (T PostgresDialect) RefTypeSql(q Querier) ([]string, error) {
+	rows, err := q.Query("select typname from pg_type where typname=$1", refTypeName)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresDialect.RefTypeSql: failed to query pg_type: %w", err)
+	}
+	found, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresDialect.RefTypeSql: failed to scan pg_type: %w", err)
+	}
+	if len(found) > 0 {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("create domain %s as varchar(%d)", refTypeName, refFieldLength)}, nil
+}
+
+func (T PostgresDialect) CreateTableSql(table string) string {
+	return fmt.Sprintf("create table if not exists %s (ref %s constraint %s_pk primary key)", table, refTypeName, table)
+}
+
+func (T PostgresDialect) TableColumns(q Querier, table string) ([]*DbColumn, error) {
+	rows, err := q.Query(`
+		select
+			column_name, data_type, coalesce(domain_name, ''),
+			coalesce(character_maximum_length, 0), coalesce(numeric_precision, 0), coalesce(numeric_scale, 0)
+		from information_schema.columns
+		where table_schema=current_schema() and table_name=$1`, table)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresDialect.TableColumns: failed to query columns: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbColumn, 0)
+	for rows.Next() {
+		var name, dataType, domain string
+		var length, precision, scale int
+		if err := rows.Scan(&name, &dataType, &domain, &length, &precision, &scale); err != nil {
+			return nil, fmt.Errorf("PostgresDialect.TableColumns: failed to scan column: %w", err)
+		}
+		colType := dataType
+		switch {
+		case domain != "":
+			colType = domain
+		case dataType == "character varying":
+			colType = fmt.Sprintf("varchar(%d)", length)
+		case dataType == "integer":
+			colType = "int"
+		case dataType == "boolean":
+			colType = "bool"
+		case dataType == "numeric":
+			colType = fmt.Sprintf("decimal(%d,%d)", precision, scale)
+		}
+		res = append(res, &DbColumn{Name: name, Type: colType})
+	}
+	return res, rows.Err()
+}
+
+func (T PostgresDialect) RenameTableSql(table string, newName string) string {
+	return fmt.Sprintf("alter table %s rename to %s", table, newName)
+}
+
+func (T PostgresDialect) RenameColumnSql(table string, column string, newName string) string {
+	return fmt.Sprintf("alter table %s rename column %s to %s", table, column, newName)
+}
+
+func (T PostgresDialect) AddColumnSql(table string, column string, colType string) string {
+	return fmt.Sprintf("alter table %s add column %s %s", table, column, colType)
+}
+
+func (T PostgresDialect) AlterColumnTypeSql(table string, column string, colType string) string {
+	return fmt.Sprintf("alter table %s alter column %s type %s", table, column, colType)
+}
+
+func (T PostgresDialect) DropColumnSql(table string, column string) string {
+	return fmt.Sprintf("alter table %s drop column %s", table, column)
+}
+
+func (T PostgresDialect) RebuildTableSql(table string, columns []*DbColumn, foreignKeys []*DbForeignKey, copyColumns []string) []string {
+	return nil
+}
+
+func (T PostgresDialect) PrepareSchemaConn(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	return noSchemaConnRestore, nil
+}
+
+func (T PostgresDialect) TableForeignKeys(q Querier, table string) ([]*DbForeignKey, error) {
+	rows, err := q.Query(`
+		select con.conname, att.attname, rt.relname, con.confdeltype
+		from pg_constraint con
+			inner join pg_class t on t.oid = con.conrelid
+			inner join pg_attribute att on att.attrelid = con.conrelid and att.attnum = con.conkey[1]
+			inner join pg_class rt on rt.oid = con.confrelid
+		where con.contype = 'f' and t.relname = $1 and t.relnamespace = current_schema()::regnamespace`, table)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresDialect.TableForeignKeys: failed to query foreign keys: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbForeignKey, 0)
+	for rows.Next() {
+		fk := &DbForeignKey{}
+		var delType string
+		if err := rows.Scan(&fk.Name, &fk.Column, &fk.RefTable, &delType); err != nil {
+			return nil, fmt.Errorf("PostgresDialect.TableForeignKeys: failed to scan foreign key: %w", err)
+		}
+		switch delType {
+		case "c":
+			fk.OnDelete = ForeignKeyCascade
+		case "n":
+			fk.OnDelete = ForeignKeySetNull
+		default:
+			fk.OnDelete = ForeignKeyRestrict
+		}
+		res = append(res, fk)
+	}
+	return res, rows.Err()
+}
+
+func (T PostgresDialect) AddForeignKeySql(table string, fk *DbForeignKey) string {
+	return fmt.Sprintf("alter table %s add constraint %s foreign key (%s) references %s (ref) on delete %s",
+		table, fk.Name, fk.Column, fk.RefTable, onDeleteSql(fk.OnDelete))
+}
+
+func (T PostgresDialect) DropForeignKeySql(table string, fk *DbForeignKey) string {
+	return fmt.Sprintf("alter table %s drop constraint %s", table, fk.Name)
+}
+
+func (T PostgresDialect) TableIndexes(q Querier, table string) ([]*DbIndex, error) {
+	rows, err := q.Query(`
+		select
+			i.relname as iname,
+			ix.indisunique as uni,
+			a.attname as cname
+		from
+			pg_class t, pg_class i, pg_index ix, pg_attribute a
+		where
+			t.oid = ix.indrelid
+			and i.oid = ix.indexrelid
+			and a.attrelid = t.oid
+			and t.relkind = 'r'
+			and a.attnum = ANY(ix.indkey)
+			and t.relname like $1
+			and ix.indisprimary=false
+		order by
+			i.relname,
+			array_position(ix.indkey, a.attnum)
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("PostgresDialect.TableIndexes: failed to query existing indexes: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbIndex, 0)
+	for rows.Next() {
+		var iname, cname string
+		var uni bool
+		if err := rows.Scan(&iname, &uni, &cname); err != nil {
+			return nil, fmt.Errorf("PostgresDialect.TableIndexes: failed to scan index row: %w", err)
+		}
+		if len(iname) == 0 || len(cname) == 0 {
+			continue
+		}
+		res = appendIndexColumn(res, iname, uni, cname)
+	}
+	return res, nil
+}
+
+func (T PostgresDialect) DropIndexSql(table string, index string) string {
+	return fmt.Sprintf("drop index %s", index)
+}
@@ -0,0 +1,276 @@
+package elorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SQLiteDialect implements Dialect for SQLite.
+type SQLiteDialect struct {
+	Driver string // database/sql driver name, "sqlite" if empty
+}
+
+func (T SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+func (T SQLiteDialect) DriverName() string {
+	if T.Driver != "" {
+		return T.Driver
+	}
+	return "sqlite"
+}
+
+func (T SQLiteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (T SQLiteDialect) SingleWriter() bool {
+	return true
+}
+
+func (T SQLiteDialect) TransactionalDDL() bool {
+	return true
+}
+
+func (T SQLiteDialect) SavepointSql(name string) string {
+	return fmt.Sprintf("savepoint %s", name)
+}
+
+func (T SQLiteDialect) ReleaseSavepointSql(name string) string {
+	return fmt.Sprintf("release savepoint %s", name)
+}
+
+func (T SQLiteDialect) RollbackToSavepointSql(name string) string {
+	return fmt.Sprintf("rollback to savepoint %s", name)
+}
+
+func (T SQLiteDialect) ColumnType(fd *FieldDef) (string, error) {
+	switch fd.Type {
+	case FieldDefTypeString:
+		return fmt.Sprintf("varchar(%d)", fd.Len), nil
+	case FieldDefTypeInt:
+		return "integer", nil
+	case FieldDefTypeBool:
+		return "boolean", nil
+	case FieldDefTypeRef:
+		return fmt.Sprintf("varchar(%d)", refFieldLength), nil
+	case FieldDefTypeDateTime:
+		return "datetime", nil
+	case FieldDefTypeNumeric:
+		return fmt.Sprintf("decimal(%d,%d)", fd.Precision, fd.Scale), nil
+	default:
+		return "", fmt.Errorf("SQLiteDialect.ColumnType: unknown field type: %d", fd.Type)
+	}
+}
+
+func (T SQLiteDialect) BoolLiteral(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// LikeClause for SQLite relies on pattern lowered in Go: built-in LIKE and LOWER fold ASCII letters only, so non-ASCII
+// letters of pattern match column values in lower case only
+func (T SQLiteDialect) LikeClause(column string, placeholder string) string {
+	return fmt.Sprintf("LOWER(%s) LIKE %s", column, placeholder)
+}
+
+func (T SQLiteDialect) PagingClause(limit int, offset int) string {
+	return fmt.Sprintf(" limit %d offset %d", limit, offset)
+}
+
+func (T SQLiteDialect) RefTypeSql(q Querier) ([]string, error) {
+	return nil, nil
+}
+
+func (T SQLiteDialect) CreateTableSql(table string) string {
+	return fmt.Sprintf("create table if not exists %s (ref varchar(%d) primary key)", table, refFieldLength)
+}
+
+func (T SQLiteDialect) TableColumns(q Querier, table string) ([]*DbColumn, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteDialect.TableColumns: failed to query columns: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res := make([]*DbColumn, 0)
+	for rows.Next() {
+		var cid int
+		var name, ctype string
+		var notnull, pk int
+		var dfltValue any
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
+			return nil, fmt.Errorf("SQLiteDialect.TableColumns: failed to scan column: %w", err)
+		}
+		res = append(res, &DbColumn{Name: name, Type: ctype})
+	}
+	return res, rows.Err()
+}
+
+func (T SQLiteDialect) RenameTableSql(table string, newName string) string {
+	return fmt.Sprintf("alter table %s rename to %s", table, newName)
+}
+
+func (T SQLiteDialect) RenameColumnSql(table string, column string, newName string) string {
+	return fmt.Sprintf("alter table %s rename column %s to %s", table, column, newName)
+}
+
+func (T SQLiteDialect) AddColumnSql(table string, column string, colType string) string {
+	return fmt.Sprintf("alter table %s add column %s %s", table, column, colType)
+}
+
+// AlterColumnTypeSql returns empty string, SQLite can't change column type, so table is rebuilt
+func (T SQLiteDialect) AlterColumnTypeSql(table string, column string, colType string) string {
+	return ""
+}
+
+// DropColumnSql returns empty string, SQLite can't drop indexed columns, so table is rebuilt
+func (T SQLiteDialect) DropColumnSql(table string, column string) string {
+	return ""
+}
+
+// RebuildTableSql implements the standard SQLite table rebuild: create new table, copy data, drop old table, rename new one
+func (T SQLiteDialect) RebuildTableSql(table string, columns []*DbColumn, foreignKeys []*DbForeignKey, copyColumns []string) []string {
+	tmp := table + "__elorm_new"
+	if len(copyColumns) == 0 {
+		tmp = table
+	}
+	defs := make([]string, 0, len(columns)+len(foreignKeys))
+	for i, c := range columns {
+		if i == 0 {
+			defs = append(defs, fmt.Sprintf("%s %s primary key", c.Name, c.Type))
+		} else {
+			defs = append(defs, fmt.Sprintf("%s %s", c.Name, c.Type))
+		}
+	}
+	for _, fk := range foreignKeys {
+		defs = append(defs, fmt.Sprintf("constraint %s foreign key (%s) references %s (ref) on delete %s",
+			fk.Name, fk.Column, fk.RefTable, onDeleteSql(fk.OnDelete)))
+	}
+	res := []string{fmt.Sprintf("create table %s (%s)", tmp, strings.Join(defs, ", "))}
+	if len(copyColumns) == 0 {
+		return res
+	}
+	copyList := strings.Join(copyColumns, ", ")
+	return append(res,
+		fmt.Sprintf("insert into %s (%s) select %s from %s", tmp, copyList, copyList, table),
+		fmt.Sprintf("drop table %s", table),
+		fmt.Sprintf("alter table %s rename to %s", tmp, table),
+	)
+}
+
+// PrepareSchemaConn disables foreign keys (when enabled) for the connection, otherwise dropping the old table during rebuild
+// would delete or nullify referencing rows. Foreign keys can't be switched inside transaction, so it is done before it starts.
+func (T SQLiteDialect) PrepareSchemaConn(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	var enabled int
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&enabled); err != nil {
+		return nil, fmt.Errorf("SQLiteDialect.PrepareSchemaConn: failed to check foreign keys: %w", err)
+	}
+	if enabled == 0 {
+		return noSchemaConnRestore, nil
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return nil, fmt.Errorf("SQLiteDialect.PrepareSchemaConn: failed to disable foreign keys: %w", err)
+	}
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+		return err
+	}, nil
+}
+
+// TableForeignKeys returns foreign keys with generated names, SQLite doesn't report constraint names
+func (T SQLiteDialect) TableForeignKeys(q Querier, table string) ([]*DbForeignKey, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA foreign_key_list(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteDialect.TableForeignKeys: failed to query foreign keys: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	fake := new(any)
+	res := make([]*DbForeignKey, 0)
+	for rows.Next() {
+		fk := &DbForeignKey{}
+		var onDelete string
+		if err := rows.Scan(fake, fake, &fk.RefTable, &fk.Column, fake, fake, &onDelete, fake); err != nil {
+			return nil, fmt.Errorf("SQLiteDialect.TableForeignKeys: failed to scan foreign key: %w", err)
+		}
+		fk.RefTable = strings.ToLower(fk.RefTable)
+		fk.Name = foreignKeyName(table, fk.Column)
+		fk.OnDelete = onDeleteMode(onDelete)
+		res = append(res, fk)
+	}
+	return res, rows.Err()
+}
+
+// AddForeignKeySql returns empty string, SQLite can't add constraints to existing table, so table is rebuilt
+func (T SQLiteDialect) AddForeignKeySql(table string, fk *DbForeignKey) string {
+	return ""
+}
+
+// DropForeignKeySql returns empty string, SQLite can't drop constraints, so table is rebuilt
+func (T SQLiteDialect) DropForeignKeySql(table string, fk *DbForeignKey) string {
+	return ""
+}
+
+func (T SQLiteDialect) TableIndexes(q Querier, table string) ([]*DbIndex, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA index_list(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteDialect.TableIndexes: failed to query existing indexes: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	fake := new(any)
+
+	type listItem struct {
+		name   string
+		unique bool
+	}
+	list := make([]listItem, 0)
+	for rows.Next() {
+		var iname string
+		var unique int
+		var creationmode string
+		if err := rows.Scan(fake, &iname, &unique, &creationmode, fake); err != nil {
+			return nil, fmt.Errorf("SQLiteDialect.TableIndexes: failed to scan index row: %w", err)
+		}
+		if len(iname) == 0 || creationmode != "c" {
+			continue
+		}
+		list = append(list, listItem{name: iname, unique: unique == 1})
+	}
+	_ = rows.Close()
+
+	res := make([]*DbIndex, 0, len(list))
+	for _, li := range list {
+		indexRows, err := q.Query(fmt.Sprintf("PRAGMA index_info(%s)", li.name))
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteDialect.TableIndexes: failed to query index info: %w", err)
+		}
+		columns := make([]string, 0)
+		for indexRows.Next() {
+			var seqno int
+			var cname string
+			if err := indexRows.Scan(&seqno, fake, &cname); err != nil {
+				_ = indexRows.Close()
+				return nil, fmt.Errorf("SQLiteDialect.TableIndexes: failed to scan index info row: %w", err)
+			}
+			columns = append(columns, cname)
+		}
+		_ = indexRows.Close()
+		res = append(res, &DbIndex{Name: li.name, Unique: li.unique, Columns: columns})
+	}
+	return res, nil
+}
+
+func (T SQLiteDialect) DropIndexSql(table string, index string) string {
+	return fmt.Sprintf("drop index %s", index)
+}
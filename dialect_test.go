@@ -0,0 +1,159 @@
+package elorm
+
+import (
+	"testing"
+)
+
+type customTestDialect struct {
+	SQLiteDialect
+}
+
+func (T customTestDialect) Name() string {
+	return "custom"
+}
+
+func TestDialectByName(t *testing.T) {
+	for _, name := range []string{"postgres", "mssql", "mysql", "sqlite", "SQLite3"} {
+		if _, err := DialectByName(name); err != nil {
+			t.Errorf("DialectByName(%s) error = %v", name, err)
+		}
+	}
+	if _, err := DialectByName("unknown"); err == nil {
+		t.Error("Expected error for unknown dialect")
+	}
+
+	err := RegisterDialect("custom", customTestDialect{})
+	if err != nil {
+		t.Fatalf("RegisterDialect() error = %v", err)
+	}
+	d, err := DialectByName("custom")
+	if err != nil {
+		t.Fatalf("DialectByName(custom) error = %v", err)
+	}
+	if d.Name() != "custom" || d.DriverName() != "sqlite" {
+		t.Errorf("unexpected custom dialect %s/%s", d.Name(), d.DriverName())
+	}
+}
+
+func TestFactory_PrepareSql(t *testing.T) {
+	args := make([]any, 12)
+	query := "select * from t where a=$1 and b=$2 and c in ($10, $11, $12)"
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{dialect: PostgresDialect{}, want: query},
+		{dialect: MSSQLDialect{}, want: query},
+		{dialect: MySQLDialect{}, want: "select * from t where a=? and b=? and c in (?, ?, ?)"},
+		{dialect: SQLiteDialect{}, want: "select * from t where a=? and b=? and c in (?, ?, ?)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			f := &Factory{dialect: tt.dialect}
+			if got := f.PrepareSql(query, args...); got != tt.want {
+				t.Errorf("PrepareSql() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_SavepointSql(t *testing.T) {
+	tests := []struct {
+		dialect                   Dialect
+		savepoint, release, rollb string
+	}{
+		{dialect: PostgresDialect{}, savepoint: "savepoint sp1", release: "release savepoint sp1", rollb: "rollback to savepoint sp1"},
+		{dialect: MSSQLDialect{}, savepoint: "save transaction sp1", release: "", rollb: "rollback transaction sp1"},
+		{dialect: MySQLDialect{}, savepoint: "savepoint sp1", release: "release savepoint sp1", rollb: "rollback to savepoint sp1"},
+		{dialect: SQLiteDialect{}, savepoint: "savepoint sp1", release: "release savepoint sp1", rollb: "rollback to savepoint sp1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			if got := tt.dialect.SavepointSql("sp1"); got != tt.savepoint {
+				t.Errorf("SavepointSql() = %s, want %s", got, tt.savepoint)
+			}
+			if got := tt.dialect.ReleaseSavepointSql("sp1"); got != tt.release {
+				t.Errorf("ReleaseSavepointSql() = %s, want %s", got, tt.release)
+			}
+			if got := tt.dialect.RollbackToSavepointSql("sp1"); got != tt.rollb {
+				t.Errorf("RollbackToSavepointSql() = %s, want %s", got, tt.rollb)
+			}
+		})
+	}
+}
+
+func TestDialect_ColumnChangesSql(t *testing.T) {
+	tests := []struct {
+		dialect     Dialect
+		alter, drop string
+		rebuild     bool
+	}{
+		{dialect: PostgresDialect{}, alter: "alter table t alter column c type varchar(5)", drop: "alter table t drop column c"},
+		{dialect: MSSQLDialect{}, alter: "alter table t alter column c varchar(5)", drop: "alter table t drop column c"},
+		{dialect: MySQLDialect{}, alter: "alter table t modify column c varchar(5)", drop: "alter table t drop column c"},
+		{dialect: SQLiteDialect{}, alter: "", drop: "", rebuild: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			if got := tt.dialect.AlterColumnTypeSql("t", "c", "varchar(5)"); got != tt.alter {
+				t.Errorf("AlterColumnTypeSql() = %s, want %s", got, tt.alter)
+			}
+			if got := tt.dialect.DropColumnSql("t", "c"); got != tt.drop {
+				t.Errorf("DropColumnSql() = %s, want %s", got, tt.drop)
+			}
+			stmts := tt.dialect.RebuildTableSql("t", []*DbColumn{{Name: "ref", Type: "varchar(107)"}}, nil, []string{"ref"})
+			if (len(stmts) > 0) != tt.rebuild {
+				t.Errorf("RebuildTableSql() = %v, want rebuild %v", stmts, tt.rebuild)
+			}
+		})
+	}
+}
+
+func TestDialect_ForeignKeySql(t *testing.T) {
+	fk := &DbForeignKey{Name: "t_fk_c", Column: "c", RefTable: "rt", OnDelete: ForeignKeyRestrict}
+	tests := []struct {
+		dialect   Dialect
+		add, drop string
+	}{
+		{dialect: PostgresDialect{}, add: "alter table t add constraint t_fk_c foreign key (c) references rt (ref) on delete restrict", drop: "alter table t drop constraint t_fk_c"},
+		{dialect: MSSQLDialect{}, add: "alter table t add constraint t_fk_c foreign key (c) references rt (ref) on delete no action", drop: "alter table t drop constraint t_fk_c"},
+		{dialect: MySQLDialect{}, add: "alter table t add constraint t_fk_c foreign key (c) references rt (ref) on delete restrict", drop: "alter table t drop foreign key t_fk_c"},
+		{dialect: SQLiteDialect{}, add: "", drop: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			if got := tt.dialect.AddForeignKeySql("t", fk); got != tt.add {
+				t.Errorf("AddForeignKeySql() = %s, want %s", got, tt.add)
+			}
+			if got := tt.dialect.DropForeignKeySql("t", fk); got != tt.drop {
+				t.Errorf("DropForeignKeySql() = %s, want %s", got, tt.drop)
+			}
+		})
+	}
+
+	stmts := SQLiteDialect{}.RebuildTableSql("t", []*DbColumn{{Name: "ref", Type: "varchar(107)"}, {Name: "c", Type: "varchar(107)"}},
+		[]*DbForeignKey{{Name: "t_fk_c", Column: "c", RefTable: "rt", OnDelete: ForeignKeySetNull}}, nil)
+	want := "create table t (ref varchar(107) primary key, c varchar(107), constraint t_fk_c foreign key (c) references rt (ref) on delete set null)"
+	if len(stmts) != 1 || stmts[0] != want {
+		t.Errorf("RebuildTableSql() = %v, want %s", stmts, want)
+	}
+}
+
+func TestDialect_LikeClause(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{dialect: PostgresDialect{}, want: "caption ILIKE $1"},
+		{dialect: MSSQLDialect{}, want: "LOWER(caption) LIKE $1"},
+		{dialect: MySQLDialect{}, want: "LOWER(caption) LIKE $1"},
+		{dialect: SQLiteDialect{}, want: "LOWER(caption) LIKE $1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			if got := tt.dialect.LikeClause("caption", "$1"); got != tt.want {
+				t.Errorf("LikeClause() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -31,6 +32,12 @@ type Entity struct {
 	dataVersion *FieldValueString
 
 	isNew bool
+
+	collections     map[*CollectionDef][]*Entity // loaded inverse navigation collections
+	collectionsLock sync.Mutex
+	tableParts      map[string]*TablePart
+	links           map[string]*ManyToMany
+	partial         map[*FieldDef]bool // loaded fields of partially loaded entity (see SelectPartial), nil for full entity
 }
 
 // Def returns the entity definition for this entity.
@@ -38,6 +45,11 @@ func (T *Entity) Def() *EntityDef {
 	return T.entityDef
 }
 
+// baseEntity returns the entity itself, also for wrapper structs embedding *Entity
+func (T *Entity) baseEntity() *Entity {
+	return T
+}
+
 // GetValues returns a map of all field values for this entity.
 func (T *Entity) GetValues() map[string]IFieldValue {
 	return T.Values
@@ -53,6 +65,11 @@ func (T *Entity) IsNew() bool {
 	return T.isNew
 }
 
+// IsPartial returns true if only some fields of this entity are loaded (see EntityDef.SelectPartial).
+func (T *Entity) IsPartial() bool {
+	return T.partial != nil
+}
+
 // IsDeleted returns true if this entity is marked for deletion.
 func (T *Entity) IsDeleted() bool {
 	return T.isDeleted.Get()
@@ -68,10 +85,20 @@ func (T *Entity) DataVersion() string {
 	return T.dataVersion.Get()
 }
 
+// isChanged returns true when any field value differs from the loaded or saved one
+func (T *Entity) isChanged() bool {
+	for _, v := range T.Values {
+		if v.isChanged() {
+			return true
+		}
+	}
+	return false
+}
+
 // Save persists the Entity to the database. It handles both insert and update operations
 // depending on whether the entity is new or existing. The method performs the following steps:
-//   - Executes the BeforeSaveHandler if defined.
-//   - Begins a database transaction.
+//   - Begins a database transaction (nested into the transaction carried by ctx, if any).
+//   - Executes the BeforeSaveHandler if defined, with context bound to the transaction.
 //   - If the entity is new, inserts a new record into the database.
 //   - If the entity exists, updates the corresponding record, optionally performing
 //     data version checks to prevent concurrent modifications.
@@ -81,8 +108,9 @@ func (T *Entity) DataVersion() string {
 // Returns an error if any step fails, including handler execution, SQL operations,
 // or transaction management.
 func (T *Entity) Save(ctx context.Context) error {
-	var err error
-
+	if T.partial != nil {
+		return fmt.Errorf("Entity.Save: cannot save partially loaded entity %s", T.RefString())
+	}
 	if !T.Def().UseSoftDelete && T.IsDeleted() {
 		return fmt.Errorf("Entity.Save: cannot save entity with IsDeleted=true, UseSoftDelete is false")
 	}
@@ -92,9 +120,24 @@ func (T *Entity) Save(ctx context.Context) error {
 		dvCheck = T.Factory.dataVersionCheckMode
 	}
 
+	afterSaveCtx := ctx
+	wasNew, prevDV := T.isNew, T.DataVersion()
+	ctx, tx, err := T.Factory.BeginTranContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Entity.Save: failed to begin transaction: %w", err)
+	}
+	// failed save or rollback of outer transaction (nested save is committed only with it) restores state of entity,
+	// so it can be saved again, and cache mustn't keep uncommitted values
+	tx.afterRollback(func() {
+		T.Factory.loadedEntities.Remove(T.RefString())
+		T.isNew = wasNew
+		T.dataVersion.Set(prevDV)
+	})
+
 	// before save handlers
 	for _, hndl := range T.entityDef.beforeSaveHandlerByRefs {
 		if err := hndl(ctx, T.RefString()); err != nil {
+			_ = T.Factory.RollbackTran(tx)
 			return fmt.Errorf("Entity.Save: beforeSaveHandlerByRef failed for ref %s: %w", T.ref.AsString(), err)
 		}
 	}
@@ -106,16 +149,19 @@ func (T *Entity) Save(ctx context.Context) error {
 			err = hndl(ctx, T.entityDef.Wrap(T))
 		}
 		if err != nil {
+			_ = T.Factory.RollbackTran(tx)
 			return fmt.Errorf("Entity.Save: beforeSaveHandler failed for ref %s: %w", T.ref.AsString(), err)
 		}
 	}
 
 	tableName, err := T.entityDef.SqlTableName()
 	if err != nil {
+		_ = T.Factory.RollbackTran(tx)
 		return fmt.Errorf("Entity.Save: failed to get SQL table name for entity %s: %w", T.entityDef.ObjectName, err)
 	}
 
 	if T.RefString() == "" {
+		_ = T.Factory.RollbackTran(tx)
 		return fmt.Errorf("Entity.Save: cannot save entity with empty Ref field")
 	}
 
@@ -127,34 +173,31 @@ func (T *Entity) Save(ctx context.Context) error {
 	for _, v := range T.entityDef.FieldDefs {
 		coln, err := v.SqlColumnName()
 		if err != nil {
+			_ = T.Factory.RollbackTran(tx)
 			return fmt.Errorf("Entity.Save: failed to get SQL column name for field %s: %w", v.Name, err)
 		}
 		columnNames[v.Name] = coln
 		fn = append(fn, coln)
 	}
 
-	tx, err := T.Factory.BeginTran()
-	if err != nil {
-		return fmt.Errorf("Entity.Save: failed to begin transaction: %w", err)
-	}
-
 	if T.isNew {
 
 		T.dataVersion.Set(NewRef())
 
 		fv := make([]string, 0, fieldCount)
+		args := make([]any, 0, fieldCount)
 		for _, v := range T.entityDef.FieldDefs {
-			sqlv, err := T.Values[v.Name].SqlStringValue()
+			sqlv, err := T.Values[v.Name].SqlValue()
 			if err != nil {
 				_ = T.Factory.RollbackTran(tx)
 				return fmt.Errorf("Entity.Save: failed to get SQL value for field %s: %w", v.Name, err)
 			}
-			fv = append(fv, sqlv)
+			args = append(args, sqlv)
+			fv = append(fv, fmt.Sprintf("$%d", len(args)))
 		}
 
-		sql := fmt.Sprintf(`insert into %s (%s) values (%s)`,
-			tableName, strings.Join(fn, ", "), strings.Join(fv, ", "))
-		_, err = tx.Exec(sql)
+		_, err = T.Factory.ExecContext(ctx, fmt.Sprintf(`insert into %s (%s) values (%s)`,
+			tableName, strings.Join(fn, ", "), strings.Join(fv, ", ")), args...)
 		if err != nil {
 			_ = T.Factory.RollbackTran(tx)
 			return fmt.Errorf("Entity.Save: failed to insert: %w", err)
@@ -165,50 +208,45 @@ func (T *Entity) Save(ctx context.Context) error {
 		oldDV := T.DataVersion()
 		T.dataVersion.Set(NewRef())
 
-		refsv, err := T.ref.SqlStringValue()
-		if err != nil {
-			_ = T.Factory.RollbackTran(tx)
-			return fmt.Errorf("Entity.Save: failed to get SQL value for Ref field: %w", err)
-		}
 		setlist := make([]string, 0, fieldCount)
+		args := make([]any, 0, fieldCount+2)
 		for _, v := range T.entityDef.FieldDefs {
 			coln := columnNames[v.Name] // Use pre-computed column name
 
-			sv, err := T.Values[v.Name].SqlStringValue()
+			sv, err := T.Values[v.Name].SqlValue()
 			if err != nil {
 				_ = T.Factory.RollbackTran(tx)
 				return fmt.Errorf("Entity.Save: failed to get SQL value for field %s: %w", v.Name, err)
 			}
-			setlist = append(setlist, fmt.Sprintf("%s = %s", coln, sv))
+			args = append(args, sv)
+			setlist = append(setlist, fmt.Sprintf("%s = $%d", coln, len(args)))
 		}
+		args = append(args, T.RefString())
+		refIdx := len(args)
 
 		if dvCheck == DataVersionCheckAlways {
 
-			sql := fmt.Sprintf(`update %s set %s where ref=%s and dataversion='%s'`,
-				tableName, strings.Join(setlist, ", "), refsv, oldDV)
-			res, err := tx.Exec(sql)
+			args = append(args, oldDV)
+			res, err := T.Factory.ExecContext(ctx, fmt.Sprintf(`update %s set %s where ref=$%d and dataversion=$%d`,
+				tableName, strings.Join(setlist, ", "), refIdx, refIdx+1), args...)
 			if err != nil {
-				T.dataVersion.Set(oldDV)
 				_ = T.Factory.RollbackTran(tx)
 				return fmt.Errorf("Entity.Save: failed to update: %w", err)
 			}
 			rowsAffected, err := res.RowsAffected()
 			if err != nil {
-				T.dataVersion.Set(oldDV)
 				_ = T.Factory.RollbackTran(tx)
 				return fmt.Errorf("Entity.Save: failed to get rows affected: %w", err)
 			}
 			if rowsAffected != 1 {
-				T.dataVersion.Set(oldDV)
 				_ = T.Factory.RollbackTran(tx)
-				return fmt.Errorf("Entity.Save: update failed, entity %s was changed by another user", T.RefString())
+				return fmt.Errorf("Entity.Save: update of %s failed: %w", T.RefString(), ErrConcurrentUpdate)
 			}
 
 		} else {
 
-			sql := fmt.Sprintf(`update %s set %s where ref=%s`,
-				tableName, strings.Join(setlist, ", "), refsv)
-			_, err = tx.Exec(sql)
+			_, err = T.Factory.ExecContext(ctx, fmt.Sprintf(`update %s set %s where ref=$%d`,
+				tableName, strings.Join(setlist, ", "), refIdx), args...)
 			if err != nil {
 				_ = T.Factory.RollbackTran(tx)
 				return fmt.Errorf("Entity.Save: failed to update: %w", err)
@@ -216,18 +254,29 @@ func (T *Entity) Save(ctx context.Context) error {
 		}
 	}
 
+	if err = T.saveTableParts(ctx); err != nil {
+		_ = T.Factory.RollbackTran(tx)
+		return fmt.Errorf("Entity.Save: %w", err)
+	}
+	if err = T.saveLinks(ctx); err != nil {
+		_ = T.Factory.RollbackTran(tx)
+		return fmt.Errorf("Entity.Save: %w", err)
+	}
+
 	err = T.Factory.CommitTran(tx)
 	if err != nil {
 		return fmt.Errorf("Entity.Save: failed to commit transaction: %w", err)
 	}
+	T.linksSaved()
 
 	// after save handlers
 	for _, handler := range T.entityDef.afterSaveHandlers {
-		if err := handler(ctx, T.entityDef.Wrap(T)); err != nil {
+		if err := handler(afterSaveCtx, T.entityDef.Wrap(T)); err != nil {
 			return fmt.Errorf("Entity.Save: afterSaveHandler failed for ref %s: %w", T.RefString(), err)
 		}
 	}
 
+	T.resetOwnerCollections()
 	for _, v := range T.Values {
 		v.resetOld()
 	}
@@ -236,7 +285,7 @@ func (T *Entity) Save(ctx context.Context) error {
 	return nil
 }
 
-func (T *Entity) valuesToMap(defs map[*FieldDef]bool) (map[string]any, error) {
+func (T *Entity) valuesToMap(ctx context.Context, defs map[*FieldDef]bool) (map[string]any, error) {
 	vm := make(map[string]any, len(T.Values))
 	for _, v := range T.Values {
 		if len(defs) > 0 {
@@ -244,6 +293,9 @@ func (T *Entity) valuesToMap(defs map[*FieldDef]bool) (map[string]any, error) {
 				continue
 			}
 		}
+		if !T.Factory.canReadField(ctx, v.Def()) {
+			continue
+		}
 
 		switch vt := v.(type) {
 		case *FieldValueString:
@@ -262,7 +314,7 @@ func (T *Entity) valuesToMap(defs map[*FieldDef]bool) (map[string]any, error) {
 					if err != nil {
 						return nil, fmt.Errorf("Entity.MarshalJSON: failed to load entity for (entity type=%s, ref=%s): %w", vt.def.Name, vt.v, err)
 					}
-					vm2, err := entity.valuesToMap(def.AutoExpandFieldsForJSON)
+					vm2, err := entity.valuesToMap(ctx, def.AutoExpandFieldsForJSON)
 					if err != nil {
 						return nil, fmt.Errorf("Entity.MarshalJSON: failed to convert entity to map for ref %s: %w", vt.v, err)
 					}
@@ -279,12 +331,38 @@ func (T *Entity) valuesToMap(defs map[*FieldDef]bool) (map[string]any, error) {
 			return nil, fmt.Errorf("Entity.MarshalJSON: unsupported field type %d for field %s", v.Def().Type, v.Def().Name)
 		}
 	}
+	if len(defs) == 0 {
+		if err := T.tablePartsToMap(ctx, vm); err != nil {
+			return nil, fmt.Errorf("Entity.MarshalJSON: %w", err)
+		}
+		for cd := range T.entityDef.AutoExpandCollectionsForJSON {
+			items, err := T.Collection(ctx, cd.Name)
+			if err != nil {
+				return nil, fmt.Errorf("Entity.MarshalJSON: failed to load collection %s for ref %s: %w", cd.Name, T.RefString(), err)
+			}
+			list := make([]map[string]any, 0, len(items))
+			for _, item := range items {
+				im, err := item.valuesToMap(ctx, nil)
+				if err != nil {
+					return nil, fmt.Errorf("Entity.MarshalJSON: failed to convert collection item to map for ref %s: %w", item.RefString(), err)
+				}
+				list = append(list, im)
+			}
+			vm[cd.Name] = list
+		}
+	}
 	return vm, nil
 }
 
 // MarshalJSON implements json.Marshaler interface for JSON serialization.
 func (T *Entity) MarshalJSON() ([]byte, error) {
-	vm, err := T.valuesToMap(nil)
+	return T.MarshalJSONContext(context.Background())
+}
+
+// MarshalJSONContext serializes entity to JSON like MarshalJSON, with access to fields decided for ctx
+// (see Factory.FieldAccessPolicy).
+func (T *Entity) MarshalJSONContext(ctx context.Context) ([]byte, error) {
+	vm, err := T.valuesToMap(ctx, T.partial)
 	if err != nil {
 		return nil, fmt.Errorf("Entity.MarshalJSON: failed to convert values to map: %w", err)
 	}
@@ -293,6 +371,12 @@ func (T *Entity) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON implements json.Unmarshaler interface for JSON deserialization.
 func (T *Entity) UnmarshalJSON(b []byte) error {
+	return T.UnmarshalJSONContext(context.Background(), b)
+}
+
+// UnmarshalJSONContext deserializes entity from JSON like UnmarshalJSON, with access to fields decided for ctx
+// (see Factory.FieldAccessPolicy). Fields which aren't writable are ignored.
+func (T *Entity) UnmarshalJSONContext(ctx context.Context, b []byte) error {
 
 	oldRef := T.RefString()
 
@@ -303,78 +387,9 @@ func (T *Entity) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("Entity.UnmarshalJSON: failed to unmarshal JSON: %w", err)
 	}
 	for _, v := range T.Values {
-		if val, ok := vm[v.Def().Name]; ok {
-			switch v.Def().Type {
-			case FieldDefTypeString:
-				v.(*FieldValueString).Set(val.(string))
-			case FieldDefTypeInt:
-				switch val.(type) {
-				case int:
-					v.(*FieldValueInt).Set(int64(val.(int)))
-				case int64:
-					v.(*FieldValueInt).Set(val.(int64))
-				case float64:
-					v.(*FieldValueInt).Set(int64(val.(float64)))
-				case string:
-					valInt, err := strconv.ParseInt(strings.TrimSpace(val.(string)), 10, 64)
-					if err != nil {
-						return fmt.Errorf("Entity.LoadFromJSON: failed to parse integer value for field %s: %w", v.Def().Name, err)
-					}
-					v.(*FieldValueInt).Set(valInt)
-				default:
-					return fmt.Errorf("Entity.LoadFromJSON: unexpected type for integer field %s: %T", v.Def().Name, val)
-				}
-			case FieldDefTypeBool:
-				switch val.(type) {
-				case bool:
-					v.(*FieldValueBool).Set(val.(bool))
-				case string:
-					asStr := strings.ToLower(val.(string))
-					v.(*FieldValueBool).Set(asStr == "true" || asStr == "1" || asStr == "yes" || asStr == "on")
-				default:
-					return fmt.Errorf("Entity.LoadFromJSON: unexpected type for boolean field %s: %T", v.Def().Name, val)
-				}
-			case FieldDefTypeRef:
-				stringVal := ""
-				switch vt := val.(type) {
-				case string:
-					stringVal = vt
-				case map[string]any:
-					if ref, ok := vt[RefFieldName]; ok {
-						stringVal, ok = ref.(string)
-						if !ok {
-							return fmt.Errorf("Entity.LoadFromJSON: expected string for reference field %s, got %T", v.Def().Name, ref)
-						}
-					} else {
-						return fmt.Errorf("Entity.LoadFromJSON: missing reference field %s in map", RefFieldName)
-					}
-				}
-				err = v.(*FieldValueRef).Set(stringVal)
-				if err != nil {
-					return fmt.Errorf("Entity.LoadFromJSON: failed to set reference field %s: %w", v.Def().Name, err)
-				}
-			case FieldDefTypeDateTime:
-				strVal := strings.TrimSpace(val.(string))
-				tv, err := time.Parse(v.Def().DateTimeJSONFormat, strVal)
-				if err != nil {
-					return fmt.Errorf("Entity.LoadFromJSON: failed to parse date time: %w", err)
-				}
-				v.(*FieldValueDateTime).Set(tv)
-			case FieldDefTypeNumeric:
-				switch val.(type) {
-				case float32:
-					v.(*FieldValueNumeric).Set(float64(val.(float32)))
-				case float64:
-					v.(*FieldValueNumeric).Set(val.(float64))
-				case string:
-					valFloat, err := strconv.ParseFloat(strings.TrimSpace(val.(string)), 64)
-					if err != nil {
-						return fmt.Errorf("Entity.LoadFromJSON: failed to parse numeric value for field %s: %w", v.Def().Name, err)
-					}
-					v.(*FieldValueNumeric).Set(valFloat)
-				default:
-					return fmt.Errorf("Entity.LoadFromJSON: unexpected type for numeric field %s: %T", v.Def().Name, val)
-				}
+		if val, ok := vm[v.Def().Name]; ok && T.Factory.canWriteField(ctx, v.Def()) {
+			if err = setFieldValueFromJSON(v, val); err != nil {
+				return fmt.Errorf("Entity.UnmarshalJSON: %w: %w", ErrValidation, err)
 			}
 		}
 	}
@@ -386,11 +401,104 @@ func (T *Entity) UnmarshalJSON(b []byte) error {
 	existsCopy, _ := T.Factory.LoadEntity(T.RefString())
 	T.isNew = existsCopy == nil
 
+	if err = T.tablePartsFromMap(ctx, vm); err != nil {
+		return fmt.Errorf("Entity.UnmarshalJSON: %w: %w", ErrValidation, err)
+	}
 	return nil
 }
 
-// LoadFrom copies field values from another entity into this entity.
+// setFieldValueFromJSON sets field value from value decoded from JSON
+func setFieldValueFromJSON(v IFieldValue, val any) error {
+	switch v.Def().Type {
+	case FieldDefTypeString:
+		strVal, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("Entity.LoadFromJSON: unexpected type for string field %s: %T", v.Def().Name, val)
+		}
+		v.(*FieldValueString).Set(strVal)
+	case FieldDefTypeInt:
+		switch val.(type) {
+		case int:
+			v.(*FieldValueInt).Set(int64(val.(int)))
+		case int64:
+			v.(*FieldValueInt).Set(val.(int64))
+		case float64:
+			v.(*FieldValueInt).Set(int64(val.(float64)))
+		case string:
+			valInt, err := strconv.ParseInt(strings.TrimSpace(val.(string)), 10, 64)
+			if err != nil {
+				return fmt.Errorf("Entity.LoadFromJSON: failed to parse integer value for field %s: %w", v.Def().Name, err)
+			}
+			v.(*FieldValueInt).Set(valInt)
+		default:
+			return fmt.Errorf("Entity.LoadFromJSON: unexpected type for integer field %s: %T", v.Def().Name, val)
+		}
+	case FieldDefTypeBool:
+		switch val.(type) {
+		case bool:
+			v.(*FieldValueBool).Set(val.(bool))
+		case string:
+			asStr := strings.ToLower(val.(string))
+			v.(*FieldValueBool).Set(asStr == "true" || asStr == "1" || asStr == "yes" || asStr == "on")
+		default:
+			return fmt.Errorf("Entity.LoadFromJSON: unexpected type for boolean field %s: %T", v.Def().Name, val)
+		}
+	case FieldDefTypeRef:
+		stringVal := ""
+		switch vt := val.(type) {
+		case string:
+			stringVal = vt
+		case map[string]any:
+			if ref, ok := vt[RefFieldName]; ok {
+				stringVal, ok = ref.(string)
+				if !ok {
+					return fmt.Errorf("Entity.LoadFromJSON: expected string for reference field %s, got %T", v.Def().Name, ref)
+				}
+			} else {
+				return fmt.Errorf("Entity.LoadFromJSON: missing reference field %s in map", RefFieldName)
+			}
+		}
+		err := v.(*FieldValueRef).Set(stringVal)
+		if err != nil {
+			return fmt.Errorf("Entity.LoadFromJSON: failed to set reference field %s: %w", v.Def().Name, err)
+		}
+	case FieldDefTypeDateTime:
+		strVal, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("Entity.LoadFromJSON: unexpected type for date time field %s: %T", v.Def().Name, val)
+		}
+		tv, err := time.Parse(v.Def().DateTimeJSONFormat, strings.TrimSpace(strVal))
+		if err != nil {
+			return fmt.Errorf("Entity.LoadFromJSON: failed to parse date time: %w", err)
+		}
+		v.(*FieldValueDateTime).Set(tv)
+	case FieldDefTypeNumeric:
+		switch val.(type) {
+		case float32:
+			v.(*FieldValueNumeric).Set(float64(val.(float32)))
+		case float64:
+			v.(*FieldValueNumeric).Set(val.(float64))
+		case string:
+			valFloat, err := strconv.ParseFloat(strings.TrimSpace(val.(string)), 64)
+			if err != nil {
+				return fmt.Errorf("Entity.LoadFromJSON: failed to parse numeric value for field %s: %w", v.Def().Name, err)
+			}
+			v.(*FieldValueNumeric).Set(valFloat)
+		default:
+			return fmt.Errorf("Entity.LoadFromJSON: unexpected type for numeric field %s: %T", v.Def().Name, val)
+		}
+	}
+	return nil
+}
+
+// LoadFrom copies field values from another entity into this entity. Without predefinedFields Ref, DataVersion and
+// fields which aren't writable in JSON by FieldDef.Access are kept. Factory.FieldAccessPolicy isn't called here.
 func (T *Entity) LoadFrom(src IEntity, predefinedFields bool) error {
+	return T.loadFrom(src, predefinedFields, func(fd *FieldDef) bool { return isWritableAccess(fd.Access) })
+}
+
+// loadFrom is LoadFrom with writable fields decided by canWrite, e.g. by access policy for REST request
+func (T *Entity) loadFrom(src IEntity, predefinedFields bool, canWrite func(fd *FieldDef) bool) error {
 	if src == nil {
 		return fmt.Errorf("Entity.LoadFrom: source entity is nil")
 	}
@@ -400,10 +508,17 @@ func (T *Entity) LoadFrom(src IEntity, predefinedFields bool) error {
 	}
 
 	vals := src.GetValues()
+	var partial map[*FieldDef]bool
+	if b, ok := src.(interface{ baseEntity() *Entity }); ok {
+		partial = b.baseEntity().partial
+	}
 
 	for idx, v := range vals {
 
-		if !predefinedFields && (v.Def().Name == RefFieldName || v.Def().Name == DataVersionFieldName) {
+		if !predefinedFields && (v.Def().Name == RefFieldName || v.Def().Name == DataVersionFieldName || !canWrite(v.Def())) {
+			continue
+		}
+		if partial != nil && !partial[v.Def()] { // fields not loaded by SelectPartial are kept
 			continue
 		}
 		switch ft := T.Values[idx].(type) {
@@ -423,5 +538,16 @@ func (T *Entity) LoadFrom(src IEntity, predefinedFields bool) error {
 		}
 	}
 
+	// changed table parts are moved, e.g. from entity decoded from JSON request
+	if srcEntity, ok := src.(interface{ baseEntity() *Entity }); ok {
+		for name, tp := range srcEntity.baseEntity().tableParts {
+			if tp.changed {
+				T.tableParts[name].rows = tp.rows
+				T.tableParts[name].loaded = true
+				T.tableParts[name].changed = true
+			}
+		}
+	}
+
 	return nil
 }
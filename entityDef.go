@@ -3,6 +3,7 @@ package elorm
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -32,6 +33,7 @@ type EntityDef struct {
 	DataVersionCheckMode    int                      // DataVersionCheckNever, DataVersionCheckDefault, DataVersionCheckAlways
 	ObjectName              string                   // name of the object, elorm-gen created strongly typed structs based on this name
 	TableName               string                   // SQL table name, if empty, it will be generated from ObjectName
+	RenamedFrom             []string                 // previous table names, existing table with such name is renamed instead of creating a new one
 	Fragments               []string                 // fragments are used to define reusable parts of entity definitions
 	FieldDefs               []*FieldDef              // defined fields. All predefined fields (Ref, IsDeleted, DataVersion) are automatically added to this list.
 	IndexDefs               []*IndexDef              // defined indexes. PK doesn't need to be defined here, it is always created automatically
@@ -40,6 +42,13 @@ type EntityDef struct {
 	DataVersionField        *FieldDef                // field for data versioning
 	Wrap                    func(source *Entity) any // optional function to wrap the entity type into custom struct (used by elorm-gen)
 	AutoExpandFieldsForJSON map[*FieldDef]bool       // if specified, these fields will be automatically expanded when serializing to JSON
+	CollectionDefs          []*CollectionDef         // inverse navigation collections, see AddCollectionDef
+	TablePartDefs           []*TablePartDef          // owned table parts, see AddTablePartDef
+	TablePartOf             *TablePartDef            // for table part rows definitions, table part they belong to
+	ManyToManyDefs          []*ManyToManyDef         // many-to-many relations, see AddManyToMany
+
+	// AutoExpandCollectionsForJSON lists collections which are serialized to JSON as nested arrays of items
+	AutoExpandCollectionsForJSON map[*CollectionDef]bool
 
 	// UseSoftDelete=true leads to:
 	// 1) SeletecEntities() includes IsDeleted=false filter always unless developer specified it explicitly
@@ -163,278 +172,166 @@ func (T *EntityDef) FieldDefByName(name string) *FieldDef {
 
 // SqlTableName returns the SQL table name for this entity definition.
 func (T *EntityDef) SqlTableName() (string, error) {
-	switch T.Factory.dbDialect {
-	case DbDialectPostgres, DbDialectMSSQL, DbDialectMySQL, DbDialectSQLite:
-		return strings.ToLower(T.TableName), nil
-	default:
-		return "", fmt.Errorf("EntityDef.SqlTableName: Unknown database type %d", T.Factory.dbDialect)
+	if T.TableName == "" {
+		return "", fmt.Errorf("EntityDef.SqlTableName: TableName is empty for %s", T.ObjectName)
 	}
+	return strings.ToLower(T.TableName), nil
 }
 
-func (T *EntityDef) ensureDBStructure() error {
-
-	switch T.Factory.dbDialect {
-	case DbDialectPostgres:
-		err := T.ensureDBStructurePostgres()
-		if err != nil {
-			return fmt.Errorf("EntityDef.ensureDBStructure: failed to ensure DB structure for Postgres: %w", err)
-		}
-	case DbDialectMSSQL:
-		err := T.ensureDBStructureMSSQL()
-		if err != nil {
-			return fmt.Errorf("EntityDef.ensureDBStructure: failed to ensure DB structure for MSSQL: %w", err)
-		}
-	case DbDialectMySQL:
-		err := T.ensureDBStructureMySQL()
-		if err != nil {
-			return fmt.Errorf("EntityDef.ensureDBStructure: failed to ensure DB structure for MySQL: %w", err)
-		}
-	case DbDialectSQLite:
-		err := T.ensureDBStructureSQLite()
-		if err != nil {
-			return fmt.Errorf("EntityDef.ensureDBStructure: failed to ensure DB structure for SQLite: %w", err)
-		}
-	default:
-		return fmt.Errorf("unknown database type %d", T.Factory.dbDialect)
-	}
-
-	err := T.ensureDatabaseIndexes()
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructure: failed to ensure DB structure: %w", err)
-	}
-
-	return nil
-}
-
-func (T *EntityDef) ensureDBStructurePostgres() error {
-	tran, err := T.Factory.BeginTran()
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to begin transaction: %w", err)
-	}
+// planDBStructure adds changes of the entity table to plan. Foreign keys are added to foreignKeyChanges, they are applied
+// after all tables are created.
+func (T *EntityDef) planDBStructure(plan *SchemaPlan, foreignKeyChanges *SchemaPlan) error {
+	dialect := T.Factory.dialect
 
 	tn, err := T.SqlTableName()
 	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to get SQL table name: %w", err)
-	}
-
-	_, err = tran.Exec(fmt.Sprintf("create table if not exists %s()", tn))
-	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to create table: %w", err)
-	}
-
-	for _, v := range T.FieldDefs {
-		colType, err := v.SqlColumnType()
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to get SQL column type for field %s: %w", v.Name, err)
-		}
-
-		coln, err := v.SqlColumnName()
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to get SQL column name for field %s: %w", v.Name, err)
-		}
-
-		_, err = tran.Exec(fmt.Sprintf("alter table %s add column if not exists %s %s", tn, coln, colType))
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to add column %s: %w", coln, err)
-		}
-
-		_, err = tran.Exec(fmt.Sprintf("alter table %s alter column %s type %s", tn, coln, colType))
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to alter column %s: %w", coln, err)
-		}
+		return fmt.Errorf("EntityDef.planDBStructure: failed to get SQL table name: %w", err)
 	}
 
-	var cnt int
-	row := tran.QueryRow("select count(*) as chk from information_schema.constraint_column_usage where table_name=$1 and constraint_name=$2", tn, tn+"_pk")
-
-	err = row.Scan(&cnt)
+	existing, err := dialect.TableColumns(T.Factory, tn)
 	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to scan constraint count: %w", err)
+		return fmt.Errorf("EntityDef.planDBStructure: failed to get columns of table %s: %w", tn, err)
 	}
-
-	if cnt == 0 {
-		_, err = tran.Exec(fmt.Sprintf("alter table %s add constraint %s_pk primary key (Ref)", tn, tn))
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to add primary key constraint: %w", err)
+	dbTable := tn // table with existing structure, differs from tn when table should be renamed
+	if len(existing) == 0 {
+		for _, old := range T.RenamedFrom {
+			oldTn := strings.ToLower(old)
+			existing, err = dialect.TableColumns(T.Factory, oldTn)
+			if err != nil {
+				return fmt.Errorf("EntityDef.planDBStructure: failed to get columns of table %s: %w", oldTn, err)
+			}
+			if len(existing) > 0 {
+				dbTable = oldTn
+				break
+			}
 		}
 	}
-
-	err = T.Factory.CommitTran(tran)
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructurePostgres: failed to commit transaction: %w", err)
-	}
-	return nil
-}
-
-func (T *EntityDef) ensureDBStructureMSSQL() error {
-	tran, err := T.Factory.BeginTran()
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructureMSSQL: failed to begin transaction: %w", err)
+	newTable := len(existing) == 0
+	if dbTable != tn {
+		plan.Changes = append(plan.Changes, &SchemaChange{Kind: SchemaChangeRenameTable, Table: tn, Name: tn, From: dbTable,
+			Sql: dialect.RenameTableSql(dbTable, tn)})
 	}
 
-	tn, err := T.SqlTableName()
-	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructureMSSQL: failed to get SQL table name: %w", err)
+	// columns which are mapped to fields, by exact name or by rename
+	mapped := make(map[string]bool, len(existing))
+	for _, v := range T.FieldDefs {
+		mapped[strings.ToLower(v.Name)] = true
 	}
+	columnRenames := make([]*SchemaChange, 0)
 
-	_, err = tran.Exec(fmt.Sprintf("if not exists (select * from sysobjects where name='%s' and xtype='U') create table %s (ref nvarchar(%d) primary key)", tn, tn, refFieldLength))
-	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructureMSSQL: failed to create table: %w", err)
-	}
+	columnChanges := &SchemaPlan{Changes: make([]*SchemaChange, 0)}
+	target := make([]*DbColumn, 0, len(T.FieldDefs))
+	copyColumns := make([]string, 0, len(T.FieldDefs))
+	changed := make([]string, 0) // altered, renamed or dropped columns, their indexes should be recreated
+	rebuild := false
 
 	for _, v := range T.FieldDefs {
 		colType, err := v.SqlColumnType()
 		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureMSSQL: failed to get SQL column type for field %s: %w", v.Name, err)
+			return fmt.Errorf("EntityDef.planDBStructure: failed to get SQL column type for field %s: %w", v.Name, err)
 		}
 		coln, err := v.SqlColumnName()
 		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureMSSQL: failed to get SQL column name for field %s: %w", v.Name, err)
+			return fmt.Errorf("EntityDef.planDBStructure: failed to get SQL column name for field %s: %w", v.Name, err)
 		}
-		_, err = tran.Exec(fmt.Sprintf("if not exists (select * from syscolumns where id=object_id('%s') and name='%s') alter table %s add %s %s", tn, coln, tn, coln, colType))
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureMSSQL: failed to add column %s: %w", coln, err)
+		target = append(target, &DbColumn{Name: coln, Type: colType})
+
+		idx := slices.IndexFunc(existing, func(c *DbColumn) bool { return strings.EqualFold(c.Name, coln) })
+		if idx < 0 {
+			for _, old := range v.RenamedFrom {
+				oldc := strings.ToLower(old)
+				if mapped[oldc] {
+					continue
+				}
+				if idx = slices.IndexFunc(existing, func(c *DbColumn) bool { return strings.EqualFold(c.Name, oldc) }); idx >= 0 {
+					mapped[oldc] = true
+					changed = append(changed, oldc)
+					columnRenames = append(columnRenames, &SchemaChange{Kind: SchemaChangeRenameColumn, Table: tn, Name: coln, From: oldc,
+						Sql: dialect.RenameColumnSql(tn, oldc, coln)})
+					break
+				}
+			}
 		}
-	}
-	err = T.Factory.CommitTran(tran)
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructureMSSQL: failed to commit transaction: %w", err)
-	}
-	return nil
-}
-
-func (T *EntityDef) ensureDBStructureMySQL() error {
-	tran, err := T.Factory.BeginTran()
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to begin transaction: %w", err)
-	}
-
-	tn, err := T.SqlTableName()
-	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to get SQL table name: %w", err)
-	}
-
-	_, err = tran.Exec(fmt.Sprintf("create table if not exists %s (ref varchar(%d) primary key)", tn, refFieldLength))
-	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to create table: %w", err)
-	}
-
-	for _, v := range T.FieldDefs {
-		colType, err := v.SqlColumnType()
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to get SQL column type for field %s: %w", v.Name, err)
+		if idx < 0 {
+			if v.Name != RefFieldName { // ref column is created with table
+				columnChanges.add(SchemaChangeAddColumn, tn, coln, dialect.AddColumnSql(tn, coln, colType))
+			}
+			continue
 		}
-		coln, err := v.SqlColumnName()
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to get SQL column name for field %s: %w", v.Name, err)
+		copyColumns = append(copyColumns, coln)
+		if !sameColumnType(existing[idx].Type, colType) {
+			changed = append(changed, coln)
+			if alter := dialect.AlterColumnTypeSql(tn, coln, colType); alter != "" {
+				columnChanges.add(SchemaChangeAlterColumn, tn, coln, alter)
+			} else {
+				rebuild = true
+			}
 		}
+	}
 
-		query := T.Factory.PrepareSql("SELECT 1 FROM information_schema.columns WHERE table_name = $1 AND column_name = $2", tn, coln)
-		rows, err := tran.Query(query, tn, coln)
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to get column information for field %s: %w", v.Name, err)
-		}
-		defer func() {
-			_ = rows.Close()
-		}()
-		if !rows.Next() {
-			_, err = tran.Exec(fmt.Sprintf("alter table %s add column %s %s", tn, coln, colType))
-			if err != nil {
-				_ = T.Factory.RollbackTran(tran)
-				return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to add column %s: %w", coln, err)
+	if T.Factory.DropOrphanColumns {
+		for _, c := range existing {
+			if mapped[strings.ToLower(c.Name)] {
+				continue
+			}
+			changed = append(changed, c.Name)
+			if drop := dialect.DropColumnSql(tn, c.Name); drop != "" {
+				columnChanges.add(SchemaChangeDropColumn, tn, c.Name, drop)
+			} else {
+				rebuild = true
 			}
 		}
-		_ = rows.Close()
-	}
-	err = T.Factory.CommitTran(tran)
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructureMySQL: failed to commit transaction: %w", err)
 	}
-	return nil
-}
 
-func (T *EntityDef) ensureDBStructureSQLite() error {
-	tran, err := T.Factory.BeginTran()
+	foreignKeys, dropForeignKeys, addForeignKeys, fkRebuild, err := T.planForeignKeys(dbTable, newTable, changed)
 	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to begin transaction: %w", err)
+		return fmt.Errorf("EntityDef.planDBStructure: failed to plan foreign keys: %w", err)
 	}
+	rebuild = rebuild || fkRebuild
 
-	tn, err := T.SqlTableName()
-	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to get SQL table name: %w", err)
+	var rebuildSql []string
+	if rebuild {
+		rebuildSql = dialect.RebuildTableSql(tn, target, foreignKeys, copyColumns)
+		if len(rebuildSql) == 0 {
+			return fmt.Errorf("EntityDef.planDBStructure: dialect %s can't alter or drop columns of table %s", dialect.Name(), tn)
+		}
 	}
 
-	_, err = tran.Exec(fmt.Sprintf("create table if not exists %s (ref varchar(%d) primary key)", tn, refFieldLength))
+	dropIndexes, createIndexes, err := T.planDatabaseIndexes(dbTable, newTable, rebuild, changed)
 	if err != nil {
-		_ = T.Factory.RollbackTran(tran)
-		return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to create table: %w", err)
+		return fmt.Errorf("EntityDef.planDBStructure: failed to plan indexes: %w", err)
 	}
 
-	for _, v := range T.FieldDefs {
-		colType, err := v.SqlColumnType()
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to get SQL column type for field %s: %w", v.Name, err)
+	switch {
+	case newTable && rebuild:
+		for _, stmt := range rebuildSql {
+			plan.add(SchemaChangeCreateTable, tn, tn, stmt)
 		}
-		coln, err := v.SqlColumnName()
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to get SQL column name for field %s: %w", v.Name, err)
-		}
-
-		sql := fmt.Sprintf("PRAGMA table_info(%s)", tn)
-		rows, err := tran.Query(sql, tn)
-		if err != nil {
-			_ = T.Factory.RollbackTran(tran)
-			return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to get column information for field %s: %w", v.Name, err)
-		}
-		defer func() {
-			_ = rows.Close()
-		}()
-		colExists := false
-		for rows.Next() {
-			var cid int
-			var name, ctype string
-			var notnull, pk int
-			var dfltValue any
-			if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err == nil {
-				if name == coln {
-					colExists = true
-					break
-				}
+	case newTable:
+		plan.add(SchemaChangeCreateTable, tn, tn, dialect.CreateTableSql(tn))
+		plan.Changes = append(plan.Changes, columnChanges.Changes...)
+		// foreign keys are added after all tables are created
+		foreignKeyChanges.Changes = append(foreignKeyChanges.Changes, addForeignKeys...)
+	case rebuild:
+		plan.Changes = append(plan.Changes, dropIndexes...)
+		plan.Changes = append(plan.Changes, columnRenames...)
+		for _, fk := range foreignKeys {
+			if slices.Contains(copyColumns, fk.Column) { // empty refs are copied as is and violate the constraint
+				plan.add(SchemaChangeNullEmptyRefs, tn, fk.Column, fmt.Sprintf("update %s set %s = null where %s = ''", tn, fk.Column, fk.Column))
 			}
 		}
-		if !colExists {
-			_, err = tran.Exec(fmt.Sprintf("alter table %s add column %s %s", tn, coln, colType))
-			if err != nil {
-				_ = T.Factory.RollbackTran(tran)
-				return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to add column %s: %w", coln, err)
-			}
+		for _, stmt := range rebuildSql {
+			plan.add(SchemaChangeRebuildTable, tn, tn, stmt)
 		}
-	}
-	err = T.Factory.CommitTran(tran)
-	if err != nil {
-		return fmt.Errorf("EntityDef.ensureDBStructureSQLite: failed to commit transaction: %w", err)
-	}
+	default:
+		plan.Changes = append(plan.Changes, dropForeignKeys...)
+		plan.Changes = append(plan.Changes, dropIndexes...)
+		plan.Changes = append(plan.Changes, columnRenames...)
+		plan.Changes = append(plan.Changes, columnChanges.Changes...)
+		// foreign keys are added after all tables are created
+		foreignKeyChanges.Changes = append(foreignKeyChanges.Changes, addForeignKeys...)
+	}
+	plan.Changes = append(plan.Changes, createIndexes...)
 	return nil
 }
 
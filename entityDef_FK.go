@@ -0,0 +1,97 @@
+package elorm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// compileForeignKeyTargets returns foreign keys which should exist for ref fields with FieldDef.ForeignKey set.
+func (T *EntityDef) compileForeignKeyTargets() ([]*DbForeignKey, error) {
+	tableName, err := T.SqlTableName()
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.compileForeignKeyTargets: failed to get SQL table name: %w", err)
+	}
+	targets := make([]*DbForeignKey, 0)
+	for _, fd := range T.FieldDefs {
+		if fd.ForeignKey == ForeignKeyNone {
+			continue
+		}
+		if fd.Type != FieldDefTypeRef || fd.Name == RefFieldName {
+			return nil, fmt.Errorf("EntityDef.compileForeignKeyTargets: foreign key is allowed for ref fields only, field %s", fd.Name)
+		}
+		if fd.ForeignKey != ForeignKeyRestrict && fd.ForeignKey != ForeignKeyCascade && fd.ForeignKey != ForeignKeySetNull {
+			return nil, fmt.Errorf("EntityDef.compileForeignKeyTargets: unknown foreign key mode %d for field %s", fd.ForeignKey, fd.Name)
+		}
+		coln, err := fd.SqlColumnName()
+		if err != nil {
+			return nil, fmt.Errorf("EntityDef.compileForeignKeyTargets: failed to get SQL column name for field %s: %w", fd.Name, err)
+		}
+		refTable, err := fd.EntityDef.SqlTableName()
+		if err != nil {
+			return nil, fmt.Errorf("EntityDef.compileForeignKeyTargets: failed to get referenced table for field %s: %w", fd.Name, err)
+		}
+		targets = append(targets, &DbForeignKey{Name: foreignKeyName(tableName, coln), Column: coln, RefTable: refTable, OnDelete: fd.ForeignKey})
+	}
+	return targets, nil
+}
+
+// planForeignKeys returns changes to drop and to add foreign keys. Existing foreign keys are read from dbTable (it differs from
+// table name when table is renamed). Foreign keys over changed columns are recreated. When dialect can't add or drop foreign keys,
+// it returns rebuild=true and no changes, foreign keys are created by table rebuild then.
+func (T *EntityDef) planForeignKeys(dbTable string, newTable bool, changed []string) (targets []*DbForeignKey, drops []*SchemaChange, adds []*SchemaChange, rebuild bool, err error) {
+	tableName, err := T.SqlTableName()
+	if err != nil {
+		return nil, nil, nil, false, fmt.Errorf("EntityDef.planForeignKeys: failed to get SQL table name: %w", err)
+	}
+	targets, err = T.compileForeignKeyTargets()
+	if err != nil {
+		return nil, nil, nil, false, fmt.Errorf("EntityDef.planForeignKeys: %w", err)
+	}
+
+	real := make([]*DbForeignKey, 0)
+	if !newTable {
+		real, err = T.Factory.dialect.TableForeignKeys(T.Factory, dbTable)
+		if err != nil {
+			return nil, nil, nil, false, fmt.Errorf("EntityDef.planForeignKeys: failed to load database foreign keys: %w", err)
+		}
+	}
+
+	matched := make(map[*DbForeignKey]bool, len(real)+len(targets))
+	for _, v1 := range targets {
+		for _, v2 := range real {
+			if strings.EqualFold(v1.Name, v2.Name) && strings.EqualFold(v1.Column, v2.Column) && strings.EqualFold(v1.RefTable, v2.RefTable) &&
+				v1.OnDelete == v2.OnDelete && !slices.ContainsFunc(changed, func(c string) bool { return strings.EqualFold(c, v2.Column) }) {
+				matched[v1] = true
+				matched[v2] = true
+			}
+		}
+	}
+
+	for _, v2 := range real {
+		if matched[v2] {
+			continue
+		}
+		sql := T.Factory.dialect.DropForeignKeySql(tableName, v2)
+		if sql == "" {
+			return targets, nil, nil, true, nil
+		}
+		drops = append(drops, &SchemaChange{Kind: SchemaChangeDropForeignKey, Table: tableName, Name: v2.Name, Sql: sql})
+	}
+	for _, v1 := range targets {
+		if matched[v1] {
+			continue
+		}
+		sql := T.Factory.dialect.AddForeignKeySql(tableName, v1)
+		if sql == "" {
+			return targets, nil, nil, true, nil
+		}
+		if !newTable {
+			// existing rows keep empty refs as '', which violate the constraint
+			adds = append(adds, &SchemaChange{Kind: SchemaChangeNullEmptyRefs, Table: tableName, Name: v1.Column,
+				Sql: fmt.Sprintf("update %s set %s = null where %s = ''", tableName, v1.Column, v1.Column)})
+		}
+		adds = append(adds, &SchemaChange{Kind: SchemaChangeAddForeignKey, Table: tableName, Name: v1.Name, Sql: sql})
+	}
+	return targets, drops, adds, false, nil
+}
@@ -0,0 +1,210 @@
+package elorm
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEntityDef_ForeignKeys(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "fk.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	shopDef, _ := factory.CreateEntityDef("FkShop", "FkShops")
+	goodDef, _ := factory.CreateEntityDef("FkGood", "FkGoods")
+	shop, _ := goodDef.AddRefFieldDef("Shop", shopDef)
+	owner, _ := goodDef.AddRefFieldDef("Owner", shopDef)
+	name, _ := goodDef.AddStringFieldDef("Name", 50)
+	shop.ForeignKey = ForeignKeyCascade
+	owner.ForeignKey = ForeignKeySetNull
+	if err = goodDef.AddIndex(false, shop); err != nil {
+		t.Fatalf("AddIndex() error = %v", err)
+	}
+
+	name.ForeignKey = ForeignKeyRestrict
+	if _, err = factory.PlanDBStructure(); err == nil {
+		t.Fatalf("PlanDBStructure() with foreign key on string field should fail")
+	}
+	name.ForeignKey = ForeignKeyNone
+
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	plan, err := factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("PlanDBStructure() after apply = %v, want empty", plan.Sql())
+	}
+
+	ctx := context.Background()
+	newShop := func() *Entity {
+		s, _ := factory.CreateEntity(shopDef)
+		if err := s.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return s
+	}
+	newGood := func(s *Entity, o *Entity) *Entity {
+		g, _ := factory.CreateEntity(goodDef)
+		_ = g.Values["Shop"].(*FieldValueRef).Set(s)
+		if o != nil {
+			_ = g.Values["Owner"].(*FieldValueRef).Set(o)
+		}
+		if err := g.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return g
+	}
+	exists := func(ref string) bool {
+		rows, err := factory.Query("select ref from fkgoods where ref=$1", ref)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		return rows.Next()
+	}
+
+	// empty ref is stored as NULL and can be filtered
+	shop1 := newShop()
+	good1 := newGood(shop1, nil)
+	found, _, err := goodDef.SelectEntities([]*Filter{AddFilterEQ(owner, "")}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("SelectEntities() error = %v", err)
+	}
+	if len(found) != 1 || found[0].RefString() != good1.RefString() {
+		t.Errorf("SelectEntities() with empty ref = %v, want %s", found, good1.RefString())
+	}
+
+	// set null
+	shop2 := newShop()
+	good2 := newGood(shop1, shop2)
+	if err = factory.DeleteEntity(ctx, shop2.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	factory.loadedEntities.Purge()
+	loaded, err := factory.LoadEntity(good2.RefString())
+	if err != nil {
+		t.Fatalf("LoadEntity() error = %v", err)
+	}
+	if got := loaded.Values["Owner"].(*FieldValueRef).AsString(); got != "" {
+		t.Errorf("Owner after delete = %s, want empty", got)
+	}
+
+	// cascade
+	if err = factory.DeleteEntity(ctx, shop1.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	if exists(good1.RefString()) || exists(good2.RefString()) {
+		t.Errorf("goods are not deleted by cascade")
+	}
+
+	// changing mode recreates constraint, existing empty refs are kept
+	shop3 := newShop()
+	good3 := newGood(shop3, nil)
+	shop.ForeignKey = ForeignKeyRestrict
+	plan, err = factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	if !strings.Contains(plan.Sql(), "on delete restrict") {
+		t.Errorf("expected constraint change, got:\n%s", plan.Sql())
+	}
+	if err = factory.ApplyDBStructure(plan); err != nil {
+		t.Fatalf("ApplyDBStructure() error = %v", err)
+	}
+	fks, err := factory.dialect.TableForeignKeys(factory, "fkgoods")
+	if err != nil {
+		t.Fatalf("TableForeignKeys() error = %v", err)
+	}
+	if idx := slices.IndexFunc(fks, func(fk *DbForeignKey) bool { return fk.Column == "shop" }); idx < 0 || fks[idx].OnDelete != ForeignKeyRestrict {
+		t.Errorf("TableForeignKeys() = %v, want restrict on shop", fks)
+	}
+	if !exists(good3.RefString()) {
+		t.Fatalf("data was not preserved by rebuild")
+	}
+
+	// restrict
+	if err = factory.DeleteEntity(ctx, shop3.RefString()); err == nil {
+		t.Errorf("DeleteEntity() of referenced shop should fail")
+	}
+
+	// dropping constraint
+	shop.ForeignKey = ForeignKeyNone
+	owner.ForeignKey = ForeignKeyNone
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	if fks, _ = factory.dialect.TableForeignKeys(factory, "fkgoods"); len(fks) != 0 {
+		t.Errorf("TableForeignKeys() = %v, want none", fks)
+	}
+	if err = factory.DeleteEntity(ctx, shop3.RefString()); err != nil {
+		t.Errorf("DeleteEntity() without constraint error = %v", err)
+	}
+
+	// adding constraint to existing data replaces empty refs with NULL
+	shop4 := newShop()
+	newGood(shop4, nil)
+	owner.ForeignKey = ForeignKeySetNull
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	rows, err := factory.Query("select count(*) from fkgoods where owner=''")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	cnt := -1
+	if rows.Next() {
+		_ = rows.Scan(&cnt)
+	}
+	if cnt != 0 {
+		t.Errorf("empty refs count = %d, want 0", cnt)
+	}
+}
+
+// alterFkDialect is SQLite dialect which adds foreign keys by alter table like server databases
+type alterFkDialect struct {
+	SQLiteDialect
+}
+
+func (T alterFkDialect) AddForeignKeySql(table string, fk *DbForeignKey) string {
+	return PostgresDialect{}.AddForeignKeySql(table, fk)
+}
+
+func TestEntityDef_ForeignKeys_NewTable(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "fknew.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	factory.dialect = alterFkDialect{SQLiteDialect: factory.dialect.(SQLiteDialect)}
+	shopDef, _ := factory.CreateEntityDef("FnShop", "FnShops")
+	goodDef, _ := factory.CreateEntityDef("FnGood", "FnGoods")
+	shop, _ := goodDef.AddRefFieldDef("Shop", shopDef)
+	shop.ForeignKey = ForeignKeyCascade
+
+	plan, err := factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	adds := make([]*SchemaChange, 0)
+	for _, c := range plan.Changes {
+		if c.Kind == SchemaChangeAddForeignKey {
+			adds = append(adds, c)
+		}
+	}
+	if len(adds) != 1 || adds[0].Table != "fngoods" || !strings.Contains(adds[0].Sql, "references fnshops") {
+		t.Fatalf("first plan should add foreign key of new table, got:\n%s", plan.Sql())
+	}
+	if plan.Changes[len(plan.Changes)-1] != adds[0] {
+		t.Errorf("foreign key should be added after tables are created, got:\n%s", plan.Sql())
+	}
+}
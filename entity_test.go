@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -120,3 +121,65 @@ func TestSelectEntities(t *testing.T) {
 	_ = allLines
 
 }
+
+func TestSelectEntities_Parameterized(t *testing.T) {
+	factory := mockFactory()
+	ordersDef := mockEntityDef_Orders(factory)
+
+	mock_ClearEntities(t)
+	mock_SeedEntities(t)
+
+	Nbr := ordersDef.FieldDefByName("OrderNbr")
+
+	Date := ordersDef.FieldDefByName("OrderDate")
+	date := time.Date(2024, 5, 17, 10, 30, 0, 0, time.UTC)
+
+	ent := mockEntity_Order()
+	ent.Values["OrderNbr"].(*FieldValueString).Set("O'Brien")
+	ent.Values["OrderDate"].(*FieldValueDateTime).Set(date)
+	err := ent.Save(context.Background())
+	if err != nil {
+		t.Fatalf("Entity.Save() error = %v", err)
+	}
+
+	ent = mockEntity_Order()
+	ent.Values["OrderNbr"].(*FieldValueString).Set("äpfel")
+	if err = ent.Save(context.Background()); err != nil {
+		t.Fatalf("Entity.Save() error = %v", err)
+	}
+
+	// dates are stored in DateTimeJSONFormat as before parameters were bound
+	table, _ := ordersDef.SqlTableName()
+	var stored int
+	_ = factory.db.QueryRow("select count(*) from " + table + " where orderdate = '" + date.Format(Date.DateTimeJSONFormat) + "'").Scan(&stored)
+	if stored != 1 {
+		t.Errorf("stored OrderDate doesn't match literal in DateTimeJSONFormat")
+	}
+
+	tests := []struct {
+		name    string
+		filters []*Filter
+		want    int
+	}{
+		{name: "quote in EQ", filters: []*Filter{AddFilterEQ(Nbr, "O'Brien")}, want: 1},
+		{name: "quote in LIKE", filters: []*Filter{AddFilterLIKE(Nbr, "o'b%")}, want: 1},
+		{name: "upper case LIKE", filters: []*Filter{AddFilterLIKE(Nbr, "O'B%")}, want: 1},
+		{name: "non-ASCII upper case LIKE", filters: []*Filter{AddFilterLIKE(Nbr, "ÄP%")}, want: 1},
+		{name: "injection in LIKE", filters: []*Filter{AddFilterLIKE(Nbr, "x' or '1'='1")}, want: 0},
+		{name: "injection in IN", filters: []*Filter{AddFilterIN(Nbr, "x') or ('1'='1", "OrderNbr_1")}, want: 1},
+		{name: "date EQ", filters: []*Filter{AddFilterEQ(Date, date)}, want: 1},
+		{name: "date range", filters: []*Filter{AddFilterGE(Date, date.Add(-time.Hour)), AddFilterLT(Date, date.Add(time.Hour))}, want: 1},
+		{name: "many params", filters: []*Filter{AddFilterIN(Nbr, "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "OrderNbr_11")}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, _, err := ordersDef.SelectEntities(tt.filters, nil, 0, 0)
+			if err != nil {
+				t.Fatalf("SelectEntities() error = %v", err)
+			}
+			if len(res) != tt.want {
+				t.Errorf("SelectEntities() returned %d rows, want %d", len(res), tt.want)
+			}
+		})
+	}
+}
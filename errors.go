@@ -0,0 +1,13 @@
+package elorm
+
+import "errors"
+
+// Errors which may be wrapped into errors returned by elorm, check them with errors.Is. HandleRestApi maps them to
+// HTTP status codes.
+var (
+	ErrNotFound         = errors.New("entity not found")                   // 404 Not Found
+	ErrInvalidRef       = errors.New("invalid ref")                        // 404 Not Found
+	ErrConcurrentUpdate = errors.New("entity was changed by another user") // 409 Conflict
+	ErrReferenced       = errors.New("entity is referenced")               // 409 Conflict
+	ErrValidation       = errors.New("validation failed")                  // 422 Unprocessable Entity
+)
@@ -0,0 +1,97 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "errors.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	shopDef, _ := factory.CreateEntityDef("ErShop", "ErShops")
+	_, _ = shopDef.AddStringFieldDef("Caption", 50)
+	goodDef, _ := factory.CreateEntityDef("ErGood", "ErGoods")
+	_, _ = goodDef.AddIntFieldDef("Qty")
+	ownerShop, _ := goodDef.AddRefFieldDef("OwnerShop", shopDef)
+	ownerShop.DeleteRule = DeleteRuleRestrict
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	ctx := context.Background()
+	shop, _ := factory.CreateEntity(shopDef)
+	_ = shop.Save(ctx)
+	good, _ := factory.CreateEntity(goodDef)
+	_ = good.Values["OwnerShop"].(*FieldValueRef).Set(shop)
+	if err = good.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	unsaved, _ := factory.CreateEntity(goodDef)
+
+	if _, err = factory.LoadEntity(unsaved.RefString()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadEntity() of unsaved entity error = %v, want ErrNotFound", err)
+	}
+	if _, err = factory.LoadEntity("garbage"); !errors.Is(err, ErrInvalidRef) {
+		t.Errorf("LoadEntity() of invalid ref error = %v, want ErrInvalidRef", err)
+	}
+	if err = good.Values["OwnerShop"].(*FieldValueRef).Set(unsaved.RefString()); !errors.Is(err, ErrInvalidRef) {
+		t.Errorf("FieldValueRef.Set() of other type ref error = %v, want ErrInvalidRef", err)
+	}
+	if err = json.Unmarshal([]byte(`{"Qty": true}`), unsaved); !errors.Is(err, ErrValidation) {
+		t.Errorf("json.Unmarshal() with wrong type error = %v, want ErrValidation", err)
+	}
+	if err = factory.DeleteEntity(ctx, shop.RefString()); !errors.Is(err, ErrReferenced) {
+		t.Errorf("DeleteEntity() of referenced entity error = %v, want ErrReferenced", err)
+	}
+	table, _ := goodDef.SqlTableName()
+	if _, err = factory.db.Exec("update "+table+" set dataversion='other' where ref=$1", good.RefString()); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	good.Values["Qty"].(*FieldValueInt).Set(1)
+	if err = good.Save(ctx); !errors.Is(err, ErrConcurrentUpdate) {
+		t.Errorf("Save() of changed entity error = %v, want ErrConcurrentUpdate", err)
+	}
+
+	goods := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	shops := CreateStdRestApiConfig(shopDef, factory.LoadEntity, shopDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(shopDef) })
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request)
+		method  string
+		ref     string
+		body    string
+		want    int
+	}{
+		{"GET unsaved", HandleRestApi(goods), http.MethodGet, unsaved.RefString(), "", http.StatusNotFound},
+		{"GET invalid ref", HandleRestApi(goods), http.MethodGet, "garbage", "", http.StatusNotFound},
+		{"PUT wrong type", HandleRestApi(goods), http.MethodPut, good.RefString(), `{"Qty": "x"}`, http.StatusUnprocessableEntity},
+		{"POST wrong ref", HandleRestApi(goods), http.MethodPost, "", `{"OwnerShop": "` + good.RefString() + `"}`, http.StatusUnprocessableEntity},
+		{"POST invalid JSON", HandleRestApi(goods), http.MethodPost, "", `{`, http.StatusBadRequest},
+		{"DELETE referenced", HandleRestApi(shops), http.MethodDelete, shop.RefString(), "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(tt.method, "/api?ref="+tt.ref, strings.NewReader(tt.body)))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d %s, want %d", w.Code, w.Body.String(), tt.want)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %s", ct)
+			}
+			var problem problemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Status != tt.want || problem.Title != http.StatusText(tt.want) || problem.Detail == "" {
+				t.Errorf("body = %s, error = %v", w.Body.String(), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package elorm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// EntityETag returns HTTP entity tag of entity, it's quoted DataVersion. ETag changes on each Save of entity.
+func EntityETag(entity IEntity) string {
+	return `"` + entity.GetValues()[DataVersionFieldName].AsString() + `"`
+}
+
+// representationETag returns weak ETag of response body. It is used for GET responses shaped by fields and expand
+// parameters or by Factory.FieldAccessPolicy: they depend on parameters, caller and referenced entities, so DataVersion
+// of entity doesn't identify them. Such ETag can't be used in If-Match, it needs strong ETag of entity.
+func representationETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches checks etag against list of entity tags from If-Match or If-None-Match header, "*" matches any.
+// Weak tags (W/"...") match only with weak comparison.
+func etagMatches(header string, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag || (weak && "W/"+tag == etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch checks If-Match header of modifying request against entity. It sends error response and returns false
+// when header doesn't match (412) or is missing while config requires it (428).
+func checkIfMatch[T IEntity](config RestApiConfig[T], r *http.Request, w http.ResponseWriter, entity IEntity) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if config.RequireIfMatch {
+			sendHttpError(w, "If-Match header is required", http.StatusPreconditionRequired)
+			return false
+		}
+		return true
+	}
+	if !etagMatches(header, EntityETag(entity), false) {
+		sendHttpError(w, fmt.Sprintf("entity %s was changed, actual ETag is %s", entity.RefString(), EntityETag(entity)), http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package elorm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestApi_ETag(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "etag.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	goodDef, _ := factory.CreateEntityDef("EtGood", "EtGoods")
+	_, _ = goodDef.AddStringFieldDef("Caption", 50)
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	good, _ := factory.CreateEntity(goodDef)
+	good.Values["Caption"].(*FieldValueString).Set("apple")
+	if err = good.Save(context.Background()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	config := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	config.EnablePatch = true
+	do := func(method string, header string, value string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/goods?ref="+good.RefString(), strings.NewReader(body))
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, r)
+		return w
+	}
+
+	etag := EntityETag(good)
+	if w := do(http.MethodGet, "", "", ""); w.Code != http.StatusOK || w.Header().Get("ETag") != etag {
+		t.Fatalf("GET = %d, ETag = %q, want %q", w.Code, w.Header().Get("ETag"), etag)
+	}
+	for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		if w := do(http.MethodGet, "If-None-Match", inm, ""); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("GET with If-None-Match %s = %d, want 304", inm, w.Code)
+		}
+	}
+	if w := do(http.MethodGet, "If-None-Match", `"other"`, ""); w.Code != http.StatusOK {
+		t.Errorf("GET with other If-None-Match = %d, want 200", w.Code)
+	}
+
+	// representation limited by fields has its own weak ETag
+	getFields := func(inm string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/goods?fields=Caption&ref="+good.RefString(), nil)
+		r.Header.Set("If-None-Match", inm)
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, r)
+		return w
+	}
+	w := getFields(etag)
+	shapedTag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(shapedTag, "W/") {
+		t.Fatalf("GET with fields and ETag of entity = %d, ETag = %q, want 200 and weak ETag", w.Code, shapedTag)
+	}
+	if w = getFields(shapedTag); w.Code != http.StatusNotModified {
+		t.Errorf("GET with fields and its ETag = %d, want 304", w.Code)
+	}
+	if w = do(http.MethodGet, "If-None-Match", shapedTag, ""); w.Code != http.StatusOK {
+		t.Errorf("GET with ETag of fields representation = %d, want 200", w.Code)
+	}
+
+	// stale and weak tags are rejected
+	for _, im := range []string{`"stale"`, "W/" + etag} {
+		if w := do(http.MethodPut, "If-Match", im, `{"Caption": "pear"}`); w.Code != http.StatusPreconditionFailed {
+			t.Errorf("PUT with If-Match %s = %d, want 412", im, w.Code)
+		}
+	}
+	if good.Values["Caption"].AsString() != "apple" {
+		t.Errorf("rejected PUT changed entity")
+	}
+	w = do(http.MethodPut, "If-Match", etag, `{"Caption": "pear"}`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag || w.Header().Get("ETag") != EntityETag(good) {
+		t.Fatalf("PUT with If-Match = %d, ETag = %q", w.Code, w.Header().Get("ETag"))
+	}
+	if w = do(http.MethodPatch, "If-Match", etag, `{"Caption": "plum"}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("PATCH with stale If-Match = %d, want 412", w.Code)
+	}
+	if w = do(http.MethodPatch, "", "", `{"Caption": "plum"}`); w.Code != http.StatusOK {
+		t.Errorf("PATCH without If-Match = %d, want 200", w.Code)
+	}
+
+	config.RequireIfMatch = true
+	if w = do(http.MethodPatch, "", "", `{"Caption": "kiwi"}`); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("PATCH without required If-Match = %d, want 428", w.Code)
+	}
+	if w = do(http.MethodDelete, "", "", ""); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("DELETE without required If-Match = %d, want 428", w.Code)
+	}
+	if w = do(http.MethodDelete, "If-Match", etag, ""); w.Code != http.StatusPreconditionFailed {
+		b, _ := io.ReadAll(w.Body)
+		t.Errorf("DELETE with stale If-Match = %d %s, want 412", w.Code, b)
+	}
+	if w = do(http.MethodDelete, "If-Match", EntityETag(good), ""); w.Code != http.StatusOK {
+		t.Errorf("DELETE with If-Match = %d, want 200", w.Code)
+	}
+	if _, err = factory.LoadEntity(good.RefString()); err == nil {
+		t.Errorf("entity isn't deleted")
+	}
+}
@@ -6,12 +6,13 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/golang-lru/v2/expirable"
 )
 
-// Database dialect constants for supported database types.
+// Database dialect constants for built-in database types. See Factory.Dialect() for dialect-specific behavior.
 const (
 	DbDialectPostgres = 100
 	DbDialectMSSQL    = 200
@@ -30,13 +31,20 @@ const (
 type Factory struct {
 	loadedEntities       *expirable.LRU[string, *Entity]
 	dataVersionCheckMode int // controlled by setDataVersionCheckMode, default is DataVersionCheckDefault
-	dbDialect            int
+	dialect              Dialect
 	db                   *sql.DB
-	activeTx             *sql.Tx // Active transaction, if any. Used to ensure that all entities are created in the same transaction.
-	nestedTxLevel        int     // Used to track nested transactions, so we can commit or rollback correctly.
+	writerLock           chan struct{} // serializes top-level transactions for single writer dialects (SQLite), see lockWriter
+	legacyTxs            sync.Map      // *sql.Tx returned by deprecated BeginTran -> its *Tx
+	migrations           []*migration  // custom migration steps ordered by version, see AddMigration
 
 	AggressiveReadingCache bool // It assumes each database has only one factory instance, so it can cache entities aggressively.
+	DropOrphanColumns      bool // EnsureDBStructure/PlanDBStructure drop table columns which have no field definitions
 	EntityDefs             []*EntityDef
+
+	// FieldAccessPolicy returns access mode of field (FieldAccessReadWrite, FieldAccessHidden, ...) for context, e.g.
+	// by user of request. It replaces FieldDef.Access when set. MarshalJSON and UnmarshalJSON call it with
+	// context.Background(), REST API and *Context methods with context of request. LoadFrom never calls it.
+	FieldAccessPolicy func(ctx context.Context, fd *FieldDef) int
 }
 
 func addHandler[ht any](
@@ -114,128 +122,83 @@ func (f *Factory) AddBeforeDeleteHandler(dest any, handler EntityHandlerFunc) er
 		func(def *EntityDef, newValue []EntityHandlerFunc) { def.beforeDeleteHandlers = newValue })
 }
 
-// BeginTran begins a database transaction or increases the nested transaction level if already in a transaction.
-func (f *Factory) BeginTran() (*sql.Tx, error) {
-	if f.dbDialect != DbDialectSQLite {
-		return f.db.Begin()
-	}
-	if f.nestedTxLevel == 0 {
-		newTx, err := f.db.Begin()
-		if err != nil {
-			return nil, fmt.Errorf("Factory.BeginTran: failed to begin transaction: %w", err)
-		}
-		f.activeTx = newTx
-	}
-	f.nestedTxLevel++
-	return f.activeTx, nil
-}
-
-// CommitTran decreases transaction level and commits the transaction if it was the last one.
-func (f *Factory) CommitTran(tx *sql.Tx) error {
-	if f.dbDialect != DbDialectSQLite {
-		return tx.Commit()
-	}
-	if f.nestedTxLevel == 0 {
-		return fmt.Errorf("Factory.CommitTran: no active transaction to commit")
-	}
-	f.nestedTxLevel--
-	if f.nestedTxLevel == 0 {
-		err := f.activeTx.Commit()
-		if err != nil {
-			_ = f.RollbackTran(tx)
-		}
-		f.activeTx = nil
-		return err
-	}
-	return nil
-}
-
-// PrepareSql prepares a SQL query by replacing Postgres-style parameters ($1, $2, ...) with MySQL/SQLite-style (?) if needed.
+// PrepareSql prepares a SQL query by replacing Postgres-style parameters ($1, $2, ...) with dialect placeholders (e.g. ? for MySQL/SQLite) if needed.
 func (f *Factory) PrepareSql(query string, args ...any) string {
 	result := query
-	if f.dbDialect == DbDialectMySQL || f.dbDialect == DbDialectSQLite {
-		for i := range args {
-			result = strings.ReplaceAll(result, fmt.Sprintf("$%d", i+1), "?")
+	// replace from the last parameter to the first one, so $1 doesn't break $10, $11, ...
+	for i := len(args); i > 0; i-- {
+		pgStyle := fmt.Sprintf("$%d", i)
+		if ph := f.dialect.Placeholder(i); ph != pgStyle {
+			result = strings.ReplaceAll(result, pgStyle, ph)
 		}
 	}
 	return result
 }
 
 // Query is a wrapper for sql.DB.Query(). It always accepts parameters in Postgres style ($1, $2, ...) and converts it to MySQL/SQLite style (?) if needed.
-// And it uses database connection or active transaction depending on the dialect and transaction level.
 func (f *Factory) Query(query string, args ...any) (*sql.Rows, error) {
+	return f.QueryContext(context.Background(), query, args...)
+}
 
+// QueryContext is like Query, but it runs inside the transaction carried by ctx (see BeginTranContext), if any.
+func (f *Factory) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
 	query2 := f.PrepareSql(query, args...)
-
-	if f.dbDialect != DbDialectSQLite {
-		return f.db.Query(query2, args...)
+	if tx := f.txFromContext(ctx); tx != nil {
+		return tx.tx.QueryContext(ctx, query2, args...)
 	}
-	if f.nestedTxLevel > 0 {
-		return f.activeTx.Query(query2, args...)
-	}
-	return f.db.Query(query2, args...)
+	return f.db.QueryContext(ctx, query2, args...)
 }
 
 // Exec executes a query without returning any rows.
 func (f *Factory) Exec(query string, args ...any) (sql.Result, error) {
-
-	query2 := f.PrepareSql(query, args...)
-
-	if f.dbDialect != DbDialectSQLite {
-		return f.db.Exec(query2, args...)
-	}
-	if f.nestedTxLevel > 0 {
-		return f.activeTx.Exec(query2, args...)
-	}
-	return f.db.Exec(query2, args...)
+	return f.ExecContext(context.Background(), query, args...)
 }
 
-// RollbackTran rolls back a database transaction and zeroes the transaction level.
-func (f *Factory) RollbackTran(tx *sql.Tx) error {
-	if f.dbDialect != DbDialectSQLite {
-		return tx.Rollback()
-	}
-	if f.nestedTxLevel == 0 {
-		return fmt.Errorf("Factory.RollbackTran: no active transaction to rollback")
+// ExecContext is like Exec, but it runs inside the transaction carried by ctx (see BeginTranContext), if any.
+func (f *Factory) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query2 := f.PrepareSql(query, args...)
+	if tx := f.txFromContext(ctx); tx != nil {
+		return tx.tx.ExecContext(ctx, query2, args...)
 	}
-	err := f.activeTx.Rollback()
-	f.activeTx = nil
-	f.nestedTxLevel = 0
-	return err
+	return f.db.ExecContext(ctx, query2, args...)
 }
 
 // CreateFactory creates a new Factory instance with the specified database dialect and connection string.
+// Dialect name should be one of built-in dialects (postgres, mssql, mysql, sqlite) or registered by RegisterDialect.
 func CreateFactory(dbDialect string, connectionString string) (*Factory, error) {
 	if dbDialect == "" {
 		return nil, fmt.Errorf("Factory.CreateFactory: dbDialect is empty")
 	}
-	if connectionString == "" {
-		return nil, fmt.Errorf("Factory.CreateFactory: connectionString is empty")
+	dialect, err := DialectByName(dbDialect)
+	if err != nil {
+		return nil, fmt.Errorf("Factory.CreateFactory: %w", err)
 	}
+	return createFactory(dialect, connectionString)
+}
 
-	var dbd int
-	switch dbDialect {
-	case "postgres":
-		dbd = DbDialectPostgres
-	case "mssql":
-		dbd = DbDialectMSSQL
-	case "mysql":
-		dbd = DbDialectMySQL
-	case "sqlite", "sqlite3":
-		dbd = DbDialectSQLite
-	default:
-		return nil, fmt.Errorf("Factory.CreateFactory: unsupported db dialect: %s", dbDialect)
+// CreateFactoryWithDialect creates a new Factory instance with the specified dialect implementation and connection string.
+func CreateFactoryWithDialect(dialect Dialect, connectionString string) (*Factory, error) {
+	if dialect == nil {
+		return nil, fmt.Errorf("Factory.CreateFactoryWithDialect: dialect is nil")
+	}
+	return createFactory(dialect, connectionString)
+}
+
+func createFactory(dialect Dialect, connectionString string) (*Factory, error) {
+	if connectionString == "" {
+		return nil, fmt.Errorf("Factory.CreateFactory: connectionString is empty")
 	}
 
 	r := &Factory{
-		dbDialect:              dbd,
+		dialect:                dialect,
 		EntityDefs:             make([]*EntityDef, 0),
 		loadedEntities:         expirable.NewLRU[string, *Entity](0, nil, time.Minute*10),
 		dataVersionCheckMode:   DataVersionCheckAlways,
 		AggressiveReadingCache: false,
+		writerLock:             make(chan struct{}, 1),
 	}
 	var err error
-	r.db, err = sql.Open(dbDialect, connectionString)
+	r.db, err = sql.Open(dialect.DriverName(), connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("Factory.CreateFactory: failed to open DB: %w", err)
 	}
@@ -248,9 +211,27 @@ func CreateFactory(dbDialect string, connectionString string) (*Factory, error)
 	return r, nil
 }
 
-// DbDialect returns the database dialect for this factory.
+// Dialect returns the database dialect for this factory.
+func (T *Factory) Dialect() Dialect {
+	return T.dialect
+}
+
+// DbDialect returns the built-in database dialect constant (DbDialectPostgres, ...) for this factory, or 0 for custom dialects.
+//
+// Deprecated: use Dialect() instead.
 func (T *Factory) DbDialect() int {
-	return T.dbDialect
+	switch T.dialect.(type) {
+	case PostgresDialect:
+		return DbDialectPostgres
+	case MSSQLDialect:
+		return DbDialectMSSQL
+	case MySQLDialect:
+		return DbDialectMySQL
+	case SQLiteDialect:
+		return DbDialectSQLite
+	default:
+		return 0
+	}
 }
 
 // SetDataVersionCheckMode sets default data version checking mode for this factory. It can be overridden by EntityDef level.
@@ -308,6 +289,10 @@ func (T *Factory) CreateEntityDef(ObjectName string, TableName string) (*EntityD
 		return nil, fmt.Errorf("Factory.CreateEntityDef: error creating DataVersion field for %s: %w", ObjectName, err)
 	}
 
+	// system fields are maintained by Save and DeleteEntity, not by JSON input
+	r.IsDeletedField.Access = FieldAccessReadOnly
+	r.DataVersionField.Access = FieldAccessReadOnly
+
 	return r, nil
 }
 
@@ -363,6 +348,20 @@ func (T *Factory) createEntityImpl(def *EntityDef, fillNew bool) (*Entity, error
 
 	r.dataVersion = r.Values[DataVersionFieldName].(*FieldValueString)
 
+	if len(def.TablePartDefs) > 0 {
+		r.tableParts = make(map[string]*TablePart, len(def.TablePartDefs))
+		for _, tpd := range def.TablePartDefs {
+			r.tableParts[tpd.Name] = &TablePart{Def: tpd, owner: r, rows: make([]*Entity, 0), loaded: true}
+		}
+	}
+
+	if len(def.ManyToManyDefs) > 0 {
+		r.links = make(map[string]*ManyToMany, len(def.ManyToManyDefs))
+		for _, mtm := range def.ManyToManyDefs {
+			r.links[mtm.Name] = &ManyToMany{Def: mtm, owner: r}
+		}
+	}
+
 	if fillNew {
 		for _, handler := range def.fillNewHandlers {
 			err := handler(r.entityDef.Wrap(r))
@@ -401,10 +400,15 @@ func (T *Factory) CreateEntityWrapped(def *EntityDef) (any, error) {
 
 // LoadEntity loads an entity from factory cache or from the database by its reference string.
 func (T *Factory) LoadEntity(Ref string) (*Entity, error) {
+	return T.LoadEntityContext(context.Background(), Ref)
+}
+
+// LoadEntityContext is like LoadEntity, but it reads database inside the transaction carried by ctx (see BeginTranContext), if any.
+func (T *Factory) LoadEntityContext(ctx context.Context, Ref string) (*Entity, error) {
 
 	ok, def := T.IsRef(Ref)
 	if !ok {
-		return nil, fmt.Errorf("Factory.LoadEntity: invalid ref %s", Ref)
+		return nil, fmt.Errorf("Factory.LoadEntity: %w %s", ErrInvalidRef, Ref)
 	}
 
 	tableName, err := def.SqlTableName()
@@ -417,10 +421,13 @@ func (T *Factory) LoadEntity(Ref string) (*Entity, error) {
 	fromCache, ok := T.loadedEntities.Get(Ref)
 	if ok {
 		if dvcm != DataVersionCheckNever {
-			q := T.PrepareSql(fmt.Sprintf("select 1 from %s where Ref=$1 and DataVersion=$2", tableName), Ref, fromCache.DataVersion())
-			row := T.db.QueryRow(q, Ref, fromCache.DataVersion())
-			var scanBuffer *int
-			if row.Scan(scanBuffer) == sql.ErrNoRows {
+			rows, err := T.QueryContext(ctx, fmt.Sprintf("select 1 from %s where Ref=$1 and DataVersion=$2", tableName), Ref, fromCache.DataVersion())
+			if err != nil {
+				return nil, fmt.Errorf("Factory.LoadEntity: failed to check data version: %w", err)
+			}
+			actual := rows.Next()
+			_ = rows.Close()
+			if !actual {
 				// The entity is not in the database or it changed, so we need to reload it.
 				T.loadedEntities.Remove(Ref)
 			} else {
@@ -452,7 +459,7 @@ func (T *Factory) LoadEntity(Ref string) (*Entity, error) {
 	}
 
 	sql := fmt.Sprintf("select %s from %s where ref=$1", strings.Join(fn, ", "), tableName)
-	rows, err := T.Query(sql, Ref)
+	rows, err := T.QueryContext(ctx, sql, Ref)
 	if err != nil {
 		return nil, fmt.Errorf("Factory.LoadEntity: failed to query select statement: %w", err)
 	}
@@ -464,7 +471,7 @@ func (T *Factory) LoadEntity(Ref string) (*Entity, error) {
 		if err := rows.Err(); err != nil {
 			return nil, fmt.Errorf("Factory.LoadEntity: rows error: %w", err)
 		}
-		return nil, fmt.Errorf("Factory.LoadEntity: entity not found in database")
+		return nil, fmt.Errorf("Factory.LoadEntity: %w in database: %s", ErrNotFound, Ref)
 	}
 
 	err = rows.Scan(fp...)
@@ -472,11 +479,43 @@ func (T *Factory) LoadEntity(Ref string) (*Entity, error) {
 		return nil, fmt.Errorf("Factory.LoadEntity: failed to scan row: %w", err)
 	}
 	res.isNew = false
+	_ = rows.Close()
+
+	if err = def.loadTableParts(ctx, []*Entity{res}); err != nil {
+		return nil, fmt.Errorf("Factory.LoadEntity: %w", err)
+	}
 	T.loadedEntities.Add(Ref, res)
 
 	return res, nil
 }
 
+// LoadEntityPartial is like LoadEntity, but it loads only specified fields (Ref is loaded always), see EntityDef.SelectPartial.
+// It always reads database, partially loaded entity isn't taken from cache and isn't put there.
+func (T *Factory) LoadEntityPartial(Ref string, fields ...*FieldDef) (*Entity, error) {
+	return T.LoadEntityPartialContext(context.Background(), Ref, fields...)
+}
+
+// LoadEntityPartialContext is like LoadEntityPartial, but it reads database inside the transaction carried by ctx (see BeginTranContext), if any.
+func (T *Factory) LoadEntityPartialContext(ctx context.Context, Ref string, fields ...*FieldDef) (*Entity, error) {
+	ok, def := T.IsRef(Ref)
+	if !ok {
+		return nil, fmt.Errorf("Factory.LoadEntityPartial: %w %s", ErrInvalidRef, Ref)
+	}
+	filters := []*Filter{AddFilterEQ(def.RefField, Ref)}
+	if def.UseSoftDelete {
+		// soft deleted entities are loaded like by LoadEntity
+		filters = append(filters, AddFilterIN(def.IsDeletedField, true, false))
+	}
+	res, _, err := def.SelectPartialContext(ctx, fields, filters, nil, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Factory.LoadEntityPartial: %w", err)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("Factory.LoadEntityPartial: %w in database: %s", ErrNotFound, Ref)
+	}
+	return res[0], nil
+}
+
 // LoadEntityWrapped loads an entity from the database and wraps it in a custom struct if defined.
 func (T *Factory) LoadEntityWrapped(Ref string) (any, error) {
 	res, err := T.LoadEntity(Ref)
@@ -489,6 +528,9 @@ func (T *Factory) LoadEntityWrapped(Ref string) (any, error) {
 	return res, nil
 }
 
+// DeleteEntity deletes entity by its reference. It runs before delete handlers, delete rules of fields referencing the entity
+// (see FieldDef.DeleteRule) and deletion in one transaction, nested into the transaction carried by ctx, if any.
+// Entity with UseSoftDelete is marked IsDeleted=true and saved instead, already soft deleted entity is left as is.
 func (T *Factory) DeleteEntity(ctx context.Context, ref string) error {
 
 	if ref == "" {
@@ -497,156 +539,70 @@ func (T *Factory) DeleteEntity(ctx context.Context, ref string) error {
 
 	ok, def := T.IsRef(ref)
 	if !ok {
-		return fmt.Errorf("Factory.DeleteEntity: invalid ref %s", ref)
-	}
-
-	var err error
-	tx, err := T.BeginTran()
-	if err != nil {
-		return fmt.Errorf("Factory.DeleteEntity: failed to begin transaction: %w", err)
+		return fmt.Errorf("Factory.DeleteEntity: %w %s", ErrInvalidRef, ref)
 	}
 
-	// before delete handlers
-	for _, handler := range def.beforeDeleteHandlerByRefs {
-		err := handler(ctx, ref)
+	if def.UseSoftDelete {
+		e, err := T.LoadEntityContext(ctx, ref)
 		if err != nil {
-			_ = T.RollbackTran(tx)
-			return fmt.Errorf("Factory.DeleteEntity: BeforeDeleteHandlerByRef failed: %w", err)
+			return fmt.Errorf("Factory.DeleteEntity: failed to load entity for soft deletion: %w", err)
 		}
-	}
-	if len(def.beforeDeleteHandlers) > 0 {
-		loaded, err := T.LoadEntity(ref)
-		if err != nil {
-			_ = T.RollbackTran(tx)
-			return fmt.Errorf("Factory.DeleteEntity: failed to load entity for deletion (for running BeforeDeleteHandler): %w", err)
+		if e.IsDeleted() {
+			return nil
 		}
-		for _, handler := range def.beforeDeleteHandlers {
-			err = handler(ctx, loaded)
-			if err != nil {
-				_ = T.RollbackTran(tx)
-				return fmt.Errorf("Factory.DeleteEntity: BeforeDeleteHandler failed: %w", err)
-			}
+		if err = T.softDeleteEntity(ctx, e); err != nil {
+			return fmt.Errorf("Factory.DeleteEntity: %w", err)
 		}
+		return nil
 	}
 
-	tableName, err := def.SqlTableName()
+	ctx, tx, err := T.BeginTranContext(ctx)
 	if err != nil {
-		_ = T.RollbackTran(tx)
-		return fmt.Errorf("Factory.DeleteEntity: failed to get SQL table name for entity %s: %w", def.ObjectName, err)
+		return fmt.Errorf("Factory.DeleteEntity: failed to begin transaction: %w", err)
 	}
 
-	switch T.dbDialect {
-	case DbDialectPostgres, DbDialectMSSQL:
-		sql := fmt.Sprintf("delete from %s where Ref=$1", tableName)
-		_, err = tx.Exec(sql, ref)
-	case DbDialectMySQL, DbDialectSQLite:
-		sql := fmt.Sprintf("delete from %s where Ref=?", tableName)
-		_, err = tx.Exec(sql, ref)
-	default:
+	if err = T.runBeforeDeleteHandlers(ctx, def, ref); err != nil {
 		_ = T.RollbackTran(tx)
-		return fmt.Errorf("Factory.DeleteEntity: unsupported db dialect: %d", T.dbDialect)
+		return fmt.Errorf("Factory.DeleteEntity: %w", err)
+	}
+
+	// owners cache their collections of this entity
+	deleted, _ := T.loadedEntities.Peek(ref)
+	if deleted == nil && T.hasCollectionsOf(def) {
+		deleted, _ = T.LoadEntityContext(ctx, ref)
 	}
 
+	ctx, err = T.applyDeleteRules(ctx, def, ref)
 	if err != nil {
 		_ = T.RollbackTran(tx)
-		return fmt.Errorf("Factory.DeleteEntity: failed to delete entity: %w", err)
+		return fmt.Errorf("Factory.DeleteEntity: %w", err)
 	}
 
-	T.loadedEntities.Remove(ref)
-
-	return T.CommitTran(tx)
-}
-
-// Database structure related methods
-
-func (T *Factory) refColumnType() (string, error) {
-	switch T.dbDialect {
-	case DbDialectPostgres, DbDialectMSSQL:
-		return "elorm_ref_type", nil
-	case DbDialectMySQL:
-		return fmt.Sprintf("VARCHAR(%d)", refFieldLength), nil
-	default:
-		return "", fmt.Errorf("factory.RefColumnType: unsupported db dialect: %d", T.dbDialect)
+	tableName, err := def.SqlTableName()
+	if err != nil {
+		_ = T.RollbackTran(tx)
+		return fmt.Errorf("Factory.DeleteEntity: failed to get SQL table name for entity %s: %w", def.ObjectName, err)
 	}
-}
-
-func (T *Factory) createRefColumnType() error {
 
-	switch T.dbDialect {
-	case DbDialectPostgres:
-
-		refObjIdDomain, err := T.refColumnType()
-		if err != nil {
-			return fmt.Errorf("Factory.createRefColumnType: failed to get ref column type: %w", err)
-		}
-
-		row, err := T.db.Query("select count(*) as cnt from pg_type where typname=$1", refObjIdDomain)
-		if err != nil {
-			return fmt.Errorf("Factory.createRefColumnType: failed to query pg_type: %w", err)
-		}
-		defer func() {
-			_ = row.Close()
-		}()
-
-		row.Next()
-		if err = row.Err(); err != nil {
-			return fmt.Errorf("Factory.createRefColumnType: row error: %w", err)
-		}
-
-		cnt := 0
-		if err = row.Scan(&cnt); err != nil {
-			return fmt.Errorf("Factory.createRefColumnType: failed to scan count: %w", err)
-		}
-
-		if cnt == 0 {
-
-			_, err = T.Exec(fmt.Sprintf("create domain %s as varchar(%d)", refObjIdDomain, refFieldLength))
-			if err != nil {
-				return fmt.Errorf("Factory.createRefColumnType: failed to create domain: %w", err)
-			}
-		}
-
-		return nil
-	case DbDialectMSSQL:
-		// For MSSQL, check if the type exists, if not, create it as a user-defined type (UDT)
-		refObjIdDomain, err := T.refColumnType()
-		if err != nil {
-			return fmt.Errorf("Factory.createRefColumnType: failed to get ref column type: %w", err)
-		}
-		var cnt int
-		sql := T.PrepareSql("SELECT COUNT(*) FROM sys.types WHERE name = $1", refObjIdDomain)
-		row := T.db.QueryRow(sql, refObjIdDomain)
-		err = row.Scan(&cnt)
-		if err != nil {
-			return fmt.Errorf("Factory.createRefColumnType: failed to query sys.types: %w", err)
-		}
-		if cnt == 0 {
-			_, err = T.Exec(fmt.Sprintf("CREATE TYPE %s FROM nvarchar(%d)", refObjIdDomain, refFieldLength))
-			if err != nil {
-				return fmt.Errorf("Factory.createRefColumnType: failed to create type: %w", err)
-			}
-		}
-		return nil
-	case DbDialectMySQL, DbDialectSQLite:
-		// MySQL does not support custom domains/types like Postgres/MSSQL, so just ensure columns use VARCHAR(107)
-		return nil
-	default:
-		return fmt.Errorf("factory.CreateRefColumnType: unsupported db dialect: %d", T.dbDialect)
+	if err = def.deleteTableParts(ctx, ref); err != nil {
+		_ = T.RollbackTran(tx)
+		return fmt.Errorf("Factory.DeleteEntity: %w", err)
+	}
+	if err = T.deleteLinks(ctx, def, ref); err != nil {
+		_ = T.RollbackTran(tx)
+		return fmt.Errorf("Factory.DeleteEntity: %w", err)
 	}
 
-}
-
-func (T *Factory) EnsureDBStructure() error {
-	err := T.createRefColumnType()
+	_, err = T.ExecContext(ctx, fmt.Sprintf("delete from %s where Ref=$1", tableName), ref)
 	if err != nil {
-		return fmt.Errorf("Factory.EnsureDBStructure: failed to create ref column type: %w", err)
+		_ = T.RollbackTran(tx)
+		return fmt.Errorf("Factory.DeleteEntity: failed to delete entity: %w", err)
 	}
 
-	for _, def := range T.EntityDefs {
-		err := def.ensureDBStructure()
-		if err != nil {
-			return fmt.Errorf("Factory.EnsureDBStructure: failed to ensure DB structure: %w", err)
-		}
+	T.loadedEntities.Remove(ref)
+	if deleted != nil {
+		deleted.resetOwnerCollections()
 	}
-	return nil
+
+	return T.CommitTran(tx)
 }
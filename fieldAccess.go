@@ -0,0 +1,35 @@
+package elorm
+
+import "context"
+
+// fieldAccess returns access mode of field for ctx. Ref is always read and written, it identifies entity.
+func (T *Factory) fieldAccess(ctx context.Context, fd *FieldDef) int {
+	if fd.Name == RefFieldName {
+		return FieldAccessReadWrite
+	}
+	if T.FieldAccessPolicy != nil {
+		return T.FieldAccessPolicy(ctx, fd)
+	}
+	return fd.Access
+}
+
+// canReadField checks that field is marshaled to JSON for ctx
+func (T *Factory) canReadField(ctx context.Context, fd *FieldDef) bool {
+	access := T.fieldAccess(ctx, fd)
+	return access == FieldAccessReadWrite || access == FieldAccessReadOnly
+}
+
+// canWriteField checks that field is accepted from JSON for ctx
+func (T *Factory) canWriteField(ctx context.Context, fd *FieldDef) bool {
+	return isWritableAccess(T.fieldAccess(ctx, fd))
+}
+
+// writableFields returns check of fields accepted from JSON for ctx, used to copy entities decoded from requests
+func (T *Factory) writableFields(ctx context.Context) func(fd *FieldDef) bool {
+	return func(fd *FieldDef) bool { return T.canWriteField(ctx, fd) }
+}
+
+// isWritableAccess checks that access mode accepts field from JSON
+func isWritableAccess(access int) bool {
+	return access == FieldAccessReadWrite || access == FieldAccessWriteOnly
+}
@@ -0,0 +1,184 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type faAdminKey struct{}
+
+func TestFieldAccess(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "access.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	userDef, _ := factory.CreateEntityDef("FaUser", "FaUsers")
+	_, _ = userDef.AddStringFieldDef("Username", 50)
+	hash, _ := userDef.AddStringFieldDef("PasswordHash", 100)
+	code, _ := userDef.AddStringFieldDef("TelegramCheckCode", 10)
+	hash.Access = FieldAccessWriteOnly
+	code.Access = FieldAccessHidden
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	ctx := context.Background()
+	newUser := func(name string, checkCode string) *Entity {
+		e, _ := factory.CreateEntity(userDef)
+		e.Values["Username"].(*FieldValueString).Set(name)
+		e.Values["PasswordHash"].(*FieldValueString).Set("h1")
+		e.Values["TelegramCheckCode"].(*FieldValueString).Set(checkCode)
+		if err := e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return e
+	}
+	user := newUser("bob", "42")
+	_ = newUser("ann", "17")
+	str := func(e *Entity, name string) string { return e.Values[name].AsString() }
+	keys := func(b []byte) map[string]any {
+		var m map[string]any
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", b, err)
+		}
+		return m
+	}
+
+	b, _ := json.Marshal(user)
+	if m := keys(b); m["Username"] != "bob" || m["IsDeleted"] != false || m["PasswordHash"] != nil || m["TelegramCheckCode"] != nil {
+		t.Errorf("MarshalJSON() = %s", b)
+	}
+
+	input, _ := factory.CreateEntity(userDef)
+	if err = json.Unmarshal([]byte(`{"Username": "eve", "PasswordHash": "h2", "TelegramCheckCode": "1", "IsDeleted": true, "DataVersion": "x"}`), input); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+	if str(input, "Username") != "eve" || str(input, "PasswordHash") != "h2" || str(input, "TelegramCheckCode") != "" || input.IsDeleted() || input.DataVersion() != "" {
+		t.Errorf("UnmarshalJSON() accepted values = %v", input.Values)
+	}
+
+	input.Values["TelegramCheckCode"].(*FieldValueString).Set("1")
+	if err = user.LoadFrom(input, false); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+	if str(user, "Username") != "eve" || str(user, "PasswordHash") != "h2" || str(user, "TelegramCheckCode") != "42" {
+		t.Errorf("LoadFrom() copied values = %v", user.Values)
+	}
+
+	if err = user.ApplyMergePatch([]byte(`{"TelegramCheckCode": "7", "IsDeleted": true, "PasswordHash": null}`)); err != nil {
+		t.Fatalf("ApplyMergePatch() error = %v", err)
+	}
+	if str(user, "TelegramCheckCode") != "42" || user.IsDeleted() || str(user, "PasswordHash") != "" {
+		t.Errorf("ApplyMergePatch() changed values = %v", user.Values)
+	}
+	if err = user.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// internal copies don't depend on policy
+	factory.FieldAccessPolicy = func(ctx context.Context, fd *FieldDef) int { return FieldAccessHidden }
+	clone, _ := factory.CreateEntity(userDef)
+	if err = clone.LoadFrom(user, false); err != nil || str(clone, "Username") != "eve" || str(clone, "TelegramCheckCode") != "" {
+		t.Errorf("LoadFrom() with policy error = %v, copied values = %v", err, clone.Values)
+	}
+
+	// admins see and change check codes
+	factory.FieldAccessPolicy = func(ctx context.Context, fd *FieldDef) int {
+		if fd == code && ctx.Value(faAdminKey{}) != nil {
+			return FieldAccessReadWrite
+		}
+		return fd.Access
+	}
+	admin := context.WithValue(ctx, faAdminKey{}, true)
+	if b, _ = user.MarshalJSONContext(admin); keys(b)["TelegramCheckCode"] != "42" {
+		t.Errorf("MarshalJSONContext() for admin = %s", b)
+	}
+	if b, _ = json.Marshal(user); keys(b)["TelegramCheckCode"] != nil {
+		t.Errorf("MarshalJSON() with policy = %s", b)
+	}
+
+	config := CreateStdRestApiConfig(userDef, factory.LoadEntity, userDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(userDef) })
+	config.EnablePatch = true
+	config.Context = func(r *http.Request) context.Context {
+		if r.Header.Get("X-Admin") != "" {
+			return context.WithValue(r.Context(), faAdminKey{}, true)
+		}
+		return r.Context()
+	}
+	do := func(isAdmin bool, method string, query string, body string) (int, map[string]any) {
+		r := httptest.NewRequest(method, "/users?"+query, strings.NewReader(body))
+		if isAdmin {
+			r.Header.Set("X-Admin", "1")
+		}
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, r)
+		if w.Body.Len() == 0 || w.Code >= 300 {
+			return w.Code, nil
+		}
+		return w.Code, keys(w.Body.Bytes())
+	}
+	ref := "ref=" + user.RefString()
+
+	if status, m := do(false, http.MethodGet, ref, ""); status != http.StatusOK || m["Username"] != "eve" || m["TelegramCheckCode"] != nil || m["PasswordHash"] != nil {
+		t.Errorf("GET = %d %v", status, m)
+	}
+	if _, m := do(true, http.MethodGet, ref, ""); m["TelegramCheckCode"] != "42" {
+		t.Errorf("GET for admin = %v", m)
+	}
+	etagFor := func(isAdmin bool, inm string) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, "/users?"+ref, nil)
+		r.Header.Set("If-None-Match", inm)
+		if isAdmin {
+			r.Header.Set("X-Admin", "1")
+		}
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, r)
+		return w.Code, w.Header().Get("ETag")
+	}
+	_, adminTag := etagFor(true, "")
+	if status, userTag := etagFor(false, adminTag); status != http.StatusOK || userTag == adminTag || userTag == EntityETag(user) {
+		t.Errorf("GET with admin ETag = %d, ETag %s, want 200 and other ETag", status, userTag)
+	}
+	if status, _ := etagFor(true, adminTag); status != http.StatusNotModified {
+		t.Errorf("GET for admin with admin ETag = %d, want 304", status)
+	}
+	if _, m := do(false, http.MethodGet, "TelegramCheckCode=42", ""); len(m["Data"].([]any)) != 2 {
+		t.Errorf("GET list with filter by hidden field = %v, filter should be ignored", m)
+	}
+	if status, _ := do(false, http.MethodGet, "filter=TelegramCheckCode+eq+'42'", ""); status != http.StatusBadRequest {
+		t.Errorf("GET list with filter expression by hidden field = %d, want 400", status)
+	}
+	if status, m := do(true, http.MethodGet, "filter=TelegramCheckCode+eq+'42'", ""); status != http.StatusOK || len(m["Data"].([]any)) != 1 {
+		t.Errorf("GET list for admin with filter expression = %d %v", status, m)
+	}
+
+	if status, _ := do(false, http.MethodPut, ref, `{"Username": "bob", "TelegramCheckCode": "9", "IsDeleted": true}`); status != http.StatusOK {
+		t.Fatalf("PUT = %d", status)
+	}
+	if str(user, "Username") != "bob" || str(user, "TelegramCheckCode") != "42" || user.IsDeleted() {
+		t.Errorf("PUT changed values = %v", user.Values)
+	}
+	if status, _ := do(true, http.MethodPatch, ref, `{"TelegramCheckCode": "9"}`); status != http.StatusOK || str(user, "TelegramCheckCode") != "9" {
+		t.Errorf("PATCH for admin = %d, TelegramCheckCode = %s", status, str(user, "TelegramCheckCode"))
+	}
+	if status, m := do(false, http.MethodPost, "", `{"Username": "joe", "TelegramCheckCode": "5"}`); status != http.StatusCreated || m["TelegramCheckCode"] != nil {
+		t.Errorf("POST = %d %v", status, m)
+	} else if created, _ := factory.LoadEntity(m["Ref"].(string)); str(created, "TelegramCheckCode") != "" {
+		t.Errorf("POST accepted hidden field")
+	}
+
+	doc, err := OpenApiDocument("Users", "1", CreateRestApiEndpoint("/users", config))
+	if err != nil {
+		t.Fatalf("OpenApiDocument() error = %v", err)
+	}
+	props := doc["components"].(map[string]any)["schemas"].(map[string]any)["FaUser"].(map[string]any)["properties"].(map[string]any)
+	if props["TelegramCheckCode"] != nil || props["PasswordHash"].(map[string]any)["writeOnly"] != true || props["IsDeleted"].(map[string]any)["readOnly"] != true ||
+		props["DataVersion"].(map[string]any)["readOnly"] != true {
+		t.Errorf("OpenAPI schema properties = %v", props)
+	}
+}
@@ -5,9 +5,11 @@ type IFieldValue interface {
 	Def() *FieldDef
 	Entity() *Entity
 	SqlStringValue(v ...any) (string, error)
+	SqlValue(v ...any) (any, error)
 	Scan(v any) error
 	AsString() string
 	resetOld()
+	isChanged() bool // value differs from the loaded or saved one
 }
 
 type fieldValueBase struct {
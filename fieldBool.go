@@ -41,6 +41,13 @@ func (T *FieldValueBool) resetOld() {
 	T.old = T.v
 }
 
+func (T *FieldValueBool) isChanged() bool {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	return T.v != T.old
+}
+
 func (T *FieldValueBool) SqlStringValue(v ...any) (string, error) {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -57,20 +64,26 @@ func (T *FieldValueBool) SqlStringValue(v ...any) (string, error) {
 	if T.def == nil || T.def.EntityDef == nil || T.def.EntityDef.Factory == nil {
 		return "", fmt.Errorf("FieldValueBool.SqlStringValue: missing definition or factory for field %s", T.def.Name)
 	}
-	switch T.def.EntityDef.Factory.dbDialect {
-	case DbDialectPostgres, DbDialectSQLite:
-		if v2 {
-			return "TRUE", nil
-		}
-		return "FALSE", nil
-	case DbDialectMSSQL, DbDialectMySQL:
-		if v2 {
-			return "1", nil
+	if T.def.EntityDef.Factory.dialect == nil {
+		return "", fmt.Errorf("FieldValueBool.SqlStringValue: unknown database dialect for field %s", T.def.Name)
+	}
+	return T.def.EntityDef.Factory.dialect.BoolLiteral(v2), nil
+}
+
+// SqlValue returns the value (or v[0] if provided) to be passed as a query parameter.
+func (T *FieldValueBool) SqlValue(v ...any) (any, error) {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	v2 := T.v
+	if len(v) == 1 {
+		ok := false
+		v2, ok = v[0].(bool)
+		if !ok {
+			return nil, fmt.Errorf("FieldValueBool.SqlValue: expected bool value for field %s, got %T", T.def.Name, v[0])
 		}
-		return "0", nil
-	default:
-		return "", fmt.Errorf("FieldValueBool.SqlStringValue: unknown database type %d for field %s", T.def.EntityDef.Factory.dbDialect, T.def.Name)
 	}
+	return v2, nil
 }
 
 func (T *FieldValueBool) AsString() string {
@@ -42,6 +42,13 @@ func (T *FieldValueDateTime) resetOld() {
 	T.old = T.v
 }
 
+func (T *FieldValueDateTime) isChanged() bool {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	return !T.v.Equal(T.old)
+}
+
 func (T *FieldValueDateTime) SqlStringValue(v ...any) (string, error) {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -66,6 +73,26 @@ func (T *FieldValueDateTime) SqlStringValue(v ...any) (string, error) {
 	return fmt.Sprintf("'%s'", v2.Format(T.def.DateTimeJSONFormat)), nil
 }
 
+// SqlValue returns the value (or v[0] if provided) to be passed as a query parameter. It is string in DateTimeJSONFormat
+// like in SqlStringValue, so stored values and filters keep the same format. Zero time is passed as NULL.
+func (T *FieldValueDateTime) SqlValue(v ...any) (any, error) {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	v2 := T.v
+	if len(v) == 1 {
+		ok := false
+		v2, ok = v[0].(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("FieldValueDateTime.SqlValue: expected time.Time value for field %s, got %T", T.def.Name, v[0])
+		}
+	}
+	if v2.IsZero() {
+		return nil, nil
+	}
+	return v2.Format(T.def.DateTimeJSONFormat), nil
+}
+
 func (T *FieldValueDateTime) AsString() string {
 	T.lock.Lock()
 	defer T.lock.Unlock()
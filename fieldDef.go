@@ -15,6 +15,31 @@ const (
 	FieldDefTypeDateTime = 600
 )
 
+// Foreign key constraint modes for ref fields (FieldDef.ForeignKey). Mode defines ON DELETE action.
+const (
+	ForeignKeyNone     = 0 // no database constraint
+	ForeignKeyRestrict = 100
+	ForeignKeyCascade  = 200
+	ForeignKeySetNull  = 300
+)
+
+// Delete rules for ref fields (FieldDef.DeleteRule), enforced by Factory.DeleteEntity when referenced entity is deleted.
+const (
+	DeleteRuleNone     = 0   // references are not checked
+	DeleteRuleRestrict = 100 // deletion is refused while entity is referenced
+	DeleteRuleCascade  = 200 // referencing entities are deleted too (soft deleted when their UseSoftDelete is true)
+	DeleteRuleClear    = 300 // references are cleared
+)
+
+// Access modes of fields in JSON (FieldDef.Access), respected by MarshalJSON, UnmarshalJSON, LoadFrom, ApplyMergePatch
+// and REST API. Factory.FieldAccessPolicy may decide them per request instead.
+const (
+	FieldAccessReadWrite = 0   // field is marshaled and accepted from JSON
+	FieldAccessHidden    = 100 // field is neither marshaled nor accepted from JSON
+	FieldAccessReadOnly  = 200 // field is marshaled, but ignored in JSON input
+	FieldAccessWriteOnly = 300 // field is accepted from JSON, but isn't marshaled
+)
+
 // FieldDef describes a field in an entity.
 type FieldDef struct {
 	Name string
@@ -25,6 +50,10 @@ type FieldDef struct {
 	Precision          int        //for numeric
 	Scale              int        //for numeric
 	DateTimeJSONFormat string     //for date time, e.g. "2006-01-02T15:04:05Z07:00"
+	RenamedFrom        []string   // previous field names, existing column with such name is renamed instead of creating a new one
+	ForeignKey         int        // for ref fields, ForeignKeyNone (default) or mode of FOREIGN KEY constraint created by EnsureDBStructure
+	DeleteRule         int        // for ref fields, DeleteRuleNone (default) or rule enforced by Factory.DeleteEntity
+	Access             int        // FieldAccessReadWrite (default) or mode restricting field in JSON and REST API
 }
 
 func (T *FieldDef) CreateFieldValue(entity *Entity) (IFieldValue, error) {
@@ -65,114 +94,38 @@ func (T *FieldDef) CreateFieldValue(entity *Entity) (IFieldValue, error) {
 }
 
 func (T *FieldDef) SqlColumnName() (string, error) {
-	switch T.EntityDef.Factory.dbDialect {
-	case DbDialectPostgres, DbDialectMSSQL, DbDialectMySQL, DbDialectSQLite:
-		return strings.ToLower(T.Name), nil
-	default:
-		return "", fmt.Errorf("FieldDef.SqlColumnName: Unknown database type")
+	if T.Name == "" {
+		return "", fmt.Errorf("FieldDef.SqlColumnName: field name is empty")
 	}
+	return strings.ToLower(T.Name), nil
 }
 
 func (T *FieldDef) SqlColumnType() (string, error) {
-	dialect := T.EntityDef.Factory.dbDialect
-	switch dialect {
-	case DbDialectPostgres:
-		return T.sqlColumnTypePostgres()
-	case DbDialectMSSQL:
-		return T.sqlColumnTypeMSSQL()
-	case DbDialectMySQL:
-		return T.sqlColumnTypeMySQL()
-	case DbDialectSQLite:
-		return T.sqlColumnTypeSQLite()
-	default:
-		return "", fmt.Errorf("FieldDef.SqlColumnType: unknown database type %d", dialect)
+	if T.EntityDef == nil || T.EntityDef.Factory == nil || T.EntityDef.Factory.dialect == nil {
+		return "", fmt.Errorf("FieldDef.SqlColumnType: missing factory or dialect for field %s", T.Name)
 	}
+	return T.EntityDef.Factory.dialect.ColumnType(T)
 }
 
-func (T *FieldDef) sqlColumnTypePostgres() (string, error) {
-	switch T.Type {
-	case FieldDefTypeString:
-		return fmt.Sprintf("varchar(%d)", T.Len), nil
-	case FieldDefTypeInt:
-		return "int", nil
-	case FieldDefTypeBool:
-		return "bool", nil
-	case FieldDefTypeRef:
-		t, err := T.EntityDef.Factory.refColumnType()
-		if err != nil {
-			return "", err
+func (T *EntityDef) checkName(name string) error {
+	for _, v := range T.FieldDefs {
+		if v.Name == name {
+			return fmt.Errorf("field %s already exists in entity %s", name, T.ObjectName)
 		}
-		return t, nil
-	case FieldDefTypeDateTime:
-		return "timestamp without time zone", nil
-	case FieldDefTypeNumeric:
-		return fmt.Sprintf("decimal(%d,%d)", T.Precision, T.Scale), nil
-	default:
-		return "", fmt.Errorf("fieldDef.sqlColumnTypePostgres: unknown field type: %d", T.Type)
 	}
-}
-
-func (T *FieldDef) sqlColumnTypeMSSQL() (string, error) {
-	switch T.Type {
-	case FieldDefTypeString:
-		return fmt.Sprintf("nvarchar(%d)", T.Len), nil
-	case FieldDefTypeInt:
-		return "bigint", nil
-	case FieldDefTypeBool:
-		return "bit", nil
-	case FieldDefTypeRef:
-		return fmt.Sprintf("nvarchar(%d)", refFieldLength), nil
-	case FieldDefTypeDateTime:
-		return "datetime", nil
-	case FieldDefTypeNumeric:
-		return fmt.Sprintf("decimal(%d,%d)", T.Precision, T.Scale), nil
-	default:
-		return "", fmt.Errorf("fieldDef.sqlColumnTypeMSSQL: unknown field type: %d", T.Type)
-	}
-}
-
-func (T *FieldDef) sqlColumnTypeMySQL() (string, error) {
-	switch T.Type {
-	case FieldDefTypeString:
-		return fmt.Sprintf("varchar(%d)", T.Len), nil
-	case FieldDefTypeInt:
-		return "int", nil
-	case FieldDefTypeBool:
-		return "tinyint(1)", nil
-	case FieldDefTypeRef:
-		return fmt.Sprintf("varchar(%d)", refFieldLength), nil
-	case FieldDefTypeDateTime:
-		return "datetime", nil
-	case FieldDefTypeNumeric:
-		return fmt.Sprintf("decimal(%d,%d)", T.Precision, T.Scale), nil
-	default:
-		return "", fmt.Errorf("fieldDef.sqlColumnTypeMySQL: unknown field type: %d", T.Type)
+	for _, v := range T.CollectionDefs {
+		if v.Name == name {
+			return fmt.Errorf("collection %s already exists in entity %s", name, T.ObjectName)
+		}
 	}
-}
-
-func (T *FieldDef) sqlColumnTypeSQLite() (string, error) {
-	switch T.Type {
-	case FieldDefTypeString:
-		return fmt.Sprintf("varchar(%d)", T.Len), nil
-	case FieldDefTypeInt:
-		return "integer", nil
-	case FieldDefTypeBool:
-		return "boolean", nil
-	case FieldDefTypeRef:
-		return fmt.Sprintf("varchar(%d)", refFieldLength), nil
-	case FieldDefTypeDateTime:
-		return "datetime", nil
-	case FieldDefTypeNumeric:
-		return fmt.Sprintf("decimal(%d,%d)", T.Precision, T.Scale), nil
-	default:
-		return "", fmt.Errorf("fieldDef.sqlColumnTypeSQLite: unknown field type: %d", T.Type)
+	for _, v := range T.TablePartDefs {
+		if v.Name == name {
+			return fmt.Errorf("table part %s already exists in entity %s", name, T.ObjectName)
+		}
 	}
-}
-
-func (T *EntityDef) checkName(name string) error {
-	for _, v := range T.FieldDefs {
+	for _, v := range T.ManyToManyDefs {
 		if v.Name == name {
-			return fmt.Errorf("field %s already exists in entity %s", name, T.ObjectName)
+			return fmt.Errorf("many-to-many relation %s already exists in entity %s", name, T.ObjectName)
 		}
 	}
 	return nil
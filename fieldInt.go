@@ -41,6 +41,13 @@ func (T *FieldValueInt) resetOld() {
 	T.old = T.v
 }
 
+func (T *FieldValueInt) isChanged() bool {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	return T.v != T.old
+}
+
 func (T *FieldValueInt) SqlStringValue(v ...any) (string, error) {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -64,6 +71,25 @@ func (T *FieldValueInt) SqlStringValue(v ...any) (string, error) {
 	return fmt.Sprintf("%d", v2), nil
 }
 
+// SqlValue returns the value (or v[0] if provided) to be passed as a query parameter.
+func (T *FieldValueInt) SqlValue(v ...any) (any, error) {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	v2 := T.v
+	if len(v) == 1 {
+		switch vt := v[0].(type) {
+		case int64:
+			v2 = vt
+		case int:
+			v2 = int64(vt)
+		default:
+			return nil, fmt.Errorf("FieldValueInt.SqlValue: expected int64 value for field %s, got %T", T.def.Name, v[0])
+		}
+	}
+	return v2, nil
+}
+
 func (T *FieldValueInt) AsString() string {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -48,6 +48,13 @@ func (T *FieldValueNumeric) resetOld() {
 	T.old = T.v
 }
 
+func (T *FieldValueNumeric) isChanged() bool {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	return T.v != T.old
+}
+
 func (T *FieldValueNumeric) mask() string {
 	return fmt.Sprintf("%%%d.%df", T.def.Precision, T.def.Scale)
 }
@@ -71,6 +78,22 @@ func (T *FieldValueNumeric) SqlStringValue(v ...any) (string, error) {
 	return strings.TrimSpace(fmt.Sprintf(T.mask(), v2)), nil
 }
 
+// SqlValue returns the value (or v[0] if provided) rounded to field scale to be passed as a query parameter.
+func (T *FieldValueNumeric) SqlValue(v ...any) (any, error) {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	v2 := T.v
+	if len(v) == 1 {
+		ok := false
+		v2, ok = v[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("FieldValueNumeric.SqlValue: expected float64 value for field %s, got %T", T.def.Name, v[0])
+		}
+	}
+	return T.rounded(v2), nil
+}
+
 func (T *FieldValueNumeric) AsString() string {
 	T.lock.Lock()
 	defer T.lock.Unlock()
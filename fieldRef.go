@@ -62,7 +62,7 @@ func (T *FieldValueRef) Set(newValue any) error {
 	}
 	ok, deft := T.factory.IsRef(stringValue)
 	if !ok {
-		return fmt.Errorf("FieldValueRef.Set: invalid ref %s", stringValue)
+		return fmt.Errorf("FieldValueRef.Set: %w %s", ErrInvalidRef, stringValue)
 	}
 
 	if T.def == nil {
@@ -70,7 +70,7 @@ func (T *FieldValueRef) Set(newValue any) error {
 	}
 
 	if T.def != nil && deft.ObjectName != T.def.EntityDef.ObjectName {
-		return fmt.Errorf("FieldValueRef.Set: ref %s does not match field type %s", deft.ObjectName, T.def.Name)
+		return fmt.Errorf("FieldValueRef.Set: %w: ref %s does not match field type %s", ErrInvalidRef, deft.ObjectName, T.def.Name)
 	}
 
 	T.v = stringValue
@@ -106,6 +106,13 @@ func (T *FieldValueRef) resetOld() {
 	T.old = T.v
 }
 
+func (T *FieldValueRef) isChanged() bool {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	return T.v != T.old
+}
+
 func (T *FieldValueRef) SqlStringValue(v ...any) (string, error) {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -128,6 +135,29 @@ func (T *FieldValueRef) SqlStringValue(v ...any) (string, error) {
 	return fmt.Sprintf("'%s'", v2), nil
 }
 
+// SqlValue returns the reference string (or v[0] if provided) to be passed as a query parameter.
+// Empty reference is NULL for fields with foreign key constraint.
+func (T *FieldValueRef) SqlValue(v ...any) (any, error) {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	v2 := T.v
+	if len(v) == 1 {
+		switch vt := v[0].(type) {
+		case string:
+			v2 = vt
+		case IEntity:
+			v2 = vt.RefString()
+		default:
+			return nil, fmt.Errorf("FieldValueRef.SqlValue: expected string value or IEntity for field %s, got %T", T.def.Name, v[0])
+		}
+	}
+	if v2 == "" && T.def != nil && T.def.ForeignKey != ForeignKeyNone {
+		return nil, nil
+	}
+	return v2, nil
+}
+
 func (T *FieldValueRef) AsString() string {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -141,6 +171,7 @@ func (T *FieldValueRef) Scan(v any) error {
 
 	if v == nil {
 		T.v = ""
+		T.old = T.v
 		return nil
 	}
 
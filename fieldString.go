@@ -34,6 +34,22 @@ func (T *FieldValueString) SqlStringValue(v ...any) (string, error) {
 	return fmt.Sprintf("'%s'", v2), nil
 }
 
+// SqlValue returns the value (or v[0] if provided) to be passed as a query parameter.
+func (T *FieldValueString) SqlValue(v ...any) (any, error) {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	v2 := T.v
+	if len(v) == 1 {
+		ok := false
+		v2, ok = v[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("FieldValueString.SqlValue: expected string value for field %s, got %T", T.def.Name, v[0])
+		}
+	}
+	return v2, nil
+}
+
 func (T *FieldValueString) Set(newValue string) {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -62,6 +78,13 @@ func (T *FieldValueString) resetOld() {
 	T.old = T.v
 }
 
+func (T *FieldValueString) isChanged() bool {
+	T.lock.Lock()
+	defer T.lock.Unlock()
+
+	return T.v != T.old
+}
+
 func (T *FieldValueString) AsString() string {
 	T.lock.Lock()
 	defer T.lock.Unlock()
@@ -31,6 +31,31 @@ func TestFieldValueString_SqlStringValue(t *testing.T) {
 	}
 }
 
+func TestFieldValueString_SqlValue(t *testing.T) {
+	field := mockFieldValueString()
+	field.Set("testValue")
+	testCases := []struct {
+		values []any
+		exp    any
+		expErr bool
+	}{
+		{values: []any{}, exp: "testValue", expErr: false},
+		{values: []any{"'); drop table x; --"}, exp: "'); drop table x; --", expErr: false},
+		{values: []any{123}, exp: nil, expErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			v, err := field.SqlValue(tc.values...)
+			if (err != nil) != tc.expErr {
+				t.Errorf("expected error: %v, got: %v", tc.expErr, err != nil)
+			}
+			if v != tc.exp {
+				t.Errorf("expected: %v, got: %v", tc.exp, v)
+			}
+		})
+	}
+}
+
 func TestFieldValueString_SetAndGet(t *testing.T) {
 	field := mockFieldValueString()
 
@@ -0,0 +1,362 @@
+package elorm
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Tokens of filter expressions
+const (
+	filterTokenEnd    = 100
+	filterTokenIdent  = 200
+	filterTokenString = 300
+	filterTokenNumber = 400
+	filterTokenLParen = 500
+	filterTokenRParen = 600
+	filterTokenComma  = 700
+)
+
+// filterExprMaxDepth limits nesting of parentheses, expressions come from requests and parser is recursive
+const filterExprMaxDepth = 32
+
+type filterToken struct {
+	kind int
+	text string
+	pos  int // 1-based position in expression
+}
+
+var filterExprOps = map[string]int{
+	"eq":   FilterEQ,
+	"ne":   FilterNOEQ,
+	"gt":   FilterGT,
+	"ge":   FilterGE,
+	"lt":   FilterLT,
+	"le":   FilterLE,
+	"like": FilterLIKE,
+}
+
+func tokenizeFilterExpr(expr string) ([]filterToken, error) {
+	res := make([]filterToken, 0)
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			res = append(res, filterToken{kind: filterTokenLParen, text: "(", pos: i + 1})
+			i++
+		case c == ')':
+			res = append(res, filterToken{kind: filterTokenRParen, text: ")", pos: i + 1})
+			i++
+		case c == ',':
+			res = append(res, filterToken{kind: filterTokenComma, text: ",", pos: i + 1})
+			i++
+		case c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string at position %d", start+1)
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' { // '' is escaped quote
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			res = append(res, filterToken{kind: filterTokenString, text: sb.String(), pos: start + 1})
+		case c == '-' || unicode.IsDigit(c):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			res = append(res, filterToken{kind: filterTokenNumber, text: string(runes[start:i]), pos: start + 1})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			res = append(res, filterToken{kind: filterTokenIdent, text: string(runes[start:i]), pos: start + 1})
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i+1)
+		}
+	}
+	res = append(res, filterToken{kind: filterTokenEnd, pos: len(runes) + 1})
+	return res, nil
+}
+
+type filterParser struct {
+	def     *EntityDef
+	allowed []string
+	tokens  []filterToken
+	pos     int
+	depth   int // nesting of parentheses
+}
+
+func (T *filterParser) peek() filterToken {
+	return T.tokens[T.pos]
+}
+
+func (T *filterParser) next() filterToken {
+	t := T.tokens[T.pos]
+	if t.kind != filterTokenEnd {
+		T.pos++
+	}
+	return t
+}
+
+// keyword checks if current token is keyword (case insensitive) and skips it
+func (T *filterParser) keyword(kw string) bool {
+	t := T.peek()
+	if t.kind == filterTokenIdent && strings.EqualFold(t.text, kw) {
+		T.pos++
+		return true
+	}
+	return false
+}
+
+func unexpectedFilterToken(t filterToken) error {
+	if t.kind == filterTokenEnd {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected '%s' at position %d", t.text, t.pos)
+}
+
+func (T *filterParser) parseOr() (*Filter, error) {
+	left, err := T.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	childs := []*Filter{left}
+	for T.keyword("or") {
+		right, err := T.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		childs = append(childs, right)
+	}
+	if len(childs) == 1 {
+		return left, nil
+	}
+	return AddOrGroup(childs...), nil
+}
+
+func (T *filterParser) parseAnd() (*Filter, error) {
+	left, err := T.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	childs := []*Filter{left}
+	for T.keyword("and") {
+		right, err := T.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		childs = append(childs, right)
+	}
+	if len(childs) == 1 {
+		return left, nil
+	}
+	return AddAndGroup(childs...), nil
+}
+
+func (T *filterParser) parseTerm() (*Filter, error) {
+	if T.peek().kind == filterTokenLParen {
+		t := T.next()
+		if T.depth >= filterExprMaxDepth {
+			return nil, fmt.Errorf("%w: parentheses are nested deeper than %d levels at position %d", ErrValidation, filterExprMaxDepth, t.pos)
+		}
+		T.depth++
+		defer func() { T.depth-- }()
+		res, err := T.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := T.next(); t.kind != filterTokenRParen {
+			return nil, unexpectedFilterToken(t)
+		}
+		return res, nil
+	}
+	return T.parseCondition()
+}
+
+// field resolves field name or path (e.g. OwnerShop.Caption) and checks it is allowed
+func (T *filterParser) field(t filterToken) (*FieldDef, []*FieldDef, error) {
+	if t.kind != filterTokenIdent {
+		return nil, nil, unexpectedFilterToken(t)
+	}
+	names := strings.Split(t.text, ".")
+	allowed := len(names) == 1
+	if T.allowed != nil {
+		allowed = slices.ContainsFunc(T.allowed, func(s string) bool { return strings.EqualFold(s, t.text) })
+	}
+	if !allowed {
+		return nil, nil, fmt.Errorf("field %s isn't allowed in filter at position %d", t.text, t.pos)
+	}
+	def := T.def
+	path := make([]*FieldDef, 0, len(names)-1)
+	for i, name := range names {
+		fd := def.FieldDefByName(name)
+		if fd == nil {
+			return nil, nil, fmt.Errorf("unknown field %s at position %d", t.text, t.pos)
+		}
+		if i == len(names)-1 {
+			return fd, path, nil
+		}
+		if fd.Type != FieldDefTypeRef {
+			return nil, nil, fmt.Errorf("field %s isn't ref field in %s at position %d", name, t.text, t.pos)
+		}
+		path = append(path, fd)
+		def = fd.EntityDef
+	}
+	return nil, nil, fmt.Errorf("empty field name at position %d", t.pos)
+}
+
+// value converts literal token to Go value of field type
+func (T *filterParser) value(fd *FieldDef, t filterToken) (any, error) {
+	wrongType := fmt.Errorf("wrong value '%s' for %s at position %d", t.text, fd.Name, t.pos)
+	switch fd.Type {
+	case FieldDefTypeString:
+		if t.kind == filterTokenString {
+			return t.text, nil
+		}
+	case FieldDefTypeInt:
+		if t.kind == filterTokenNumber {
+			if v, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+				return v, nil
+			}
+		}
+	case FieldDefTypeNumeric:
+		if t.kind == filterTokenNumber {
+			if v, err := strconv.ParseFloat(t.text, 64); err == nil {
+				return v, nil
+			}
+		}
+	case FieldDefTypeBool:
+		if t.kind == filterTokenIdent && (strings.EqualFold(t.text, "true") || strings.EqualFold(t.text, "false")) {
+			return strings.EqualFold(t.text, "true"), nil
+		}
+	case FieldDefTypeDateTime:
+		if t.kind == filterTokenString {
+			if v, err := time.Parse(fd.DateTimeJSONFormat, t.text); err == nil {
+				return v, nil
+			}
+		}
+	case FieldDefTypeRef:
+		if t.kind == filterTokenString {
+			if t.text == "" {
+				return t.text, nil
+			}
+			if ok, def := T.def.Factory.IsRef(t.text); ok && def == fd.EntityDef {
+				return t.text, nil
+			}
+		}
+	}
+	return nil, wrongType
+}
+
+func (T *filterParser) parseCondition() (*Filter, error) {
+	ft := T.next()
+	fd, path, err := T.field(ft)
+	if err != nil {
+		return nil, err
+	}
+	opToken := T.next()
+	if opToken.kind != filterTokenIdent {
+		return nil, unexpectedFilterToken(opToken)
+	}
+	op := strings.ToLower(opToken.text)
+	switch op {
+	case "is":
+		not := T.keyword("not")
+		if !T.keyword("null") {
+			return nil, unexpectedFilterToken(T.peek())
+		}
+		if not {
+			return AddFilterIsNOTNULL(fd).Through(path...), nil
+		}
+		return AddFilterIsNULL(fd).Through(path...), nil
+	case "in", "not":
+		if op == "not" && !T.keyword("in") {
+			return nil, unexpectedFilterToken(T.peek())
+		}
+		if t := T.next(); t.kind != filterTokenLParen {
+			return nil, unexpectedFilterToken(t)
+		}
+		values := make([]any, 0)
+		for {
+			v, err := T.value(fd, T.next())
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			t := T.next()
+			if t.kind == filterTokenRParen {
+				break
+			}
+			if t.kind != filterTokenComma {
+				return nil, unexpectedFilterToken(t)
+			}
+		}
+		if op == "not" {
+			return AddFilterNOTIN(fd, values...).Through(path...), nil
+		}
+		return AddFilterIN(fd, values...).Through(path...), nil
+	}
+	filterOp, ok := filterExprOps[op]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator '%s' at position %d", opToken.text, opToken.pos)
+	}
+	if filterOp == FilterLIKE && fd.Type != FieldDefTypeString {
+		return nil, fmt.Errorf("like isn't supported for non-string field %s at position %d", fd.Name, opToken.pos)
+	}
+	vt := T.next()
+	if (filterOp == FilterEQ || filterOp == FilterNOEQ) && vt.kind == filterTokenIdent && strings.EqualFold(vt.text, "null") {
+		if filterOp == FilterEQ {
+			return AddFilterIsNULL(fd).Through(path...), nil
+		}
+		return AddFilterIsNOTNULL(fd).Through(path...), nil
+	}
+	v, err := T.value(fd, vt)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{Op: filterOp, LeftOp: fd, RightOp: v, Path: path}, nil
+}
+
+// ParseFilter parses filter expression into filter tree, e.g.
+//
+//	Price gt 10 and (Caption like 'a%' or IsActive eq true) and OwnerShop.Caption in ('A', 'B')
+//
+// Operators are eq, ne, gt, ge, lt, le, like, in (...), not in (...), is null, is not null, conditions are
+// combined by and, or and parentheses. Strings, dates (in DateTimeJSONFormat of field) and refs are quoted by
+// single quotes, quote inside string is doubled. Parentheses may be nested up to 32 levels. Values are checked against field types. Allowed lists names of fields and
+// ref paths which may be used in expression, nil allows all fields of entity without paths.
+func (T *EntityDef) ParseFilter(expr string, allowed []string) (*Filter, error) {
+	tokens, err := tokenizeFilterExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.ParseFilter: %w", err)
+	}
+	p := &filterParser{def: T, allowed: allowed, tokens: tokens}
+	res, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.ParseFilter: %w", err)
+	}
+	if t := p.peek(); t.kind != filterTokenEnd {
+		return nil, fmt.Errorf("EntityDef.ParseFilter: %w", unexpectedFilterToken(t))
+	}
+	return res, nil
+}
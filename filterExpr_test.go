@@ -0,0 +1,139 @@
+package elorm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntityDef_ParseFilter(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "filterexpr.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	shopDef, _ := factory.CreateEntityDef("FeShop", "FeShops")
+	_, _ = shopDef.AddStringFieldDef("Caption", 50)
+	goodDef, _ := factory.CreateEntityDef("FeGood", "FeGoods")
+	caption, _ := goodDef.AddStringFieldDef("Caption", 50)
+	_, _ = goodDef.AddNumericFieldDef("Price", 10, 2)
+	_, _ = goodDef.AddIntFieldDef("Qty")
+	_, _ = goodDef.AddBoolFieldDef("IsActive")
+	_, _ = goodDef.AddDateTimeFieldDef("Since")
+	_, _ = goodDef.AddRefFieldDef("OwnerShop", shopDef)
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	shopA, _ := factory.CreateEntity(shopDef)
+	shopA.Values["Caption"].(*FieldValueString).Set("A")
+	_ = shopA.Save(ctx)
+	shopB, _ := factory.CreateEntity(shopDef)
+	shopB.Values["Caption"].(*FieldValueString).Set("B")
+	_ = shopB.Save(ctx)
+	goods := []struct {
+		caption string
+		price   float64
+		qty     int64
+		active  bool
+		shop    *Entity
+	}{
+		{"apple", 5, 1, true, shopA},
+		{"apricot", 15, 2, false, shopA},
+		{"banana", 25, 3, true, shopB},
+		{"o'range", 35, 4, false, nil},
+	}
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, g := range goods {
+		e, _ := factory.CreateEntity(goodDef)
+		e.Values["Caption"].(*FieldValueString).Set(g.caption)
+		e.Values["Price"].(*FieldValueNumeric).Set(g.price)
+		e.Values["Qty"].(*FieldValueInt).Set(g.qty)
+		e.Values["IsActive"].(*FieldValueBool).Set(g.active)
+		e.Values["Since"].(*FieldValueDateTime).Set(since.AddDate(0, i, 0))
+		if g.shop != nil {
+			_ = e.Values["OwnerShop"].(*FieldValueRef).Set(g.shop)
+		}
+		if err = e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		expr    string
+		allowed []string
+		want    string
+		wantErr string
+	}{
+		{expr: "Price gt 10 and (Caption like 'a%' or IsActive eq true)", want: "apricot,banana"},
+		{expr: "qty IN (1, 3) or Caption eq 'o''range'", want: "apple,banana,o'range"},
+		{expr: "Qty not in (1,2) and not_a_field eq 1", wantErr: "unknown field"},
+		{expr: "Price ge 15 AND Price le 25.5", want: "apricot,banana"},
+		{expr: "OwnerShop eq '" + shopB.RefString() + "'", want: "banana"},
+		{expr: "OwnerShop.Caption eq 'A'", allowed: []string{"OwnerShop.Caption"}, want: "apple,apricot"},
+		{expr: "OwnerShop.Caption eq 'A'", wantErr: "isn't allowed"},
+		{expr: "Caption eq 'apple'", allowed: []string{"Price"}, wantErr: "isn't allowed"},
+		{expr: "Since lt '" + since.AddDate(0, 2, 0).Format(goodDef.FieldDefByName("Since").DateTimeJSONFormat) + "'", want: "apple,apricot"},
+		{expr: "Qty ne 1 and Qty is not null", want: "apricot,banana,o'range"},
+		{expr: "Price gt 'x'", wantErr: "wrong value 'x' for Price at position 10"},
+		{expr: "Qty eq 1.5", wantErr: "wrong value"},
+		{expr: "Price like '1%'", wantErr: "like isn't supported"},
+		{expr: "OwnerShop eq '" + shopA.Values["Ref"].AsString() + "x'", wantErr: "wrong value"},
+		{expr: "(Qty eq 1", wantErr: "unexpected end of expression"},
+		{expr: "Qty eq 1)", wantErr: "unexpected ')' at position 9"},
+		{expr: "Qty between 1", wantErr: "unknown operator 'between'"},
+		{expr: "Caption eq 'abc", wantErr: "unterminated string"},
+		{expr: "Qty eq 1 ; drop table x", wantErr: "unexpected character ';'"},
+		{expr: strings.Repeat("(", 32) + "Qty eq 1" + strings.Repeat(")", 32), want: "apple"},
+		{expr: strings.Repeat("(", 33) + "Qty eq 1" + strings.Repeat(")", 33), wantErr: "nested deeper than 32 levels at position 33"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := goodDef.ParseFilter(tt.expr, tt.allowed)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseFilter() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			list, _, err := goodDef.SelectEntities([]*Filter{filter}, []*SortItem{{Field: caption, Asc: true}}, 0, 0)
+			if err != nil {
+				t.Fatalf("SelectEntities() error = %v", err)
+			}
+			got := make([]string, 0, len(list))
+			for _, e := range list {
+				got = append(got, e.Values["Caption"].AsString())
+			}
+			if !slices.Equal(got, strings.Split(tt.want, ",")) {
+				t.Errorf("SelectEntities() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	config := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	handler := HandleRestApi(config)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/goods?"+url.Values{"filter": {"Price gt 'x'"}}.Encode(), nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "wrong value") {
+		t.Errorf("GET with invalid filter = %d %s, want 400", w.Code, w.Body.String())
+	}
+	if _, err := goodDef.ParseFilter(strings.Repeat("(", 100000), nil); !errors.Is(err, ErrValidation) {
+		t.Errorf("ParseFilter() of deeply nested expression error = %v, want ErrValidation", err)
+	}
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/goods?"+url.Values{"filter": {"Price gt 10 and IsActive eq true"}}.Encode(), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "banana") || strings.Contains(w.Body.String(), "apricot") {
+		t.Errorf("GET with filter = %d %s", w.Code, w.Body.String())
+	}
+}
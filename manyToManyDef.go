@@ -0,0 +1,297 @@
+package elorm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Predefined fields of many-to-many link tables
+const (
+	ManyToManyOwnerFieldName  = "Owner"
+	ManyToManyTargetFieldName = "Target"
+)
+
+// ManyToManyDef describes many-to-many relation between Owner and Target entities. Links are stored in the link table
+// managed by elorm, LinkDef describes it.
+type ManyToManyDef struct {
+	Name        string
+	Owner       *EntityDef
+	Target      *EntityDef
+	LinkDef     *EntityDef // entity definition of link table
+	OwnerField  *FieldDef  // link ref to owner
+	TargetField *FieldDef  // link ref to target
+}
+
+// AddManyToMany adds many-to-many relation with target entities. Link table name is owner table name + "_" + name,
+// EnsureDBStructure creates it with unique (Owner, Target) index and index by Target.
+func (T *EntityDef) AddManyToMany(name string, target *EntityDef) (*ManyToManyDef, error) {
+	if err := T.checkName(name); err != nil {
+		return nil, fmt.Errorf("EntityDef.AddManyToMany: %w", err)
+	}
+	if target == nil {
+		return nil, fmt.Errorf("EntityDef.AddManyToMany: target is nil for %s", name)
+	}
+	linkDef, err := T.Factory.CreateEntityDef(T.ObjectName+name, T.TableName+"_"+name)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.AddManyToMany: failed to create link definition: %w", err)
+	}
+	linkDef.DataVersionCheckMode = DataVersionCheckNever
+	nr := &ManyToManyDef{Name: name, Owner: T, Target: target, LinkDef: linkDef}
+	nr.OwnerField, err = linkDef.AddRefFieldDef(ManyToManyOwnerFieldName, T)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.AddManyToMany: failed to create owner field: %w", err)
+	}
+	nr.TargetField, err = linkDef.AddRefFieldDef(ManyToManyTargetFieldName, target)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.AddManyToMany: failed to create target field: %w", err)
+	}
+	if err = linkDef.AddIndex(true, nr.OwnerField, nr.TargetField); err != nil {
+		return nil, fmt.Errorf("EntityDef.AddManyToMany: failed to create owner index: %w", err)
+	}
+	if err = linkDef.AddIndex(false, nr.TargetField); err != nil {
+		return nil, fmt.Errorf("EntityDef.AddManyToMany: failed to create target index: %w", err)
+	}
+	T.ManyToManyDefs = append(T.ManyToManyDefs, nr)
+	return nr, nil
+}
+
+// ManyToManyDefByName returns the many-to-many relation definition with the specified name.
+func (T *EntityDef) ManyToManyDefByName(name string) *ManyToManyDef {
+	for _, v := range T.ManyToManyDefs {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// AddFilterHasAny creates a filter for owner entities linked by relation to any of target refs.
+func AddFilterHasAny(relation *ManyToManyDef, targets ...any) *Filter {
+	if relation == nil || len(targets) == 0 {
+		return nil
+	}
+	return &Filter{
+		Op:       FilterHasAny,
+		LeftOp:   relation.TargetField,
+		RightOp:  targets,
+		Relation: relation,
+	}
+}
+
+// renderHasAny renders condition "ref in (select owner from link where target in (...))"
+func (T *ManyToManyDef) renderHasAny(f *Factory, targets []any, args *[]any, joins *sqlJoins) (string, error) {
+	linkTable, err := T.LinkDef.SqlTableName()
+	if err != nil {
+		return "", fmt.Errorf("ManyToManyDef.renderHasAny: %w", err)
+	}
+	ownerColumn, err := T.OwnerField.SqlColumnName()
+	if err != nil {
+		return "", fmt.Errorf("ManyToManyDef.renderHasAny: %w", err)
+	}
+	in, err := AddFilterIN(T.TargetField, targets...).renderWhereClause(f, args)
+	if err != nil {
+		return "", fmt.Errorf("ManyToManyDef.renderHasAny: %w", err)
+	}
+	refColumn, err := joins.column(nil, T.Owner.RefField)
+	if err != nil {
+		return "", fmt.Errorf("ManyToManyDef.renderHasAny: %w", err)
+	}
+	return fmt.Sprintf("%s IN (select %s from %s where %s)", refColumn, ownerColumn, linkTable, in), nil
+}
+
+// ManyToMany holds links of an entity by many-to-many relation. Links are loaded on first access
+// and stored by owner Save: only added and removed links are written.
+type ManyToMany struct {
+	Def     *ManyToManyDef
+	owner   *Entity
+	saved   []string // links in database
+	current []string
+	loaded  bool
+}
+
+func (T *ManyToMany) load(ctx context.Context) error {
+	if T.loaded {
+		return nil
+	}
+	if !T.owner.isNew {
+		linkTable, err := T.Def.LinkDef.SqlTableName()
+		if err != nil {
+			return fmt.Errorf("ManyToMany.load: %w", err)
+		}
+		rows, err := T.owner.Factory.QueryContext(ctx, fmt.Sprintf("select %s from %s where %s=$1 order by %s",
+			strings.ToLower(ManyToManyTargetFieldName), linkTable, strings.ToLower(ManyToManyOwnerFieldName), strings.ToLower(ManyToManyTargetFieldName)),
+			T.owner.RefString())
+		if err != nil {
+			return fmt.Errorf("ManyToMany.load: failed to query links of %s: %w", T.Def.Name, err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		saved, err := scanStrings(rows)
+		if err != nil {
+			return fmt.Errorf("ManyToMany.load: failed to scan links of %s: %w", T.Def.Name, err)
+		}
+		T.saved = saved
+	}
+	T.current = slices.Clone(T.saved)
+	T.loaded = true
+	return nil
+}
+
+// targetRef checks target type and returns its ref
+func (T *ManyToMany) targetRef(target any) (string, error) {
+	ref := ""
+	switch v := target.(type) {
+	case string:
+		ref = v
+	case IEntity:
+		if !isNilInterfaceValue(v) {
+			ref = v.RefString()
+		}
+	default:
+		return "", fmt.Errorf("expected string or IEntity, got %T", target)
+	}
+	ok, def := T.owner.Factory.IsRef(ref)
+	if !ok || def != T.Def.Target {
+		return "", fmt.Errorf("ref %s is not %s", ref, T.Def.Target.ObjectName)
+	}
+	return ref, nil
+}
+
+// Add links targets (refs or entities). Already linked targets are skipped.
+func (T *ManyToMany) Add(targets ...any) error {
+	if err := T.load(context.Background()); err != nil {
+		return fmt.Errorf("ManyToMany.Add: %w", err)
+	}
+	for _, v := range targets {
+		ref, err := T.targetRef(v)
+		if err != nil {
+			return fmt.Errorf("ManyToMany.Add: %w", err)
+		}
+		if !slices.Contains(T.current, ref) {
+			T.current = append(T.current, ref)
+		}
+	}
+	return nil
+}
+
+// Remove unlinks targets (refs or entities).
+func (T *ManyToMany) Remove(targets ...any) error {
+	if err := T.load(context.Background()); err != nil {
+		return fmt.Errorf("ManyToMany.Remove: %w", err)
+	}
+	for _, v := range targets {
+		ref, err := T.targetRef(v)
+		if err != nil {
+			return fmt.Errorf("ManyToMany.Remove: %w", err)
+		}
+		T.current = slices.DeleteFunc(T.current, func(s string) bool { return s == ref })
+	}
+	return nil
+}
+
+// List returns refs of linked targets.
+func (T *ManyToMany) List() ([]string, error) {
+	if err := T.load(context.Background()); err != nil {
+		return nil, fmt.Errorf("ManyToMany.List: %w", err)
+	}
+	return slices.Clone(T.current), nil
+}
+
+// ManyToMany returns links of the entity by relation name, or nil when relation isn't defined.
+func (T *Entity) ManyToMany(name string) *ManyToMany {
+	return T.links[name]
+}
+
+// saveLinks writes added and removed links inside the owner transaction
+func (T *Entity) saveLinks(ctx context.Context) error {
+	for _, mtm := range T.links {
+		if !mtm.loaded {
+			continue
+		}
+		removed := make([]any, 0)
+		for _, ref := range mtm.saved {
+			if !slices.Contains(mtm.current, ref) {
+				removed = append(removed, ref)
+			}
+		}
+		if len(removed) > 0 {
+			linkTable, err := mtm.Def.LinkDef.SqlTableName()
+			if err != nil {
+				return fmt.Errorf("Entity.saveLinks: %w", err)
+			}
+			args := []any{T.RefString()}
+			in, err := AddFilterIN(mtm.Def.TargetField, removed...).renderWhereClause(T.Factory, &args)
+			if err != nil {
+				return fmt.Errorf("Entity.saveLinks: %w", err)
+			}
+			_, err = T.Factory.ExecContext(ctx, fmt.Sprintf("delete from %s where %s=$1 and %s", linkTable, strings.ToLower(ManyToManyOwnerFieldName), in), args...)
+			if err != nil {
+				return fmt.Errorf("Entity.saveLinks: failed to delete links of %s: %w", mtm.Def.Name, err)
+			}
+		}
+		for _, ref := range mtm.current {
+			if slices.Contains(mtm.saved, ref) {
+				continue
+			}
+			link, err := T.Factory.CreateEntity(mtm.Def.LinkDef)
+			if err != nil {
+				return fmt.Errorf("Entity.saveLinks: failed to create link of %s: %w", mtm.Def.Name, err)
+			}
+			if err = link.Values[ManyToManyOwnerFieldName].(*FieldValueRef).Set(T); err != nil {
+				return fmt.Errorf("Entity.saveLinks: %w", err)
+			}
+			if err = link.Values[ManyToManyTargetFieldName].(*FieldValueRef).Set(ref); err != nil {
+				return fmt.Errorf("Entity.saveLinks: %w", err)
+			}
+			if err = link.Save(ctx); err != nil {
+				return fmt.Errorf("Entity.saveLinks: failed to save link of %s: %w", mtm.Def.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// linksSaved marks current links as stored to database, it is called after owner transaction is committed
+func (T *Entity) linksSaved() {
+	for _, mtm := range T.links {
+		if mtm.loaded {
+			mtm.saved = slices.Clone(mtm.current)
+		}
+	}
+}
+
+// deleteLinks deletes links of deleted entity, both as owner and as target
+func (T *Factory) deleteLinks(ctx context.Context, def *EntityDef, ref string) error {
+	seen := make(map[*EntityDef]bool, len(T.EntityDefs))
+	for _, ed := range T.EntityDefs {
+		if seen[ed] {
+			continue
+		}
+		seen[ed] = true
+		for _, mtm := range ed.ManyToManyDefs {
+			columns := make([]string, 0, 2)
+			if mtm.Owner == def {
+				columns = append(columns, strings.ToLower(ManyToManyOwnerFieldName))
+			}
+			if mtm.Target == def {
+				columns = append(columns, strings.ToLower(ManyToManyTargetFieldName))
+			}
+			if len(columns) == 0 {
+				continue
+			}
+			linkTable, err := mtm.LinkDef.SqlTableName()
+			if err != nil {
+				return fmt.Errorf("Factory.deleteLinks: %w", err)
+			}
+			for _, col := range columns {
+				if _, err = T.ExecContext(ctx, fmt.Sprintf("delete from %s where %s=$1", linkTable, col), ref); err != nil {
+					return fmt.Errorf("Factory.deleteLinks: failed to delete links of %s.%s: %w", ed.ObjectName, mtm.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
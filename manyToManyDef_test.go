@@ -0,0 +1,125 @@
+package elorm
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEntity_ManyToMany(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "m2m.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	goodDef, _ := factory.CreateEntityDef("MmGood", "MmGoods")
+	tagDef, _ := factory.CreateEntityDef("MmTag", "MmTags")
+	tags, err := goodDef.AddManyToMany("Tags", tagDef)
+	if err != nil {
+		t.Fatalf("AddManyToMany() error = %v", err)
+	}
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	idx, err := factory.dialect.TableIndexes(factory, "mmgoods_tags")
+	if err != nil || len(idx) != 2 {
+		t.Errorf("link table indexes = %v, %v", idx, err)
+	}
+
+	ctx := context.Background()
+	newEntity := func(def *EntityDef) *Entity {
+		e, _ := factory.CreateEntity(def)
+		if err := e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return e
+	}
+	tag1, tag2, tag3 := newEntity(tagDef), newEntity(tagDef), newEntity(tagDef)
+	good1, _ := factory.CreateEntity(goodDef)
+	good2 := newEntity(goodDef)
+
+	if err = good1.ManyToMany("Tags").Add(good2); err == nil {
+		t.Errorf("Add() with wrong target type should fail")
+	}
+	if err = good1.ManyToMany("Tags").Add(tag1, tag2.RefString(), tag1); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err = good1.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err = good2.ManyToMany("Tags").Add(tag3); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err = good2.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	linkRefs := func() []string {
+		links, _, err := tags.LinkDef.SelectEntities(nil, nil, 0, 0)
+		if err != nil {
+			t.Fatalf("SelectEntities() error = %v", err)
+		}
+		res := make([]string, 0, len(links))
+		for _, l := range links {
+			res = append(res, l.RefString())
+		}
+		return res
+	}
+	before := linkRefs()
+
+	factory.loadedEntities.Purge()
+	loaded, _ := factory.LoadEntity(good1.RefString())
+	list, err := loaded.ManyToMany("Tags").List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []string{tag1.RefString(), tag2.RefString()}
+	slices.Sort(want)
+	if !slices.Equal(list, want) {
+		t.Errorf("List() = %v, want %v", list, want)
+	}
+
+	// only changed links are written
+	_ = loaded.ManyToMany("Tags").Remove(tag1)
+	_ = loaded.ManyToMany("Tags").Add(tag3)
+	if err = loaded.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	after := linkRefs()
+	if len(after) != 3 {
+		t.Fatalf("links count = %d, want 3", len(after))
+	}
+	kept := 0
+	for _, r := range after {
+		if slices.Contains(before, r) {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Errorf("unchanged links were rewritten, kept %d of 2", kept)
+	}
+
+	// filter
+	found, _, err := goodDef.SelectEntities([]*Filter{AddFilterHasAny(tags, tag3.RefString())}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("SelectEntities() error = %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("SelectEntities() with HasAny tag3 = %d entities, want 2", len(found))
+	}
+	found, _, _ = goodDef.SelectEntities([]*Filter{AddFilterHasAny(tags, tag1, tag2)}, nil, 0, 0)
+	if len(found) != 1 || found[0].RefString() != good1.RefString() {
+		t.Errorf("SelectEntities() with HasAny tag1, tag2 = %v, want good1", found)
+	}
+
+	// links are deleted with target and owner
+	if err = factory.DeleteEntity(ctx, tag3.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	if err = factory.DeleteEntity(ctx, good1.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	if left := linkRefs(); len(left) != 0 {
+		t.Errorf("links left after delete: %v", left)
+	}
+}
@@ -0,0 +1,100 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ApplyMergePatch changes entity by JSON merge patch (RFC 7386): only fields present in patch are changed, null
+// resets field to empty value. Table parts are replaced as a whole, rows are matched to existing ones by Ref.
+// Ref, DataVersion and fields which aren't writable (see FieldDef.Access) are ignored like in PUT requests, unknown
+// names cause error. Entity isn't saved.
+func (T *Entity) ApplyMergePatch(patch []byte) error {
+	return T.ApplyMergePatchContext(context.Background(), patch)
+}
+
+// ApplyMergePatchContext changes entity by JSON merge patch like ApplyMergePatch, with access to fields decided for ctx
+// (see Factory.FieldAccessPolicy).
+func (T *Entity) ApplyMergePatchContext(ctx context.Context, patch []byte) error {
+	var vm map[string]any
+	if err := json.Unmarshal(patch, &vm); err != nil {
+		return fmt.Errorf("Entity.ApplyMergePatch: invalid patch: %w", err)
+	}
+	if vm == nil {
+		return fmt.Errorf("Entity.ApplyMergePatch: patch should be JSON object")
+	}
+
+	// check names and values before changing anything
+	tpValues := make(map[string]any)
+	for name, val := range vm {
+		if _, ok := T.Values[name]; ok {
+			continue
+		}
+		if slices.ContainsFunc(T.entityDef.TablePartDefs, func(tpd *TablePartDef) bool { return tpd.Name == name }) {
+			tpValues[name] = val
+			continue
+		}
+		return fmt.Errorf("Entity.ApplyMergePatch: unknown field %s", name)
+	}
+	scratch, err := T.Factory.createEntityImpl(T.entityDef, false) // fill new handlers shouldn't run for validation
+	if err != nil {
+		return fmt.Errorf("Entity.ApplyMergePatch: %w", err)
+	}
+	for name, val := range vm {
+		if v, ok := scratch.Values[name]; ok && val != nil && T.Factory.canWriteField(ctx, v.Def()) {
+			if err = setFieldValueFromJSON(v, val); err != nil {
+				return fmt.Errorf("Entity.ApplyMergePatch: %w: %w", ErrValidation, err)
+			}
+		}
+	}
+
+	for name, val := range vm {
+		v, ok := T.Values[name]
+		if !ok || name == RefFieldName || name == DataVersionFieldName || !T.Factory.canWriteField(ctx, v.Def()) {
+			continue
+		}
+		if val == nil {
+			if err = resetFieldValue(v); err != nil {
+				return fmt.Errorf("Entity.ApplyMergePatch: %w", err)
+			}
+			continue
+		}
+		if err = setFieldValueFromJSON(v, val); err != nil {
+			return fmt.Errorf("Entity.ApplyMergePatch: %w", err)
+		}
+	}
+
+	if len(tpValues) > 0 {
+		for name := range tpValues {
+			if _, err := T.tableParts[name].Rows(); err != nil { // existing rows are matched by Ref
+				return fmt.Errorf("Entity.ApplyMergePatch: %w", err)
+			}
+		}
+		if err = T.tablePartsFromMap(ctx, tpValues); err != nil {
+			return fmt.Errorf("Entity.ApplyMergePatch: %w: %w", ErrValidation, err)
+		}
+	}
+	return nil
+}
+
+// resetFieldValue sets empty value of field type
+func resetFieldValue(v IFieldValue) error {
+	switch vt := v.(type) {
+	case *FieldValueString:
+		vt.Set("")
+	case *FieldValueInt:
+		vt.Set(0)
+	case *FieldValueBool:
+		vt.Set(false)
+	case *FieldValueNumeric:
+		vt.Set(0)
+	case *FieldValueDateTime:
+		vt.Set(time.Time{})
+	case *FieldValueRef:
+		return vt.Set("")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEntity_ApplyMergePatch(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "mergepatch.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	goodDef, _ := factory.CreateEntityDef("MpGood", "MpGoods")
+	_, _ = goodDef.AddStringFieldDef("Caption", 50)
+	_, _ = goodDef.AddNumericFieldDef("Price", 10, 2)
+	_, _ = goodDef.AddIntFieldDef("Qty")
+	lines, _ := goodDef.AddTablePartDef("Lines", "MpGoodLines")
+	_, _ = lines.RowDef.AddIntFieldDef("Qty")
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	goodDef.Wrap = func(source *Entity) any { return source } // handlers get entity instead of definition
+	saves := 0
+	_ = factory.AddBeforeSaveHandler(goodDef, func(ctx context.Context, entity any) error {
+		saves++
+		if entity.(*Entity).Values["Price"].(*FieldValueNumeric).Get() < 0 {
+			return errors.New("negative price")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	good, _ := factory.CreateEntity(goodDef)
+	good.Values["Caption"].(*FieldValueString).Set("apple")
+	good.Values["Price"].(*FieldValueNumeric).Set(10)
+	good.Values["Qty"].(*FieldValueInt).Set(5)
+	row, _ := good.TablePart("Lines").AddRow()
+	row.Values["Qty"].(*FieldValueInt).Set(1)
+	if err = good.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	saves = 0
+	fills := 0
+	_ = factory.AddFillNewHandler(goodDef, func(entity any) error {
+		fills++
+		return nil
+	})
+
+	config := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	config.EnablePatch = true
+	handler := HandleRestApi(config)
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPatch, "/goods?ref="+good.RefString(), strings.NewReader(body)))
+		return w
+	}
+	stored := func() (caption string, price float64, qty int64) {
+		table, _ := goodDef.SqlTableName()
+		err := factory.db.QueryRow("select caption, price, qty from "+table+" where ref=$1", good.RefString()).Scan(&caption, &price, &qty)
+		if err != nil {
+			t.Fatalf("QueryRow() error = %v", err)
+		}
+		return
+	}
+
+	w := patch(`{"Price": 12}`)
+	if w.Code != http.StatusOK || saves != 1 {
+		t.Fatalf("PATCH = %d %s, saves = %d", w.Code, w.Body.String(), saves)
+	}
+	var resp map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["Price"] != float64(12) || resp["Caption"] != "apple" {
+		t.Errorf("PATCH response = %v", resp)
+	}
+	if fills != 0 {
+		t.Errorf("PATCH called fill new handlers %d times", fills)
+	}
+	if c, p, q := stored(); c != "apple" || p != 12 || q != 5 {
+		t.Errorf("stored after PATCH = %s, %v, %d", c, p, q)
+	}
+
+	if w = patch(`{"Qty": null}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH with null = %d %s", w.Code, w.Body.String())
+	}
+	if _, _, q := stored(); q != 0 {
+		t.Errorf("PATCH with null: Qty = %d, want 0", q)
+	}
+
+	for _, body := range []string{`{"Caption": "x", "Unknown": 1}`, `[1]`, `{`} {
+		if w = patch(body); w.Code != http.StatusBadRequest {
+			t.Errorf("PATCH %s = %d, want 400", body, w.Code)
+		}
+	}
+	for _, body := range []string{`{"Caption": "x", "Price": "abc"}`, `{"Caption": 1}`} {
+		if w = patch(body); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("PATCH %s = %d, want 422", body, w.Code)
+		}
+	}
+	if good.Values["Caption"].AsString() != "apple" {
+		t.Errorf("rejected PATCH changed entity: Caption = %s", good.Values["Caption"].AsString())
+	}
+
+	if w = patch(`{"Price": -1}`); w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "negative price") {
+		t.Errorf("PATCH rejected by before save handler = %d %s", w.Code, w.Body.String())
+	}
+	good, err = factory.LoadEntity(good.RefString())
+	if err != nil || good.Values["Price"].(*FieldValueNumeric).Get() != 12 {
+		t.Fatalf("rejected PATCH left changed entity in cache")
+	}
+
+	if w = patch(`{"Lines": [{"Ref": "` + row.RefString() + `", "Qty": 7}, {"Qty": 2}]}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH with table part = %d %s", w.Code, w.Body.String())
+	}
+	rows, _, err := lines.RowDef.SelectEntities([]*Filter{AddFilterEQ(lines.OwnerField, good.RefString())}, []*SortItem{{Field: lines.LineNoField, Asc: true}}, 0, 0)
+	if err != nil || len(rows) != 2 || rows[0].RefString() != row.RefString() || rows[0].Values["Qty"].(*FieldValueInt).Get() != 7 {
+		t.Errorf("table part after PATCH: rows = %d, err = %v", len(rows), err)
+	}
+	if p := good.Values["Price"].(*FieldValueNumeric).Get(); p != 12 {
+		t.Errorf("PATCH with table part changed Price = %v", p)
+	}
+
+	config = CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	w = httptest.NewRecorder()
+	HandleRestApi(config)(w, httptest.NewRequest(http.MethodPatch, "/goods?ref="+good.RefString(), strings.NewReader(`{}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("PATCH disabled by default = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package elorm
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const schemaHistoryTable = "elorm_schema_history" // applied custom migration steps (version > 0) and renames (version 0)
+
+// MigrationFunc is a function type for custom migration steps. ctx carries the migration transaction.
+type MigrationFunc func(ctx context.Context) error
+
+type migration struct {
+	version int64
+	name    string
+	fn      MigrationFunc
+}
+
+// AddMigration registers custom migration step, e.g. data backfill. Steps which are not applied yet run in version order
+// by EnsureDBStructure/ApplyDBStructure after database structure changes, inside the migration transaction.
+// Applied steps are recorded to elorm_schema_history table and never run again, like table and column renames.
+func (T *Factory) AddMigration(version int64, name string, fn MigrationFunc) error {
+	if fn == nil {
+		return fmt.Errorf("Factory.AddMigration: fn is nil")
+	}
+	if version <= 0 {
+		return fmt.Errorf("Factory.AddMigration: version should be positive, got %d", version)
+	}
+	for _, m := range T.migrations {
+		if m.version == version {
+			return fmt.Errorf("Factory.AddMigration: migration with version %d already registered (%s)", version, m.name)
+		}
+	}
+	T.migrations = append(T.migrations, &migration{version: version, name: name, fn: fn})
+	slices.SortFunc(T.migrations, func(a, b *migration) int { return cmp.Compare(a.version, b.version) })
+	return nil
+}
+
+// AppliedMigrations returns versions of custom migration steps recorded to schema history table.
+func (T *Factory) AppliedMigrations() ([]int64, error) {
+	cols, err := T.dialect.TableColumns(T, schemaHistoryTable)
+	if err != nil {
+		return nil, fmt.Errorf("Factory.AppliedMigrations: failed to check schema history table: %w", err)
+	}
+	res := make([]int64, 0)
+	if len(cols) == 0 {
+		return res, nil
+	}
+	rows, err := T.Query(fmt.Sprintf("select version from %s where version > 0 order by version", schemaHistoryTable))
+	if err != nil {
+		return nil, fmt.Errorf("Factory.AppliedMigrations: failed to query schema history: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("Factory.AppliedMigrations: failed to scan schema history: %w", err)
+		}
+		res = append(res, v)
+	}
+	return res, rows.Err()
+}
+
+// planSchemaHistoryTable plans creation of schema history table when database has no one yet
+func (T *Factory) planSchemaHistoryTable(plan *SchemaPlan) error {
+	cols, err := T.dialect.TableColumns(T, schemaHistoryTable)
+	if err != nil {
+		return fmt.Errorf("Factory.planSchemaHistoryTable: failed to check schema history table: %w", err)
+	}
+	if len(cols) > 0 {
+		return nil
+	}
+	sql, err := T.schemaHistoryTableSql()
+	if err != nil {
+		return fmt.Errorf("Factory.planSchemaHistoryTable: %w", err)
+	}
+	plan.add(SchemaChangeCreateTable, schemaHistoryTable, schemaHistoryTable, sql)
+	return nil
+}
+
+// planMigrations records planned renames to schema history and plans custom migration steps which are not applied yet.
+// History is recorded by plain SQL statements, so script exported by SchemaPlan.Sql records it too.
+func (T *Factory) planMigrations(plan *SchemaPlan) error {
+	for _, c := range slices.Clone(plan.Changes) {
+		if c.Kind == SchemaChangeRenameTable || c.Kind == SchemaChangeRenameColumn {
+			plan.Changes = append(plan.Changes, schemaHistoryRecord(0, c.String()))
+		}
+	}
+	applied, err := T.AppliedMigrations()
+	if err != nil {
+		return fmt.Errorf("Factory.planMigrations: %w", err)
+	}
+	for _, m := range T.migrations {
+		if slices.Contains(applied, m.version) {
+			continue
+		}
+		plan.Changes = append(plan.Changes, &SchemaChange{Kind: SchemaChangeMigration, Name: m.name, Version: m.version,
+			Sql: fmt.Sprintf("-- migration %d %s runs by application", m.version, m.name)})
+		plan.Changes = append(plan.Changes, schemaHistoryRecord(m.version, m.name))
+	}
+	return nil
+}
+
+// schemaHistoryRecord returns change which records rename (version 0) or migration step to schema history table
+func schemaHistoryRecord(version int64, name string) *SchemaChange {
+	return &SchemaChange{Kind: SchemaChangeRecordHistory, Table: schemaHistoryTable, Name: name, Version: version,
+		Sql: fmt.Sprintf("insert into %s (version, name, appliedat) values (%d, '%s', CURRENT_TIMESTAMP)",
+			schemaHistoryTable, version, strings.ReplaceAll(name, "'", "''"))}
+}
+
+func (T *Factory) schemaHistoryTableSql() (string, error) {
+	versionType, err := T.dialect.ColumnType(&FieldDef{Type: FieldDefTypeInt})
+	if err != nil {
+		return "", fmt.Errorf("Factory.schemaHistoryTableSql: %w", err)
+	}
+	nameType, err := T.dialect.ColumnType(&FieldDef{Type: FieldDefTypeString, Len: 255})
+	if err != nil {
+		return "", fmt.Errorf("Factory.schemaHistoryTableSql: %w", err)
+	}
+	appliedType, err := T.dialect.ColumnType(&FieldDef{Type: FieldDefTypeDateTime})
+	if err != nil {
+		return "", fmt.Errorf("Factory.schemaHistoryTableSql: %w", err)
+	}
+	return fmt.Sprintf("create table %s (version %s, name %s, appliedat %s)",
+		schemaHistoryTable, versionType, nameType, appliedType), nil
+}
+
+// runMigration runs migration step, it is recorded to schema history table by the next change of plan
+func (T *Factory) runMigration(ctx context.Context, version int64) error {
+	idx := slices.IndexFunc(T.migrations, func(m *migration) bool { return m.version == version })
+	if idx < 0 {
+		return fmt.Errorf("Factory.runMigration: migration %d is not registered", version)
+	}
+	m := T.migrations[idx]
+	if err := m.fn(ctx); err != nil {
+		return fmt.Errorf("Factory.runMigration: migration %d %s failed: %w", m.version, m.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package elorm
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFactory_Migrations(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "migrations.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	def, err := factory.CreateEntityDef("Task", "Tasks")
+	if err != nil {
+		t.Fatalf("CreateEntityDef() error = %v", err)
+	}
+	title, _ := def.AddStringFieldDef("Title", 50)
+	if err = def.AddIndex(false, title); err != nil {
+		t.Fatalf("AddIndex() error = %v", err)
+	}
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ent, _ := factory.CreateEntity(def)
+	ent.Values["Title"].(*FieldValueString).Set("first")
+	if err = ent.Save(context.Background()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// rename table and field, add new field with backfill
+	def.TableName = "Jobs"
+	def.RenamedFrom = []string{"Tasks"}
+	title.Name = "Caption"
+	title.RenamedFrom = []string{"Title"}
+	_, _ = def.AddStringFieldDef("Status", 10)
+
+	runs := 0
+	err = factory.AddMigration(1, "backfill status", func(ctx context.Context) error {
+		runs++
+		_, err := factory.ExecContext(ctx, "update jobs set status=$1", "open")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("AddMigration() error = %v", err)
+	}
+	if err = factory.AddMigration(1, "duplicate", func(ctx context.Context) error { return nil }); err == nil {
+		t.Errorf("AddMigration() with duplicate version should fail")
+	}
+
+	plan, err := factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	kinds := make([]int, 0, len(plan.Changes))
+	for _, c := range plan.Changes {
+		kinds = append(kinds, c.Kind)
+	}
+	for _, k := range []int{SchemaChangeRenameTable, SchemaChangeRenameColumn, SchemaChangeAddColumn, SchemaChangeMigration} {
+		if !slices.Contains(kinds, k) {
+			t.Errorf("PlanDBStructure() has no change of kind %d:\n%s", k, plan.Sql())
+		}
+	}
+	if slices.Contains(kinds, SchemaChangeCreateTable) && plan.Changes[slices.Index(kinds, SchemaChangeCreateTable)].Table == "jobs" {
+		t.Errorf("renamed table should not be created:\n%s", plan.Sql())
+	}
+	sql := plan.Sql()
+	for _, s := range []string{
+		"insert into elorm_schema_history (version, name, appliedat) values (0, 'rename table tasks to jobs', CURRENT_TIMESTAMP)",
+		"insert into elorm_schema_history (version, name, appliedat) values (0, 'rename column jobs.title to caption', CURRENT_TIMESTAMP)",
+		"insert into elorm_schema_history (version, name, appliedat) values (1, 'backfill status', CURRENT_TIMESTAMP)",
+	} {
+		if !strings.Contains(sql, s) {
+			t.Errorf("plan SQL has no %s:\n%s", s, sql)
+		}
+	}
+
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("second EnsureDBStructure() error = %v", err)
+	}
+	if runs != 1 {
+		t.Errorf("migration runs = %d, want 1", runs)
+	}
+	applied, err := factory.AppliedMigrations()
+	if err != nil || !slices.Equal(applied, []int64{1}) {
+		t.Errorf("AppliedMigrations() = %v, %v", applied, err)
+	}
+	var renames int
+	if err = factory.db.QueryRow("select count(*) from elorm_schema_history where version = 0").Scan(&renames); err != nil || renames != 2 {
+		t.Errorf("recorded renames = %d, %v, want 2", renames, err)
+	}
+
+	factory.loadedEntities.Purge()
+	loaded, err := factory.LoadEntity(ent.RefString())
+	if err != nil {
+		t.Fatalf("LoadEntity() error = %v", err)
+	}
+	if got := loaded.Values["Caption"].(*FieldValueString).Get(); got != "first" {
+		t.Errorf("renamed field value = %s, want first", got)
+	}
+	if got := loaded.Values["Status"].(*FieldValueString).Get(); got != "open" {
+		t.Errorf("backfilled field value = %s, want open", got)
+	}
+
+	// failed migration rolls back structure changes
+	_, _ = def.AddStringFieldDef("Owner", 10)
+	err = factory.AddMigration(2, "failing", func(ctx context.Context) error { return fmt.Errorf("fail") })
+	if err != nil {
+		t.Fatalf("AddMigration() error = %v", err)
+	}
+	if err = factory.EnsureDBStructure(); err == nil {
+		t.Fatalf("EnsureDBStructure() with failing migration should fail")
+	}
+	cols, err := factory.dialect.TableColumns(factory, "jobs")
+	if err != nil {
+		t.Fatalf("TableColumns() error = %v", err)
+	}
+	if slices.ContainsFunc(cols, func(c *DbColumn) bool { return c.Name == "owner" }) {
+		t.Errorf("structure change was not rolled back after failed migration")
+	}
+}
@@ -0,0 +1,423 @@
+package elorm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RestApiEndpoint describes REST API endpoint for OpenAPI document: mount path and settings of RestApiConfig.
+type RestApiEndpoint struct {
+	Path string
+	Def  *EntityDef
+
+	AutoFilters   bool
+	CursorPaging  bool
+	EnableGetOne  bool
+	EnableGetList bool
+	EnablePost    bool
+	EnablePut     bool
+	EnableDelete  bool
+	EnablePatch   bool
+
+	RequireIfMatch bool
+	EnableBatch    bool
+
+	ParamRef      string
+	ParamPageNo   string
+	ParamPageSize string
+	ParamSortBy   string
+	ParamCursor   string
+	ParamCount    string
+	ParamFilter   string
+	ParamBatch    string
+	ParamFields   string
+	ParamExpand   string
+
+	ExpandFields []string
+}
+
+// CreateRestApiEndpoint creates endpoint description from config mounted to path (e.g. "/api/goods").
+func CreateRestApiEndpoint[T IEntity](path string, config RestApiConfig[T]) *RestApiEndpoint {
+	res := &RestApiEndpoint{
+		Path:           path,
+		Def:            config.Def,
+		AutoFilters:    config.AutoFilters,
+		CursorPaging:   config.CursorPaging,
+		EnableGetOne:   config.EnableGetOne,
+		EnableGetList:  config.EnableGetList,
+		EnablePost:     config.EnablePost,
+		EnablePut:      config.EnablePut,
+		EnableDelete:   config.EnableDelete,
+		EnablePatch:    config.EnablePatch,
+		RequireIfMatch: config.RequireIfMatch,
+		EnableBatch:    config.EnableBatch,
+		ParamRef:       config.ParamRef,
+		ParamPageNo:    config.ParamPageNo,
+		ParamPageSize:  config.ParamPageSize,
+		ParamSortBy:    config.ParamSortBy,
+		ParamCursor:    config.ParamCursor,
+		ParamCount:     config.ParamCount,
+		ParamFilter:    config.ParamFilter,
+		ParamBatch:     config.ParamBatch,
+		ParamFields:    config.ParamFields,
+		ParamExpand:    config.ParamExpand,
+		ExpandFields:   config.ExpandFields,
+	}
+	if config.SelectEntitiesPageFunc == nil { // cursor pagination isn't enabled
+		res.CursorPaging = false
+		res.ParamCursor = ""
+		res.ParamCount = ""
+	}
+	return res
+}
+
+// OpenApiDocument builds OpenAPI 3 document for endpoints. Entity schemas are placed into components/schemas by
+// ObjectName, fields are described by FieldDef.Access (Factory.FieldAccessPolicy depends on request, it isn't used).
+// Use OpenApiJSON or OpenApiYAML to serialize it.
+func OpenApiDocument(title string, version string, endpoints ...*RestApiEndpoint) (map[string]any, error) {
+	schemas := make(map[string]any)
+	paths := make(map[string]any)
+	for _, ep := range endpoints {
+		if ep == nil || ep.Def == nil {
+			return nil, fmt.Errorf("OpenApiDocument: endpoint without entity definition")
+		}
+		if _, ok := paths[ep.Path]; ok {
+			return nil, fmt.Errorf("OpenApiDocument: duplicate path %s", ep.Path)
+		}
+		openApiEntitySchemas(ep.Def, schemas)
+		paths[ep.Path] = ep.pathItem()
+	}
+	return map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]any{"title": title, "version": version},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}, nil
+}
+
+// OpenApiJSON returns OpenAPI document for endpoints as indented JSON.
+func OpenApiJSON(title string, version string, endpoints ...*RestApiEndpoint) ([]byte, error) {
+	doc, err := OpenApiDocument(title, version, endpoints...)
+	if err != nil {
+		return nil, fmt.Errorf("OpenApiJSON: %w", err)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// OpenApiYAML returns OpenAPI document for endpoints as YAML.
+func OpenApiYAML(title string, version string, endpoints ...*RestApiEndpoint) ([]byte, error) {
+	doc, err := OpenApiDocument(title, version, endpoints...)
+	if err != nil {
+		return nil, fmt.Errorf("OpenApiYAML: %w", err)
+	}
+	var sb strings.Builder
+	writeYAML(&sb, doc, 0)
+	return []byte(sb.String()), nil
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// openApiFieldSchema returns schema of field value in JSON
+func openApiFieldSchema(fd *FieldDef) map[string]any {
+	switch fd.Type {
+	case FieldDefTypeString:
+		res := map[string]any{"type": "string"}
+		if fd.Len > 0 {
+			res["maxLength"] = fd.Len
+		}
+		return res
+	case FieldDefTypeInt:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case FieldDefTypeBool:
+		return map[string]any{"type": "boolean"}
+	case FieldDefTypeNumeric:
+		res := map[string]any{"type": "number", "description": fmt.Sprintf("numeric(%d,%d)", fd.Precision, fd.Scale)}
+		if fd.Precision > fd.Scale {
+			limit := math.Pow10(fd.Precision-fd.Scale) - math.Pow10(-fd.Scale)
+			res["maximum"] = limit
+			res["minimum"] = -limit
+		}
+		return res
+	case FieldDefTypeDateTime:
+		example := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+		res := map[string]any{"type": "string", "example": example.Format(fd.DateTimeJSONFormat)}
+		if fd.DateTimeJSONFormat == time.RFC3339 || fd.DateTimeJSONFormat == time.RFC3339Nano {
+			res["format"] = "date-time"
+		}
+		return res
+	case FieldDefTypeRef:
+		res := map[string]any{"type": "string", "description": "Ref of " + fd.EntityDef.ObjectName}
+		if len(fd.EntityDef.AutoExpandFieldsForJSON) > 0 && fd.Name != RefFieldName {
+			// expanded entity, refs inside it are described as plain strings to avoid endless recursion
+			props := make(map[string]any, len(fd.EntityDef.AutoExpandFieldsForJSON))
+			for efd := range fd.EntityDef.AutoExpandFieldsForJSON {
+				if efd.Type == FieldDefTypeRef {
+					props[efd.Name] = map[string]any{"type": "string", "description": "Ref of " + efd.EntityDef.ObjectName}
+				} else {
+					props[efd.Name] = openApiFieldSchema(efd)
+				}
+			}
+			expanded := map[string]any{"type": "object", "description": "Expanded " + fd.EntityDef.ObjectName, "properties": props}
+			return map[string]any{"oneOf": []any{res, expanded}} // empty ref isn't expanded
+		}
+		return res
+	}
+	return map[string]any{}
+}
+
+// openApiEntitySchemas adds schema of entity definition and schemas of its table part rows and collection items
+func openApiEntitySchemas(def *EntityDef, schemas map[string]any) {
+	if _, ok := schemas[def.ObjectName]; ok {
+		return
+	}
+	props := make(map[string]any, len(def.FieldDefs))
+	schema := map[string]any{"type": "object", "properties": props}
+	schemas[def.ObjectName] = schema
+	for _, fd := range def.FieldDefs {
+		if def.TablePartOf != nil && fd.Name == TablePartOwnerFieldName {
+			continue // owner isn't marshaled in table part rows
+		}
+		schema := openApiFieldSchema(fd)
+		switch fd.Access {
+		case FieldAccessHidden:
+			continue
+		case FieldAccessReadOnly:
+			schema["readOnly"] = true
+		case FieldAccessWriteOnly:
+			schema["writeOnly"] = true
+		}
+		props[fd.Name] = schema
+	}
+	for _, tpd := range def.TablePartDefs {
+		openApiEntitySchemas(tpd.RowDef, schemas)
+		props[tpd.Name] = map[string]any{"type": "array", "items": schemaRef(tpd.RowDef.ObjectName)}
+	}
+	for cd := range def.AutoExpandCollectionsForJSON {
+		openApiEntitySchemas(cd.ItemDef, schemas)
+		props[cd.Name] = map[string]any{"type": "array", "readOnly": true, "items": schemaRef(cd.ItemDef.ObjectName)}
+	}
+}
+
+func queryParam(name string, required bool, description string, schema map[string]any) map[string]any {
+	return map[string]any{"name": name, "in": "query", "required": required, "description": description, "schema": schema}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// errorResponses adds problem details (RFC 7807) responses for codes
+func errorResponses(responses map[string]any, codes ...string) map[string]any {
+	problem := map[string]any{"type": "object", "properties": map[string]any{
+		"type":   map[string]any{"type": "string"},
+		"title":  map[string]any{"type": "string"},
+		"status": map[string]any{"type": "integer"},
+		"detail": map[string]any{"type": "string"},
+	}}
+	for _, c := range codes {
+		responses[c] = map[string]any{"description": "Error", "content": map[string]any{"application/problem+json": map[string]any{"schema": problem}}}
+	}
+	return responses
+}
+
+// pathItem describes enabled operations of endpoint
+func (T *RestApiEndpoint) pathItem() map[string]any {
+	name := T.Def.ObjectName
+	entity := schemaRef(name)
+	refParam := func(required bool) map[string]any {
+		return queryParam(T.ParamRef, required, "Ref of "+name, map[string]any{"type": "string"})
+	}
+	ifMatch := map[string]any{"name": "If-Match", "in": "header", "required": T.RequireIfMatch,
+		"description": "ETag of entity from previous response", "schema": map[string]any{"type": "string"}}
+	res := make(map[string]any)
+
+	if T.EnableGetOne || T.EnableGetList {
+		params := make([]any, 0)
+		responses := make([]any, 0)
+		summary := make([]string, 0, 2)
+		if T.EnableGetOne {
+			params = append(params, refParam(!T.EnableGetList))
+			responses = append(responses, entity)
+			summary = append(summary, fmt.Sprintf("%s by %s", name, T.ParamRef))
+		}
+		if T.EnableGetList {
+			params = append(params, T.listParams()...)
+			responses = append(responses, T.listEnvelopes()...)
+			summary = append(summary, "list of "+name)
+		}
+		params = append(params, T.shapeParams()...)
+		var schema map[string]any
+		if len(responses) == 1 {
+			schema = responses[0].(map[string]any)
+		} else {
+			schema = map[string]any{"oneOf": responses}
+		}
+		getResponses := map[string]any{"200": map[string]any{"description": "OK", "content": jsonContent(schema)}}
+		if T.EnableGetOne {
+			getResponses["304"] = map[string]any{"description": "Not modified since ETag from If-None-Match header"}
+		}
+		res["get"] = map[string]any{
+			"summary":    strings.Join(summary, " or "),
+			"parameters": params,
+			"responses":  errorResponses(getResponses, "400", "404", "500"),
+		}
+	}
+	if T.EnablePost {
+		res["post"] = map[string]any{
+			"summary":     "Create " + name,
+			"requestBody": map[string]any{"required": true, "content": jsonContent(entity)},
+			"responses":   errorResponses(map[string]any{"201": map[string]any{"description": "Created", "content": jsonContent(entity)}}, "400", "409", "422", "500"),
+		}
+	}
+	if T.EnableBatch {
+		// OpenAPI doesn't allow two POST operations on one path, so batch mode is only described
+		post, ok := res["post"].(map[string]any)
+		if !ok {
+			post = map[string]any{"summary": "Batch of operations on " + name, "responses": map[string]any{"200": map[string]any{"description": "Results of operations"}}}
+			res["post"] = post
+		}
+		post["description"] = fmt.Sprintf("With '%s' query parameter body is array of operations {Op, Ref, IfMatch, Data} "+
+			"(Op is create, update, patch or delete) and response is array of results {Status, Ref, ETag, Data, Error}", T.ParamBatch)
+	}
+	if T.EnablePut {
+		res["put"] = map[string]any{
+			"summary":     "Update " + name,
+			"parameters":  []any{refParam(true), ifMatch},
+			"requestBody": map[string]any{"required": true, "content": jsonContent(entity)},
+			"responses":   errorResponses(map[string]any{"200": map[string]any{"description": "OK"}}, "400", "404", "409", "412", "422", "428", "500"),
+		}
+	}
+	if T.EnablePatch {
+		res["patch"] = map[string]any{
+			"summary":     "Change fields of " + name + " (JSON merge patch)",
+			"parameters":  []any{refParam(true), ifMatch},
+			"requestBody": map[string]any{"required": true, "content": map[string]any{"application/merge-patch+json": map[string]any{"schema": entity}}},
+			"responses":   errorResponses(map[string]any{"200": map[string]any{"description": "OK", "content": jsonContent(entity)}}, "400", "404", "409", "412", "422", "428", "500"),
+		}
+	}
+	if T.EnableDelete {
+		res["delete"] = map[string]any{
+			"summary":    "Delete " + name,
+			"parameters": []any{refParam(true), ifMatch},
+			"responses":  errorResponses(map[string]any{"200": map[string]any{"description": "OK"}}, "404", "409", "412", "428", "500"),
+		}
+	}
+	return res
+}
+
+// shapeParams returns query parameters limiting fields and expanding refs in GET responses
+func (T *RestApiEndpoint) shapeParams() []any {
+	res := make([]any, 0, 2)
+	if T.ParamFields != "" {
+		res = append(res, queryParam(T.ParamFields, false, "Comma separated fields of response, e.g. \"Caption,Price\"", map[string]any{"type": "string"}))
+	}
+	if T.ParamExpand != "" && T.ExpandFields != nil {
+		res = append(res, queryParam(T.ParamExpand, false, "Comma separated refs expanded into objects with optional fields, e.g. \"OwnerShop(Caption)\". Allowed fields: "+
+			strings.Join(T.ExpandFields, ", "), map[string]any{"type": "string"}))
+	}
+	return res
+}
+
+// listParams returns query parameters of list request
+func (T *RestApiEndpoint) listParams() []any {
+	res := []any{
+		queryParam(T.ParamPageSize, false, "Page size", map[string]any{"type": "integer"}),
+		queryParam(T.ParamSortBy, false, "Comma separated fields with optional \"desc\", e.g. \"Caption desc,Ref\"", map[string]any{"type": "string"}),
+	}
+	if T.ParamPageNo != "" && !T.CursorPaging {
+		res = append(res, queryParam(T.ParamPageNo, false, "Page number starting from 1", map[string]any{"type": "integer"}))
+	}
+	if T.ParamCursor != "" {
+		res = append(res, queryParam(T.ParamCursor, false, "Cursor of page (NextCursor or PrevCursor of previous response)", map[string]any{"type": "string"}))
+	}
+	if T.ParamCount != "" {
+		res = append(res, queryParam(T.ParamCount, false, "Count total for cursor pagination", map[string]any{"type": "boolean"}))
+	}
+	if T.ParamFilter != "" {
+		res = append(res, queryParam(T.ParamFilter, false, "Filter expression, e.g. \"Price gt 10 and Caption like 'a%'\"", map[string]any{"type": "string"}))
+	}
+	if T.AutoFilters {
+		reserved := []string{T.ParamRef, T.ParamPageNo, T.ParamPageSize, T.ParamSortBy, T.ParamCursor, T.ParamCount, T.ParamFilter, T.ParamFields, T.ParamExpand}
+		for _, fd := range T.Def.FieldDefs {
+			if fd.Access == FieldAccessHidden || fd.Access == FieldAccessWriteOnly ||
+				slices.ContainsFunc(reserved, func(s string) bool { return strings.EqualFold(s, fd.Name) }) {
+				continue
+			}
+			res = append(res, queryParam(fd.Name, false, "Equals filter", openApiFieldSchema(fd)))
+		}
+	}
+	return res
+}
+
+// listEnvelopes returns schemas of list responses
+func (T *RestApiEndpoint) listEnvelopes() []any {
+	data := map[string]any{"type": "array", "items": schemaRef(T.Def.ObjectName)}
+	cursor := map[string]any{"type": "object", "properties": map[string]any{
+		"Data":       data,
+		"NextCursor": map[string]any{"type": "string"},
+		"PrevCursor": map[string]any{"type": "string"},
+		"Total":      map[string]any{"type": "integer"},
+	}}
+	if T.CursorPaging {
+		return []any{cursor}
+	}
+	pages := map[string]any{"type": "object", "properties": map[string]any{
+		"Data":       data,
+		"PagesCount": map[string]any{"type": "integer"},
+	}}
+	if T.ParamCursor == "" {
+		return []any{pages}
+	}
+	return []any{pages, cursor}
+}
+
+// writeYAML writes value of JSON-like document as YAML block, strings are double quoted
+func writeYAML(sb *strings.Builder, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch vt := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			sb.WriteString(pad + strconv.Quote(k) + ":")
+			writeYAMLValue(sb, vt[k], indent)
+		}
+	case []any:
+		for _, item := range vt {
+			sb.WriteString(pad + "-")
+			writeYAMLValue(sb, item, indent)
+		}
+	}
+}
+
+func writeYAMLValue(sb *strings.Builder, v any, indent int) {
+	switch vt := v.(type) {
+	case map[string]any:
+		if len(vt) == 0 {
+			sb.WriteString(" {}\n")
+			return
+		}
+		sb.WriteString("\n")
+		writeYAML(sb, vt, indent+1)
+	case []any:
+		if len(vt) == 0 {
+			sb.WriteString(" []\n")
+			return
+		}
+		sb.WriteString("\n")
+		writeYAML(sb, vt, indent+1)
+	default:
+		data, _ := json.Marshal(vt) // JSON scalars are valid YAML
+		sb.WriteString(" " + string(data) + "\n")
+	}
+}
@@ -0,0 +1,120 @@
+package elorm
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOpenApiDocument(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "openapi.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	shopDef, _ := factory.CreateEntityDef("OaShop", "OaShops")
+	_, _ = shopDef.AddStringFieldDef("Caption", 50)
+	goodDef, _ := factory.CreateEntityDef("OaGood", "OaGoods")
+	_, _ = goodDef.AddStringFieldDef("Caption", 100)
+	_, _ = goodDef.AddNumericFieldDef("Price", 10, 2)
+	_, _ = goodDef.AddRefFieldDef("OwnerShop", shopDef)
+	lines, _ := goodDef.AddTablePartDef("Lines", "OaGoodLines")
+	_, _ = lines.RowDef.AddIntFieldDef("Qty")
+
+	create := func() (*Entity, error) { return factory.CreateEntity(goodDef) }
+	goods := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities, create)
+	goods.EnableDelete = false
+	goods.EnablePatch = true
+	shops := CreateStdRestApiConfig(shopDef, factory.LoadEntity, shopDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(shopDef) })
+	shops.SelectEntitiesPageFunc = shopDef.SelectEntitiesPage
+	shops.CursorPaging = true
+	shops.AutoFilters = false
+
+	data, err := OpenApiJSON("Shop API", "1.0", CreateRestApiEndpoint("/api/goods", goods), CreateRestApiEndpoint("/api/shops", shops))
+	if err != nil {
+		t.Fatalf("OpenApiJSON() error = %v", err)
+	}
+	var doc struct {
+		OpenApi string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name     string
+				Required bool
+			}
+			Responses map[string]any
+		}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any
+			}
+		}
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenApi, "3.") || len(doc.Paths) != 2 {
+		t.Fatalf("OpenApiJSON() openapi = %q, paths = %d", doc.OpenApi, len(doc.Paths))
+	}
+
+	goodsPath := doc.Paths["/api/goods"]
+	for _, m := range []string{"get", "post", "put", "patch"} {
+		if _, ok := goodsPath[m]; !ok {
+			t.Errorf("OpenApiJSON() /api/goods has no %s operation", m)
+		}
+	}
+	if _, ok := goodsPath["delete"]; ok {
+		t.Errorf("OpenApiJSON() /api/goods has disabled delete operation")
+	}
+	params := make([]string, 0)
+	for _, p := range goodsPath["get"].Parameters {
+		params = append(params, p.Name)
+	}
+	for _, want := range []string{"ref", "pageno", "pagesize", "sortby", "filter", "Caption", "OwnerShop"} {
+		if !slices.Contains(params, want) {
+			t.Errorf("OpenApiJSON() GET /api/goods parameters %v miss %s", params, want)
+		}
+	}
+	if !goodsPath["put"].Parameters[0].Required {
+		t.Errorf("OpenApiJSON() PUT ref parameter isn't required")
+	}
+	if _, ok := goodsPath["post"].Responses["201"]; !ok {
+		t.Errorf("OpenApiJSON() POST has no 201 response")
+	}
+	for _, p := range doc.Paths["/api/shops"]["get"].Parameters {
+		if p.Name == "pageno" || p.Name == "Caption" {
+			t.Errorf("OpenApiJSON() GET /api/shops has unexpected parameter %s", p.Name)
+		}
+	}
+
+	good := doc.Components.Schemas["OaGood"].Properties
+	if good["Caption"]["type"] != "string" || good["Caption"]["maxLength"] != float64(100) {
+		t.Errorf("OpenApiJSON() Caption schema = %v", good["Caption"])
+	}
+	if good["Price"]["type"] != "number" || good["Price"]["maximum"] != 99999999.99 {
+		t.Errorf("OpenApiJSON() Price schema = %v", good["Price"])
+	}
+	if good["Lines"]["type"] != "array" {
+		t.Errorf("OpenApiJSON() Lines schema = %v", good["Lines"])
+	}
+	row, ok := doc.Components.Schemas[lines.RowDef.ObjectName]
+	if !ok || row.Properties["Qty"]["type"] != "integer" || row.Properties["Owner"] != nil {
+		t.Errorf("OpenApiJSON() table part row schema = %v", row.Properties)
+	}
+	if _, ok := doc.Components.Schemas["OaShop"]; !ok {
+		t.Errorf("OpenApiJSON() has no OaShop schema")
+	}
+
+	yaml, err := OpenApiYAML("Shop API", "1.0", CreateRestApiEndpoint("/api/goods", goods))
+	if err != nil {
+		t.Fatalf("OpenApiYAML() error = %v", err)
+	}
+	if !strings.Contains(string(yaml), "\"openapi\": \"3.0.3\"\n") || !strings.Contains(string(yaml), "\n  \"/api/goods\":\n") {
+		t.Errorf("OpenApiYAML() = %s", yaml)
+	}
+
+	if _, err = OpenApiJSON("x", "1", CreateRestApiEndpoint("/a", goods), CreateRestApiEndpoint("/a", shops)); err == nil {
+		t.Errorf("OpenApiJSON() with duplicate path should fail")
+	}
+}
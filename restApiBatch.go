@@ -0,0 +1,212 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Operations of batch request
+const (
+	BatchOpCreate = "create" // like POST
+	BatchOpUpdate = "update" // like PUT
+	BatchOpPatch  = "patch"  // like PATCH
+	BatchOpDelete = "delete" // like DELETE
+)
+
+// RestApiBatchItem is an operation of batch request (see RestApiConfig.EnableBatch).
+type RestApiBatchItem struct {
+	Op      string          // BatchOpCreate, BatchOpUpdate, BatchOpPatch or BatchOpDelete
+	Ref     string          // ref of entity for update, patch and delete
+	IfMatch string          // optional ETag of entity for update, patch and delete, see EntityETag
+	Data    json.RawMessage // entity for create and update, JSON merge patch for patch
+}
+
+// RestApiBatchResult is a result of batch operation. Results are returned in order of operations.
+type RestApiBatchResult struct {
+	Status int    // HTTP status code of operation
+	Ref    string `json:",omitempty"`
+	ETag   string `json:",omitempty"`
+	Data   any    `json:",omitempty"` // saved entity for create, update and patch
+	Error  string `json:",omitempty"`
+}
+
+// batchOperation executes one operation of batch inside transaction carried by ctx. It returns result or error with
+// default HTTP status for it.
+func batchOperation[T IEntity](config RestApiConfig[T], ctx context.Context, item RestApiBatchItem) (RestApiBatchResult, int, error) {
+	enabled := map[string]bool{
+		BatchOpCreate: config.EnablePost,
+		BatchOpUpdate: config.EnablePut,
+		BatchOpPatch:  config.EnablePatch,
+		BatchOpDelete: config.EnableDelete,
+	}
+	allowed, ok := enabled[item.Op]
+	if !ok {
+		return RestApiBatchResult{}, http.StatusBadRequest, fmt.Errorf("unknown operation '%s'", item.Op)
+	}
+	if !allowed {
+		return RestApiBatchResult{}, http.StatusMethodNotAllowed, fmt.Errorf("operation '%s' isn't enabled", item.Op)
+	}
+
+	var entity *Entity
+	if item.Op != BatchOpCreate {
+		if item.Ref == "" {
+			return RestApiBatchResult{}, http.StatusBadRequest, fmt.Errorf("missing Ref")
+		}
+		var err error
+		entity, err = config.Def.Factory.LoadEntityContext(ctx, item.Ref)
+		if err != nil {
+			return RestApiBatchResult{}, http.StatusNotFound, fmt.Errorf("failed to load entity: %w", err)
+		}
+		if entity.Def() != config.Def {
+			return RestApiBatchResult{}, http.StatusNotFound, fmt.Errorf("%w %s for %s", ErrInvalidRef, item.Ref, config.Def.ObjectName)
+		}
+		if item.IfMatch == "" && config.RequireIfMatch {
+			return RestApiBatchResult{}, http.StatusPreconditionRequired, fmt.Errorf("IfMatch is required")
+		}
+		if item.IfMatch != "" && !etagMatches(item.IfMatch, EntityETag(entity), false) {
+			return RestApiBatchResult{}, http.StatusPreconditionFailed, fmt.Errorf("entity %s was changed, actual ETag is %s", item.Ref, EntityETag(entity))
+		}
+	}
+
+	switch item.Op {
+	case BatchOpCreate:
+		newRecord, err := config.CreateEntityFunc()
+		if err != nil {
+			return RestApiBatchResult{}, http.StatusInternalServerError, fmt.Errorf("failed to create entity: %w", err)
+		}
+		refFld := newRecord.GetValues()[RefFieldName].(*FieldValueRef)
+		oldRef := refFld.v
+		if err = decodeEntity(ctx, item.Data, &newRecord); err != nil {
+			return RestApiBatchResult{}, http.StatusBadRequest, fmt.Errorf("invalid data: %w", err)
+		}
+		if refFld.v == "" {
+			refFld.v = oldRef
+		}
+		if err = newRecord.Save(ctx); err != nil {
+			return RestApiBatchResult{}, http.StatusInternalServerError, fmt.Errorf("failed to save entity: %w", err)
+		}
+		data, err := shapedEntity(ctx, nil, newRecord)
+		if err != nil {
+			return RestApiBatchResult{}, http.StatusInternalServerError, fmt.Errorf("failed to convert entity: %w", err)
+		}
+		return RestApiBatchResult{Status: http.StatusCreated, Ref: newRecord.RefString(), ETag: EntityETag(newRecord), Data: data}, 0, nil
+	case BatchOpUpdate:
+		reqRecord, err := config.CreateEntityFunc()
+		if err != nil {
+			return RestApiBatchResult{}, http.StatusInternalServerError, fmt.Errorf("failed to create entity: %w", err)
+		}
+		if err = decodeEntity(ctx, item.Data, &reqRecord); err != nil {
+			return RestApiBatchResult{}, http.StatusBadRequest, fmt.Errorf("invalid data: %w", err)
+		}
+		if err = entity.loadFrom(reqRecord, false, entity.Factory.writableFields(ctx)); err != nil {
+			return RestApiBatchResult{}, http.StatusInternalServerError, fmt.Errorf("failed to load data into entity: %w", err)
+		}
+	case BatchOpPatch:
+		if err := entity.ApplyMergePatchContext(ctx, item.Data); err != nil {
+			return RestApiBatchResult{}, http.StatusBadRequest, fmt.Errorf("invalid data: %w", err)
+		}
+	case BatchOpDelete:
+		// entities with UseSoftDelete are marked as deleted by DeleteEntity, after the same handlers and delete rules
+		if err := config.Def.Factory.DeleteEntity(ctx, item.Ref); err != nil {
+			return RestApiBatchResult{}, errorStatus(err, http.StatusInternalServerError), fmt.Errorf("failed to delete entity: %w", err)
+		}
+		return RestApiBatchResult{Status: http.StatusOK, Ref: item.Ref}, 0, nil
+	}
+
+	if err := entity.Save(ctx); err != nil {
+		return RestApiBatchResult{}, http.StatusInternalServerError, fmt.Errorf("failed to save entity: %w", err)
+	}
+	data, err := shapedEntity(ctx, nil, entity)
+	if err != nil {
+		return RestApiBatchResult{}, http.StatusInternalServerError, fmt.Errorf("failed to convert entity: %w", err)
+	}
+	return RestApiBatchResult{Status: http.StatusOK, Ref: entity.RefString(), ETag: EntityETag(entity), Data: data}, 0, nil
+}
+
+// responseBatch executes operations of batch request in one transaction. With BatchAllOrNothing the first failed
+// operation rolls back the whole batch, response has its status and other operations get 424 Failed Dependency.
+// Otherwise each operation is nested transaction, failed ones are rolled back alone and response status is 200.
+func responseBatch[T IEntity](config RestApiConfig[T], r *http.Request, w http.ResponseWriter) {
+	const methodPrefix = "RestApiConfig.responseBatch: "
+	var items []RestApiBatchItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		sendHttpError(w, fmt.Sprintf("%sinvalid request data: %v", methodPrefix, err), http.StatusBadRequest)
+		return
+	}
+	if config.MaxBatchSize > 0 && len(items) > config.MaxBatchSize {
+		sendHttpError(w, fmt.Sprintf("%stoo many operations: %d, max %d", methodPrefix, len(items), config.MaxBatchSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ctx := r.Context()
+	if config.Context != nil {
+		ctx = config.Context(r)
+	}
+	factory := config.Def.Factory
+	ctx, tx, err := factory.BeginTranContext(ctx)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%s%v", methodPrefix, err), http.StatusInternalServerError)
+		return
+	}
+	// entities changed in memory by rolled back operations shouldn't stay in cache
+	forget := func(refs ...string) {
+		for _, ref := range refs {
+			factory.loadedEntities.Remove(ref)
+		}
+	}
+
+	results := make([]RestApiBatchResult, len(items))
+	failed := -1
+	for i, item := range items {
+		itemCtx, itemTx := ctx, tx
+		if !config.BatchAllOrNothing {
+			if itemCtx, itemTx, err = factory.BeginTranContext(ctx); err != nil {
+				_ = factory.RollbackTran(tx)
+				sendHttpError(w, fmt.Sprintf("%s%v", methodPrefix, err), http.StatusInternalServerError)
+				return
+			}
+		}
+		res, status, err := batchOperation(config, itemCtx, item)
+		if err == nil && itemTx != tx {
+			if err = factory.CommitTran(itemTx); err != nil {
+				status = http.StatusInternalServerError
+			}
+		}
+		if err == nil {
+			results[i] = res
+			continue
+		}
+		results[i] = RestApiBatchResult{Status: errorStatus(err, status), Ref: item.Ref, Error: err.Error()}
+		if config.BatchAllOrNothing {
+			failed = i
+			break
+		}
+		_ = factory.RollbackTran(itemTx)
+		forget(item.Ref)
+	}
+
+	if failed >= 0 {
+		_ = factory.RollbackTran(tx)
+		for i, item := range items {
+			if i != failed {
+				forget(results[i].Ref)
+				results[i] = RestApiBatchResult{Status: http.StatusFailedDependency, Ref: item.Ref, Error: fmt.Sprintf("operation %d failed", failed)}
+			}
+		}
+		forget(items[failed].Ref)
+		w.WriteHeader(results[failed].Status)
+	} else if err = factory.CommitTran(tx); err != nil {
+		for _, res := range results {
+			forget(res.Ref)
+		}
+		sendHttpError(w, fmt.Sprintf("%s%v", methodPrefix, err), http.StatusInternalServerError)
+		return
+	}
+
+	if err = json.NewEncoder(w).Encode(results); err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to encode results to JSON: %v", methodPrefix, err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,113 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestApi_Batch(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "batch.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	goodDef, _ := factory.CreateEntityDef("BtGood", "BtGoods")
+	_, _ = goodDef.AddStringFieldDef("Caption", 50)
+	_, _ = goodDef.AddIntFieldDef("Qty")
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	ctx := context.Background()
+	seed := func(caption string) *Entity {
+		e, _ := factory.CreateEntity(goodDef)
+		e.Values["Caption"].(*FieldValueString).Set(caption)
+		e.Values["Qty"].(*FieldValueInt).Set(1)
+		if err := e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return e
+	}
+	a := seed("a")
+	b := seed("b")
+	table, _ := goodDef.SqlTableName()
+	stored := func() (count int, qtyA int64) {
+		_ = factory.db.QueryRow("select count(*) from " + table).Scan(&count)
+		_ = factory.db.QueryRow("select qty from "+table+" where ref=$1", a.RefString()).Scan(&qtyA)
+		return
+	}
+
+	config := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	config.EnableBatch = true
+	config.EnablePatch = true
+	batch := func(body string) (int, []RestApiBatchResult) {
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, httptest.NewRequest(http.MethodPost, "/goods?batch", strings.NewReader(body)))
+		var results []RestApiBatchResult
+		if w.Code < 300 || strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+				t.Fatalf("json.Unmarshal(%s) error = %v", w.Body.String(), err)
+			}
+		}
+		return w.Code, results
+	}
+	statuses := func(results []RestApiBatchResult) []int {
+		res := make([]int, 0, len(results))
+		for _, r := range results {
+			res = append(res, r.Status)
+		}
+		return res
+	}
+	ops := `[
+		{"Op": "create", "Data": {"Caption": "c", "Qty": 3}},
+		{"Op": "patch", "Ref": "` + a.RefString() + `", "Data": {"Qty": 5}},
+		{"Op": "delete", "Ref": "` + b.RefString() + `"},
+		{"Op": "patch", "Ref": "` + a.RefString() + `", "Data": {"Qty": "x"}}
+	]`
+
+	// all-or-nothing
+	code, results := batch(ops)
+	if code != http.StatusUnprocessableEntity || len(results) != 4 || results[3].Error == "" {
+		t.Fatalf("all-or-nothing batch = %d %v", code, results)
+	}
+	if got := statuses(results); got[0] != http.StatusFailedDependency || got[1] != http.StatusFailedDependency || got[2] != http.StatusFailedDependency {
+		t.Errorf("all-or-nothing batch statuses = %v", got)
+	}
+	if count, qty := stored(); count != 2 || qty != 1 {
+		t.Errorf("all-or-nothing batch isn't rolled back: count = %d, qty = %d", count, qty)
+	}
+	if loaded, err := factory.LoadEntity(a.RefString()); err != nil || loaded.Values["Qty"].(*FieldValueInt).Get() != 1 {
+		t.Errorf("all-or-nothing batch left changed entity in cache")
+	}
+
+	// best-effort
+	config.BatchAllOrNothing = false
+	code, results = batch(ops)
+	want := []int{http.StatusCreated, http.StatusOK, http.StatusOK, http.StatusUnprocessableEntity}
+	if got := statuses(results); code != http.StatusOK || len(got) != 4 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Fatalf("best-effort batch = %d %v, want %v", code, got, want)
+	}
+	if results[0].Ref == "" || results[0].ETag == "" || results[0].Data == nil {
+		t.Errorf("best-effort batch create result = %+v", results[0])
+	}
+	if count, qty := stored(); count != 2 || qty != 5 {
+		t.Errorf("best-effort batch: count = %d, qty = %d, want 2, 5", count, qty)
+	}
+
+	code, results = batch(`[{"Op": "merge"}, {"Op": "update", "Ref": "` + a.RefString() + `", "IfMatch": "\"stale\"", "Data": {}}, {"Op": "delete"}]`)
+	if got := statuses(results); code != http.StatusOK || got[0] != http.StatusBadRequest || got[1] != http.StatusPreconditionFailed || got[2] != http.StatusBadRequest {
+		t.Errorf("batch with invalid operations = %d %v", code, got)
+	}
+
+	config.MaxBatchSize = 2
+	if code, _ = batch(ops); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("too large batch = %d, want 413", code)
+	}
+	if code, _ = batch(`{}`); code != http.StatusBadRequest {
+		t.Errorf("batch with object body = %d, want 400", code)
+	}
+}
@@ -0,0 +1,240 @@
+package elorm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultMaxExpandDepth is the default maximum nesting of refs expanded by REST API expand parameter.
+const DefaultMaxExpandDepth = 2
+
+// jsonShape defines fields of entity in JSON output and refs expanded into nested objects
+type jsonShape struct {
+	fields map[*FieldDef]bool // nil means usual JSON of entity (fields loaded by SelectPartial or all fields)
+	expand map[*FieldDef]*jsonShape
+}
+
+// expandNode is an item of expand parameter: Name or Name(child, ...)
+type expandNode struct {
+	name     string
+	children []*expandNode // nil when item has no parentheses
+}
+
+// parseExpand parses expand parameter, e.g. "OwnerShop(Caption,City(Name)),CreatedBy"
+func parseExpand(s string) ([]*expandNode, error) {
+	pos := 0
+	var parseList func(depth int) ([]*expandNode, error)
+	parseList = func(depth int) ([]*expandNode, error) {
+		res := make([]*expandNode, 0)
+		for {
+			start := pos
+			for pos < len(s) && !strings.ContainsRune("(),", rune(s[pos])) {
+				pos++
+			}
+			node := &expandNode{name: strings.TrimSpace(s[start:pos])}
+			if node.name == "" {
+				return nil, fmt.Errorf("empty name at position %d", start+1)
+			}
+			if pos < len(s) && s[pos] == '(' {
+				pos++
+				children, err := parseList(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				if pos >= len(s) || s[pos] != ')' {
+					return nil, fmt.Errorf("missing ')' at position %d", pos+1)
+				}
+				pos++
+				node.children = children
+			}
+			res = append(res, node)
+			if pos >= len(s) || s[pos] == ')' {
+				if pos < len(s) && depth == 0 {
+					return nil, fmt.Errorf("unexpected ')' at position %d", pos+1)
+				}
+				return res, nil
+			}
+			if s[pos] != ',' {
+				return nil, fmt.Errorf("unexpected '%c' at position %d", s[pos], pos+1)
+			}
+			pos++
+		}
+	}
+	return parseList(0)
+}
+
+// parseFieldsParam returns shape with fields listed in fields parameter (e.g. "Caption,Price") and Ref
+func parseFieldsParam(def *EntityDef, s string) (*jsonShape, error) {
+	res := &jsonShape{fields: map[*FieldDef]bool{def.RefField: true}, expand: make(map[*FieldDef]*jsonShape)}
+	for _, name := range strings.Split(s, ",") {
+		fd := def.FieldDefByName(strings.TrimSpace(name))
+		if fd == nil {
+			return nil, fmt.Errorf("unknown field %s", strings.TrimSpace(name))
+		}
+		res.fields[fd] = true
+	}
+	return res, nil
+}
+
+// shapeBuilder checks expand parameter against allowed paths and builds shapes of expanded refs
+type shapeBuilder struct {
+	allowed  []string
+	maxDepth int
+}
+
+func (T *shapeBuilder) isAllowed(path string) bool {
+	return slices.ContainsFunc(T.allowed, func(s string) bool { return strings.EqualFold(s, path) })
+}
+
+// canExpand checks that some fields of entity referenced by path are allowed
+func (T *shapeBuilder) canExpand(path string) bool {
+	return slices.ContainsFunc(T.allowed, func(s string) bool {
+		return len(s) > len(path)+1 && strings.EqualFold(s[:len(path)+1], path+".")
+	})
+}
+
+// expandRef builds shape of entity referenced by fd at path (e.g. "OwnerShop.City") for expand item
+func (T *shapeBuilder) expandRef(fd *FieldDef, path string, node *expandNode, depth int) (*jsonShape, error) {
+	if fd.Type != FieldDefTypeRef || fd.Name == RefFieldName {
+		return nil, fmt.Errorf("field %s isn't ref field", path)
+	}
+	if depth > T.maxDepth {
+		return nil, fmt.Errorf("expansion of %s is deeper than %d", path, T.maxDepth)
+	}
+	if !T.canExpand(path) {
+		return nil, fmt.Errorf("expansion of %s isn't allowed", path)
+	}
+	def := fd.EntityDef
+	res := &jsonShape{fields: map[*FieldDef]bool{def.RefField: true}, expand: make(map[*FieldDef]*jsonShape)}
+	if node.children == nil { // all allowed fields
+		all := T.isAllowed(path + ".*")
+		for _, cfd := range def.FieldDefs {
+			if all || T.isAllowed(path+"."+cfd.Name) {
+				res.fields[cfd] = true
+			}
+		}
+		return res, nil
+	}
+	for _, child := range node.children {
+		cfd := def.FieldDefByName(child.name)
+		if cfd == nil {
+			return nil, fmt.Errorf("unknown field %s.%s", path, child.name)
+		}
+		cpath := path + "." + cfd.Name
+		if child.children != nil {
+			sub, err := T.expandRef(cfd, cpath, child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res.expand[cfd] = sub
+		} else if !T.isAllowed(path+".*") && !T.isAllowed(cpath) {
+			return nil, fmt.Errorf("field %s isn't allowed", cpath)
+		}
+		res.fields[cfd] = true
+	}
+	return res, nil
+}
+
+// jsonShapeOf returns shape of JSON output defined by fields and expand parameters, nil when both are empty.
+// Allowed lists paths of fields of referenced entities which may be expanded.
+func jsonShapeOf(def *EntityDef, fields string, expand string, allowed []string, maxDepth int) (*jsonShape, error) {
+	if fields == "" && expand == "" {
+		return nil, nil
+	}
+	res := &jsonShape{expand: make(map[*FieldDef]*jsonShape)}
+	if fields != "" {
+		var err error
+		if res, err = parseFieldsParam(def, fields); err != nil {
+			return nil, err
+		}
+	}
+	if expand == "" {
+		return res, nil
+	}
+	nodes, err := parseExpand(expand)
+	if err != nil {
+		return nil, err
+	}
+	b := &shapeBuilder{allowed: allowed, maxDepth: maxDepth}
+	for _, node := range nodes {
+		fd := def.FieldDefByName(node.name)
+		if fd == nil {
+			return nil, fmt.Errorf("unknown field %s", node.name)
+		}
+		sub, err := b.expandRef(fd, fd.Name, node, 1)
+		if err != nil {
+			return nil, err
+		}
+		res.expand[fd] = sub
+		if res.fields != nil {
+			res.fields[fd] = true
+		}
+	}
+	return res, nil
+}
+
+// shapedMap converts entity to map for JSON output by shape, with access to fields decided for ctx
+func (T *Entity) shapedMap(ctx context.Context, shape *jsonShape) (map[string]any, error) {
+	fields := shape.fields
+	if fields == nil {
+		fields = T.partial
+	}
+	vm, err := T.valuesToMap(ctx, fields)
+	if err != nil {
+		return nil, fmt.Errorf("Entity.shapedMap: %w", err)
+	}
+	for fd, sub := range shape.expand {
+		ref := T.Values[fd.Name].AsString()
+		if ref == "" || !T.Factory.canReadField(ctx, fd) {
+			continue
+		}
+		e, err := T.Factory.LoadEntityContext(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("Entity.shapedMap: failed to load %s of %s: %w", fd.Name, T.RefString(), err)
+		}
+		if vm[fd.Name], err = e.shapedMap(ctx, sub); err != nil {
+			return nil, err
+		}
+	}
+	return vm, nil
+}
+
+// shapedEntity returns entity for JSON output, converted to map by shape when shape isn't nil. Entity is converted
+// to map anyway when Factory.FieldAccessPolicy is set, access to fields is decided for ctx.
+func shapedEntity[E IEntity](ctx context.Context, shape *jsonShape, entity E) (any, error) {
+	if shape == nil && !hasFieldAccessPolicy(entity) {
+		return entity, nil
+	}
+	base, ok := any(entity).(interface{ baseEntity() *Entity })
+	if !ok {
+		return nil, fmt.Errorf("shapedEntity: entity of type %T doesn't support fields and expand", entity)
+	}
+	if shape == nil {
+		shape = &jsonShape{}
+	}
+	return base.baseEntity().shapedMap(ctx, shape)
+}
+
+// shapedList returns list of entities for JSON output like shapedEntity
+func shapedList[E IEntity](ctx context.Context, shape *jsonShape, list []E) (any, error) {
+	if shape == nil && (len(list) == 0 || !hasFieldAccessPolicy(list[0])) {
+		return list, nil
+	}
+	res := make([]any, 0, len(list))
+	for _, e := range list {
+		m, err := shapedEntity(ctx, shape, e)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// hasFieldAccessPolicy checks that access to fields of entity is decided by Factory.FieldAccessPolicy
+func hasFieldAccessPolicy(entity IEntity) bool {
+	base, ok := entity.(interface{ baseEntity() *Entity })
+	return ok && base.baseEntity().Factory.FieldAccessPolicy != nil
+}
@@ -0,0 +1,156 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRestApi_FieldsAndExpand(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "shape.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	userDef, _ := factory.CreateEntityDef("ShUser", "ShUsers")
+	_, _ = userDef.AddStringFieldDef("Username", 50)
+	_, _ = userDef.AddStringFieldDef("PasswordHash", 100)
+	cityDef, _ := factory.CreateEntityDef("ShCity", "ShCities")
+	_, _ = cityDef.AddStringFieldDef("Name", 50)
+	shopDef, _ := factory.CreateEntityDef("ShShop", "ShShops")
+	_, _ = shopDef.AddStringFieldDef("Caption", 50)
+	_, _ = shopDef.AddRefFieldDef("City", cityDef)
+	goodDef, _ := factory.CreateEntityDef("ShGood", "ShGoods")
+	_, _ = goodDef.AddStringFieldDef("Caption", 50)
+	_, _ = goodDef.AddIntFieldDef("Price")
+	_, _ = goodDef.AddRefFieldDef("OwnerShop", shopDef)
+	_, _ = goodDef.AddRefFieldDef("CreatedBy", userDef)
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	user, _ := factory.CreateEntity(userDef)
+	user.Values["Username"].(*FieldValueString).Set("bob")
+	user.Values["PasswordHash"].(*FieldValueString).Set("secret")
+	city, _ := factory.CreateEntity(cityDef)
+	city.Values["Name"].(*FieldValueString).Set("Oslo")
+	shop, _ := factory.CreateEntity(shopDef)
+	shop.Values["Caption"].(*FieldValueString).Set("corner")
+	_ = shop.Values["City"].(*FieldValueRef).Set(city)
+	good, _ := factory.CreateEntity(goodDef)
+	good.Values["Caption"].(*FieldValueString).Set("apple")
+	good.Values["Price"].(*FieldValueInt).Set(10)
+	_ = good.Values["OwnerShop"].(*FieldValueRef).Set(shop)
+	_ = good.Values["CreatedBy"].(*FieldValueRef).Set(user)
+	for _, e := range []*Entity{user, city, shop, good} {
+		if err = e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	config := CreateStdRestApiConfig(goodDef, factory.LoadEntity, goodDef.SelectEntities,
+		func() (*Entity, error) { return factory.CreateEntity(goodDef) })
+	config.SelectEntitiesPageFunc = goodDef.SelectEntitiesPage
+	config.ExpandFields = []string{"OwnerShop.*", "OwnerShop.City.Name", "CreatedBy.Username"}
+	get := func(query string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, httptest.NewRequest(http.MethodGet, "/goods?"+query, nil))
+		var res map[string]any
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal(%s) error = %v", w.Body.String(), err)
+			}
+		}
+		return w.Code, res
+	}
+	keys := func(m any) []string {
+		res := make([]string, 0)
+		for k := range m.(map[string]any) {
+			res = append(res, k)
+		}
+		slices.Sort(res)
+		return res
+	}
+	ref := "ref=" + good.RefString()
+
+	code, res := get(ref + "&fields=Caption")
+	if code != http.StatusOK || !slices.Equal(keys(res), []string{"Caption", "Ref"}) {
+		t.Errorf("GET with fields = %d %v", code, res)
+	}
+
+	code, res = get(ref + "&expand=OwnerShop(Caption,City(Name)),CreatedBy")
+	if code != http.StatusOK || res["Price"] != 10.0 {
+		t.Fatalf("GET with expand = %d %v", code, res)
+	}
+	if got := keys(res["OwnerShop"]); !slices.Equal(got, []string{"Caption", "City", "Ref"}) {
+		t.Errorf("expanded OwnerShop fields = %v", got)
+	}
+	if got := res["OwnerShop"].(map[string]any)["City"]; got.(map[string]any)["Name"] != "Oslo" {
+		t.Errorf("expanded OwnerShop.City = %v", got)
+	}
+	if got := keys(res["CreatedBy"]); !slices.Equal(got, []string{"Ref", "Username"}) {
+		t.Errorf("expanded CreatedBy fields = %v, PasswordHash shouldn't be there", got)
+	}
+
+	// ETag of expanded entity follows referenced entities
+	getTag := func(inm string) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, "/goods?"+ref+"&expand=OwnerShop(City(Name))", nil)
+		r.Header.Set("If-None-Match", inm)
+		w := httptest.NewRecorder()
+		HandleRestApi(config)(w, r)
+		return w.Code, w.Header().Get("ETag")
+	}
+	_, tag := getTag("")
+	if code, _ = getTag(tag); code != http.StatusNotModified {
+		t.Errorf("GET with expand and the same ETag = %d, want 304", code)
+	}
+	city.Values["Name"].(*FieldValueString).Set("Bergen")
+	if err = city.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if code, newTag := getTag(tag); code != http.StatusOK || newTag == tag {
+		t.Errorf("GET with expand after change of referenced entity = %d, ETag %s, want 200 and new ETag", code, newTag)
+	}
+
+	for _, query := range []string{
+		"fields=Unknown",
+		"expand=CreatedBy(PasswordHash)",
+		"expand=Caption",
+		"expand=OwnerShop(City(Name)",
+		"expand=OwnerShop),Caption",
+		"expand=OwnerShop,,CreatedBy",
+	} {
+		if code, _ = get(ref + "&" + query); code != http.StatusBadRequest {
+			t.Errorf("GET with %s = %d, want 400", query, code)
+		}
+	}
+	config.MaxExpandDepth = 1
+	if code, _ = get(ref + "&expand=OwnerShop(City(Name))"); code != http.StatusBadRequest {
+		t.Errorf("GET with too deep expand = %d, want 400", code)
+	}
+	config.MaxExpandDepth = DefaultMaxExpandDepth
+
+	// lists with both kinds of pagination
+	for _, query := range []string{"fields=Price&expand=OwnerShop", "fields=Price&expand=OwnerShop&cursor="} {
+		code, res = get(query)
+		if code != http.StatusOK || len(res["Data"].([]any)) != 1 {
+			t.Fatalf("GET list with %s = %d %v", query, code, res)
+		}
+		item := res["Data"].([]any)[0]
+		if got := keys(item); !slices.Equal(got, []string{"OwnerShop", "Price", "Ref"}) {
+			t.Errorf("list item fields with %s = %v", query, got)
+		}
+		if got := item.(map[string]any)["OwnerShop"].(map[string]any)["Caption"]; got != "corner" {
+			t.Errorf("list item OwnerShop.Caption with %s = %v", query, got)
+		}
+	}
+
+	config.ExpandFields = nil
+	if code, _ = get(ref + "&expand=OwnerShop"); code != http.StatusBadRequest {
+		t.Errorf("GET with expand without ExpandFields = %d, want 400", code)
+	}
+}
@@ -0,0 +1,194 @@
+package elorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Schema change kinds for SchemaChange.Kind
+const (
+	SchemaChangeCreateType     = 100
+	SchemaChangeCreateTable    = 200
+	SchemaChangeAddColumn      = 300
+	SchemaChangeAlterColumn    = 400
+	SchemaChangeDropIndex      = 500
+	SchemaChangeCreateIndex    = 600
+	SchemaChangeDropColumn     = 700
+	SchemaChangeRebuildTable   = 800 // one of statements rebuilding table, used by dialects which can't alter or drop columns (SQLite)
+	SchemaChangeRenameTable    = 900
+	SchemaChangeRenameColumn   = 1000
+	SchemaChangeMigration      = 1100 // custom migration step registered by Factory.AddMigration, it runs Go code instead of Sql
+	SchemaChangeAddForeignKey  = 1200
+	SchemaChangeDropForeignKey = 1300
+	SchemaChangeNullEmptyRefs  = 1400 // replaces empty refs with NULL before foreign key is created
+	SchemaChangeRecordHistory  = 1500 // records rename or custom migration step to elorm_schema_history table
+)
+
+// SchemaChange describes one planned database structure change.
+type SchemaChange struct {
+	Kind    int    // one of SchemaChange* constants
+	Table   string // table name, empty for SchemaChangeCreateType
+	Name    string // column, index or type name
+	From    string // previous table or column name for renames
+	Version int64  // migration version for SchemaChangeMigration
+	Sql     string // statement to apply the change
+}
+
+// String returns human-readable description of the change
+func (T *SchemaChange) String() string {
+	switch T.Kind {
+	case SchemaChangeCreateType:
+		return fmt.Sprintf("create type %s", T.Name)
+	case SchemaChangeCreateTable:
+		return fmt.Sprintf("create table %s", T.Table)
+	case SchemaChangeAddColumn:
+		return fmt.Sprintf("add column %s.%s", T.Table, T.Name)
+	case SchemaChangeAlterColumn:
+		return fmt.Sprintf("alter column %s.%s", T.Table, T.Name)
+	case SchemaChangeDropIndex:
+		return fmt.Sprintf("drop index %s on %s", T.Name, T.Table)
+	case SchemaChangeCreateIndex:
+		return fmt.Sprintf("create index %s on %s", T.Name, T.Table)
+	case SchemaChangeDropColumn:
+		return fmt.Sprintf("drop column %s.%s", T.Table, T.Name)
+	case SchemaChangeRebuildTable:
+		return fmt.Sprintf("rebuild table %s", T.Table)
+	case SchemaChangeRenameTable:
+		return fmt.Sprintf("rename table %s to %s", T.From, T.Table)
+	case SchemaChangeRenameColumn:
+		return fmt.Sprintf("rename column %s.%s to %s", T.Table, T.From, T.Name)
+	case SchemaChangeAddForeignKey:
+		return fmt.Sprintf("add foreign key %s on %s", T.Name, T.Table)
+	case SchemaChangeDropForeignKey:
+		return fmt.Sprintf("drop foreign key %s on %s", T.Name, T.Table)
+	case SchemaChangeNullEmptyRefs:
+		return fmt.Sprintf("set empty refs of %s.%s to null", T.Table, T.Name)
+	case SchemaChangeMigration:
+		return fmt.Sprintf("run migration %d %s", T.Version, T.Name)
+	case SchemaChangeRecordHistory:
+		return fmt.Sprintf("record %s to schema history", T.Name)
+	default:
+		return fmt.Sprintf("unknown change %d for %s.%s", T.Kind, T.Table, T.Name)
+	}
+}
+
+// SchemaPlan is an ordered list of changes to bring database structure in line with entity definitions.
+// It is created by Factory.PlanDBStructure and can be reviewed, exported as SQL script or applied by Factory.ApplyDBStructure.
+type SchemaPlan struct {
+	Changes []*SchemaChange
+}
+
+func (T *SchemaPlan) add(kind int, table string, name string, sql string) {
+	T.Changes = append(T.Changes, &SchemaChange{Kind: kind, Table: table, Name: name, Sql: sql})
+}
+
+// IsEmpty returns true when database structure is up to date
+func (T *SchemaPlan) IsEmpty() bool {
+	return len(T.Changes) == 0
+}
+
+// Sql returns all planned statements as SQL script, one statement per line
+func (T *SchemaPlan) Sql() string {
+	var sb strings.Builder
+	for _, c := range T.Changes {
+		sb.WriteString(c.Sql)
+		sb.WriteString(";\n")
+	}
+	return sb.String()
+}
+
+// PlanDBStructure compares entity definitions with the database structure and returns planned changes without applying them.
+func (T *Factory) PlanDBStructure() (*SchemaPlan, error) {
+	plan := &SchemaPlan{Changes: make([]*SchemaChange, 0)}
+
+	stmts, err := T.dialect.RefTypeSql(T)
+	if err != nil {
+		return nil, fmt.Errorf("Factory.PlanDBStructure: failed to check ref column type: %w", err)
+	}
+	for _, stmt := range stmts {
+		plan.add(SchemaChangeCreateType, "", refTypeName, stmt)
+	}
+
+	err = T.planSchemaHistoryTable(plan)
+	if err != nil {
+		return nil, fmt.Errorf("Factory.PlanDBStructure: %w", err)
+	}
+
+	foreignKeys := &SchemaPlan{Changes: make([]*SchemaChange, 0)}
+	planned := make(map[*EntityDef]bool, len(T.EntityDefs))
+	for _, def := range T.EntityDefs {
+		if planned[def] {
+			continue
+		}
+		planned[def] = true
+		err := def.planDBStructure(plan, foreignKeys)
+		if err != nil {
+			return nil, fmt.Errorf("Factory.PlanDBStructure: %w", err)
+		}
+	}
+	plan.Changes = append(plan.Changes, foreignKeys.Changes...)
+
+	err = T.planMigrations(plan)
+	if err != nil {
+		return nil, fmt.Errorf("Factory.PlanDBStructure: %w", err)
+	}
+	return plan, nil
+}
+
+// ApplyDBStructure applies changes planned by PlanDBStructure in one transaction. Custom migration steps run in the same transaction.
+// Changes are applied on dedicated connection prepared by Dialect.PrepareSchemaConn. Postgres, MSSQL and SQLite roll back
+// all changes on failure. MySQL commits each structure change implicitly (see Dialect.TransactionalDDL), so changes before
+// the failed one stay applied there and error tells how many of them; plan again to continue from the actual structure.
+func (T *Factory) ApplyDBStructure(plan *SchemaPlan) (err error) {
+	if plan == nil {
+		return fmt.Errorf("Factory.ApplyDBStructure: plan is nil")
+	}
+	ctx := context.Background()
+	conn, err := T.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("Factory.ApplyDBStructure: failed to get connection: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	restore, err := T.dialect.PrepareSchemaConn(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("Factory.ApplyDBStructure: failed to prepare connection: %w", err)
+	}
+	defer func() {
+		if rerr := restore(); rerr != nil && err == nil {
+			err = fmt.Errorf("Factory.ApplyDBStructure: failed to restore connection: %w", rerr)
+		}
+	}()
+	return T.withTx(ctx, conn, func(ctx context.Context) error {
+		for i, c := range plan.Changes {
+			var err error
+			if c.Kind == SchemaChangeMigration {
+				err = T.runMigration(ctx, c.Version)
+			} else {
+				_, err = T.ExecContext(ctx, c.Sql)
+			}
+			if err != nil && !T.dialect.TransactionalDDL() {
+				return fmt.Errorf("Factory.ApplyDBStructure: failed to %s, %d of %d changes before it may stay applied: %w", c, i, len(plan.Changes), err)
+			}
+			if err != nil {
+				return fmt.Errorf("Factory.ApplyDBStructure: failed to %s: %w", c, err)
+			}
+		}
+		return nil
+	})
+}
+
+// EnsureDBStructure plans and applies database structure changes, see PlanDBStructure.
+func (T *Factory) EnsureDBStructure() error {
+	plan, err := T.PlanDBStructure()
+	if err != nil {
+		return fmt.Errorf("Factory.EnsureDBStructure: failed to plan DB structure: %w", err)
+	}
+	err = T.ApplyDBStructure(plan)
+	if err != nil {
+		return fmt.Errorf("Factory.EnsureDBStructure: failed to ensure DB structure: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package elorm
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFactory_PlanDBStructure(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "plan.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	def, err := factory.CreateEntityDef("PlanItem", "PlanItems")
+	if err != nil {
+		t.Fatalf("CreateEntityDef() error = %v", err)
+	}
+	code, _ := def.AddStringFieldDef("Code", 20)
+	if err = def.AddIndex(true, code); err != nil {
+		t.Fatalf("AddIndex() error = %v", err)
+	}
+
+	kinds := func(plan *SchemaPlan) []int {
+		res := make([]int, 0, len(plan.Changes))
+		for _, c := range plan.Changes {
+			res = append(res, c.Kind)
+		}
+		return res
+	}
+
+	plan, err := factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	want := []int{SchemaChangeCreateTable, SchemaChangeCreateTable, SchemaChangeAddColumn, SchemaChangeAddColumn, SchemaChangeAddColumn, SchemaChangeCreateIndex}
+	if got := kinds(plan); !slices.Equal(got, want) {
+		t.Fatalf("PlanDBStructure() kinds = %v, want %v", got, want)
+	}
+	if sql := plan.Sql(); !strings.Contains(sql, "create table if not exists planitems") || !strings.Contains(sql, "create unique index planitems_idx_by_code__uniq") ||
+		!strings.Contains(sql, "create table elorm_schema_history") {
+		t.Errorf("unexpected plan SQL:\n%s", sql)
+	}
+
+	// planning is a dry run
+	plan2, err := factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	if len(plan2.Changes) != len(plan.Changes) {
+		t.Errorf("PlanDBStructure() changed database structure")
+	}
+
+	if err = factory.ApplyDBStructure(plan); err != nil {
+		t.Fatalf("ApplyDBStructure() error = %v", err)
+	}
+	plan, err = factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("PlanDBStructure() after apply = %v, want empty", plan.Sql())
+	}
+
+	name, _ := def.AddStringFieldDef("Name", 50)
+	def.IndexDefs = nil
+	if err = def.AddIndex(false, name); err != nil {
+		t.Fatalf("AddIndex() error = %v", err)
+	}
+	plan, err = factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	want = []int{SchemaChangeDropIndex, SchemaChangeAddColumn, SchemaChangeCreateIndex}
+	if got := kinds(plan); !slices.Equal(got, want) {
+		t.Fatalf("PlanDBStructure() kinds = %v, want %v", got, want)
+	}
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+}
+
+func TestFactory_PlanDBStructure_Rebuild(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "rebuild.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	def, err := factory.CreateEntityDef("RebuildItem", "RebuildItems")
+	if err != nil {
+		t.Fatalf("CreateEntityDef() error = %v", err)
+	}
+	code, _ := def.AddStringFieldDef("Code", 5)
+	_, _ = def.AddStringFieldDef("Obsolete", 10)
+	if err = def.AddIndex(true, code); err != nil {
+		t.Fatalf("AddIndex() error = %v", err)
+	}
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ent, _ := factory.CreateEntity(def)
+	ent.Values["Code"].(*FieldValueString).Set("abc")
+	if err = ent.Save(context.Background()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	code.Len = 50
+	def.FieldDefs = slices.DeleteFunc(def.FieldDefs, func(fd *FieldDef) bool { return fd.Name == "Obsolete" })
+
+	plan, err := factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	for _, c := range plan.Changes {
+		if c.Kind == SchemaChangeDropColumn {
+			t.Errorf("orphan column is dropped without DropOrphanColumns: %s", c.Sql)
+		}
+	}
+	if !strings.Contains(plan.Sql(), "create table rebuilditems__elorm_new") {
+		t.Errorf("expected table rebuild, got:\n%s", plan.Sql())
+	}
+
+	factory.DropOrphanColumns = true
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	cols, err := factory.dialect.TableColumns(factory, "rebuilditems")
+	if err != nil {
+		t.Fatalf("TableColumns() error = %v", err)
+	}
+	for _, c := range cols {
+		if c.Name == "obsolete" {
+			t.Errorf("orphan column was not dropped")
+		}
+		if c.Name == "code" && c.Type != "varchar(50)" {
+			t.Errorf("column type = %s, want varchar(50)", c.Type)
+		}
+	}
+
+	plan, err = factory.PlanDBStructure()
+	if err != nil {
+		t.Fatalf("PlanDBStructure() error = %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("PlanDBStructure() after rebuild = %v, want empty", plan.Sql())
+	}
+
+	factory.loadedEntities.Purge()
+	loaded, err := factory.LoadEntity(ent.RefString())
+	if err != nil {
+		t.Fatalf("LoadEntity() error = %v", err)
+	}
+	if got := loaded.Values["Code"].(*FieldValueString).Get(); got != "abc" {
+		t.Errorf("data was not preserved by rebuild, Code = %s", got)
+	}
+}
+
+// spMySQLLikeDialect is SQLite which reports implicit commits of structure changes like MySQL
+type spMySQLLikeDialect struct {
+	SQLiteDialect
+}
+
+func (T spMySQLLikeDialect) TransactionalDDL() bool {
+	return false
+}
+
+func TestFactory_ApplyDBStructure_Failed(t *testing.T) {
+	for _, tt := range []struct {
+		dialect Dialect
+		wantErr string
+	}{
+		{dialect: SQLiteDialect{}, wantErr: "failed to add column spitems.broken"},
+		{dialect: spMySQLLikeDialect{}, wantErr: "failed to add column spitems.broken, 4 of 5 changes before it may stay applied"},
+	} {
+		factory, err := CreateFactoryWithDialect(tt.dialect, "file:"+filepath.Join(t.TempDir(), "failed.db"))
+		if err != nil {
+			t.Fatalf("CreateFactoryWithDialect() error = %v", err)
+		}
+		if _, err = factory.CreateEntityDef("SpItem", "SpItems"); err != nil {
+			t.Fatalf("CreateEntityDef() error = %v", err)
+		}
+		plan, err := factory.PlanDBStructure()
+		if err != nil {
+			t.Fatalf("PlanDBStructure() error = %v", err)
+		}
+		plan.add(SchemaChangeAddColumn, "spitems", "broken", "alter table spitems add broken unknown syntax (")
+		if err = factory.ApplyDBStructure(plan); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ApplyDBStructure() error = %v, want %q", err, tt.wantErr)
+		}
+	}
+}
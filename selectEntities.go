@@ -1,7 +1,10 @@
 package elorm
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -18,16 +21,19 @@ const (
 	FilterNOTIN     = 120
 	FilterIsNULL    = 130
 	FilterIsNOTNULL = 140
+	FilterHasAny    = 150 // many-to-many relation has any of refs, see AddFilterHasAny
 	FilterAndGroup  = 200
 	FilterOrGroup   = 210
 )
 
 // Filter represents a filter condition for entity selection (SelectEntities).
 type Filter struct {
-	Op      int
-	LeftOp  *FieldDef
-	RightOp any
-	Childs  []*Filter
+	Op       int
+	LeftOp   *FieldDef
+	RightOp  any
+	Childs   []*Filter
+	Relation *ManyToManyDef // for FilterHasAny
+	Path     []*FieldDef    // ref fields leading from queried entity to LeftOp, see Through
 }
 
 // AddFilterEQ creates a filter for equality comparison.
@@ -200,11 +206,22 @@ var renderGroupOpsMap = map[int]string{
 	FilterOrGroup:  " or ",
 }
 
-func (T *Filter) renderWhereClause(f *Factory) (string, error) {
+// renderWhereClause renders filter as SQL condition. Values are not inlined, they are appended to args
+// and referenced as Postgres-style parameters ($1, $2, ...), so the query should be passed through Factory.PrepareSql.
+func (T *Filter) renderWhereClause(f *Factory, args *[]any) (string, error) {
+	return T.render(f, args, nil)
+}
+
+// render renders filter with column names qualified by joins (nil joins means plain column names)
+func (T *Filter) render(f *Factory, args *[]any, joins *sqlJoins) (string, error) {
+	addArg := func(v any) string {
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d", len(*args))
+	}
 	switch T.Op {
 	case FilterEQ, FilterNOEQ, FilterGE, FilterGT, FilterLT, FilterLE:
 		if T.LeftOp != nil && T.RightOp != nil {
-			colname, err := T.LeftOp.SqlColumnName()
+			colname, err := joins.column(T.Path, T.LeftOp)
 			if err != nil {
 				return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL column name: %w", err)
 			}
@@ -212,15 +229,21 @@ func (T *Filter) renderWhereClause(f *Factory) (string, error) {
 			if err != nil {
 				return "", fmt.Errorf("Filter.renderWhereClause: failed to create field value: %w", err)
 			}
-			rv, err := fv.SqlStringValue(T.RightOp)
+			rv, err := fv.SqlValue(T.RightOp)
 			if err != nil {
-				return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL string value: %w", err)
+				return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL value: %w", err)
+			}
+			if rv == nil && (T.Op == FilterEQ || T.Op == FilterNOEQ) { // e.g. empty ref with foreign key or zero time
+				if T.Op == FilterEQ {
+					return fmt.Sprintf("%s %s", colname, renderOpsMap[FilterIsNULL]), nil
+				}
+				return fmt.Sprintf("%s %s", colname, renderOpsMap[FilterIsNOTNULL]), nil
 			}
-			return fmt.Sprintf("%s %s %v", colname, renderOpsMap[T.Op], rv), nil
+			return fmt.Sprintf("%s %s %s", colname, renderOpsMap[T.Op], addArg(rv)), nil
 		}
 	case FilterLIKE:
 		if T.LeftOp != nil && T.RightOp != nil {
-			colname, err := T.LeftOp.SqlColumnName()
+			colname, err := joins.column(T.Path, T.LeftOp)
 			if err != nil {
 				return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL column name: %w", err)
 			}
@@ -228,12 +251,7 @@ func (T *Filter) renderWhereClause(f *Factory) (string, error) {
 			if !ok {
 				return "", fmt.Errorf("Filter.renderWhereClause: expected string for LIKE operation, got %T", T.RightOp)
 			}
-			// by default LIKE os case-sensitive on SQLITE
-			if f.dbDialect == DbDialectSQLite {
-				return fmt.Sprintf("LOWER(%s) LIKE '%v'", colname, strings.ToLower(rop)), nil
-			} else {
-				return fmt.Sprintf("%s LIKE '%v'", colname, rop), nil
-			}
+			return f.dialect.LikeClause(colname, addArg(strings.ToLower(rop))), nil
 		}
 	case FilterIN, FilterNOTIN:
 		if T.LeftOp != nil && T.RightOp != nil {
@@ -241,23 +259,31 @@ func (T *Filter) renderWhereClause(f *Factory) (string, error) {
 			if err != nil {
 				return "", fmt.Errorf("Filter.renderWhereClause: failed to create field value: %w", err)
 			}
-			colname, err := T.LeftOp.SqlColumnName()
+			colname, err := joins.column(T.Path, T.LeftOp)
 			if err != nil {
 				return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL column name: %w", err)
 			}
 			var values []string
 			for _, v := range T.RightOp.([]any) {
-				rv, err := fv.SqlStringValue(v)
+				rv, err := fv.SqlValue(v)
 				if err != nil {
-					return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL string value for IN/NOT IN: %w", err)
+					return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL value for IN/NOT IN: %w", err)
 				}
-				values = append(values, rv)
+				values = append(values, addArg(rv))
 			}
 			return fmt.Sprintf("%s %s (%s)", colname, renderOpsMap[T.Op], strings.Join(values, ", ")), nil
 		}
+	case FilterHasAny:
+		if T.Relation != nil && T.RightOp != nil {
+			clause, err := T.Relation.renderHasAny(f, T.RightOp.([]any), args, joins)
+			if err != nil {
+				return "", fmt.Errorf("Filter.renderWhereClause: %w", err)
+			}
+			return clause, nil
+		}
 	case FilterIsNULL, FilterIsNOTNULL:
 		if T.LeftOp != nil {
-			colname, err := T.LeftOp.SqlColumnName()
+			colname, err := joins.column(T.Path, T.LeftOp)
 			if err != nil {
 				return "", fmt.Errorf("Filter.renderWhereClause: failed to get SQL column name: %w", err)
 			}
@@ -266,7 +292,7 @@ func (T *Filter) renderWhereClause(f *Factory) (string, error) {
 	case FilterAndGroup, FilterOrGroup:
 		results := make([]string, len(T.Childs))
 		for i, v := range T.Childs {
-			clause, err := v.renderWhereClause(f)
+			clause, err := v.render(f, args, joins)
 			if err != nil {
 				return "", fmt.Errorf("Filter.renderWhereClause: failed to render child clause: %w", err)
 			}
@@ -281,13 +307,133 @@ func (T *Filter) renderWhereClause(f *Factory) (string, error) {
 type SortItem struct {
 	Field *FieldDef
 	Asc   bool
+	Path  []*FieldDef // ref fields leading from queried entity to Field
+}
+
+// renderWhere renders where clause for filters joined by "and" with soft delete condition when UseSoftDelete is true
+// and filters have no IsDeleted condition
+func (T *EntityDef) renderWhere(filters []*Filter, args *[]any, joins *sqlJoins) (string, error) {
+	var where strings.Builder
+	for i, f := range filters {
+		if i > 0 {
+			where.WriteString(" and ")
+		}
+		clause, err := f.render(T.Factory, args, joins)
+		if err != nil {
+			return "", fmt.Errorf("EntityDef.renderWhere: failed to render where clause: %w", err)
+		}
+		where.WriteString(clause)
+	}
+	if T.UseSoftDelete && !hasFilterOn(filters, T.IsDeletedField) {
+		if len(filters) > 0 {
+			where.WriteString(" and ")
+		}
+		clause, err := AddFilterEQ(T.IsDeletedField, false).render(T.Factory, args, joins)
+		if err != nil {
+			return "", fmt.Errorf("EntityDef.renderWhere: failed to render soft delete where clause: %w", err)
+		}
+		where.WriteString(clause)
+	}
+	if where.Len() == 0 {
+		return "", nil
+	}
+	return " where " + where.String(), nil
 }
 
 // SelectEntities retrieves entities from the database with filtering, sorting, and pagination.
 func (T *EntityDef) SelectEntities(filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) (result []*Entity, pagesCount int, err error) {
-	if filters == nil {
-		filters = []*Filter{}
+	return T.SelectEntitiesContext(context.Background(), filters, sorts, pageNo, pageSize)
+}
+
+// SelectEntitiesContext is like SelectEntities, but it reads database inside the transaction carried by ctx (see Factory.BeginTranContext), if any.
+func (T *EntityDef) SelectEntitiesContext(ctx context.Context, filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) (result []*Entity, pagesCount int, err error) {
+	return T.selectEntities(ctx, nil, filters, sorts, pageNo, pageSize)
+}
+
+// SelectPartial is like SelectEntities, but it loads only specified fields (Ref is loaded always). Partially loaded
+// entities aren't cached, they can't be saved and they are marshaled to JSON with loaded fields only.
+func (T *EntityDef) SelectPartial(fields []*FieldDef, filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) (result []*Entity, pagesCount int, err error) {
+	return T.SelectPartialContext(context.Background(), fields, filters, sorts, pageNo, pageSize)
+}
+
+// SelectPartialContext is like SelectPartial, but it reads database inside the transaction carried by ctx (see Factory.BeginTranContext), if any.
+func (T *EntityDef) SelectPartialContext(ctx context.Context, fields []*FieldDef, filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) (result []*Entity, pagesCount int, err error) {
+	partial := []*FieldDef{T.RefField}
+	for _, fd := range fields {
+		if !slices.Contains(T.FieldDefs, fd) {
+			return nil, 0, fmt.Errorf("EntityDef.SelectPartial: field is not field of %s", T.ObjectName)
+		}
+		if !slices.Contains(partial, fd) {
+			partial = append(partial, fd)
+		}
+	}
+	return T.selectEntities(ctx, partial, filters, sorts, pageNo, pageSize)
+}
+
+// selectSql renders select query for SelectEntities (count query when totals is true)
+func (T *EntityDef) selectSql(loadFields []*FieldDef, filters []*Filter, sorts []*SortItem, pageNo int, pageSize int, totals bool) (string, []any, error) {
+	var builder strings.Builder
+	args := make([]any, 0)
+	joins := newSqlJoins(T, filters, sorts)
+
+	// where and order by are rendered first, they define joins
+	where, err := T.renderWhere(filters, &args, joins)
+	if err != nil {
+		return "", nil, fmt.Errorf("EntityDef.selectSql: %w", err)
+	}
+	var orderBy strings.Builder
+	if len(sorts) > 0 && !totals {
+		orderBy.WriteString(" order by ")
+		sortClauses := make([]string, 0, len(sorts))
+		for _, s := range sorts {
+			if s.Field == nil {
+				continue
+			}
+			coln, err := joins.column(s.Path, s.Field)
+			if err != nil {
+				return "", nil, fmt.Errorf("EntityDef.selectSql: failed to get SQL column name for sort field: %w", err)
+			}
+			order := "ASC"
+			if !s.Asc {
+				order = "DESC"
+			}
+			sortClauses = append(sortClauses, fmt.Sprintf("%s %s", coln, order))
+		}
+		orderBy.WriteString(strings.Join(sortClauses, ", "))
+		if pageNo > 0 && pageSize > 0 {
+			orderBy.WriteString(T.Factory.dialect.PagingClause(pageSize, (pageNo-1)*pageSize))
+		}
+	}
+
+	fields := ""
+	if totals {
+		fields = "count(*) as total"
+	} else {
+		fnames := make([]string, 0, len(loadFields))
+		for _, v := range loadFields {
+			coln, err := joins.column(nil, v)
+			if err != nil {
+				return "", nil, fmt.Errorf("EntityDef.selectSql: failed to get SQL column name for field %s: %w", v.Name, err)
+			}
+			fnames = append(fnames, coln)
+		}
+		fields = strings.Join(fnames, ", ")
 	}
+	builder.WriteString("select ")
+	builder.WriteString(fields)
+	builder.WriteString(" from ")
+	tablename, err := T.SqlTableName()
+	if err != nil {
+		return "", nil, fmt.Errorf("EntityDef.selectSql: failed to get SQL table name: %w", err)
+	}
+	builder.WriteString(joins.from(tablename))
+	builder.WriteString(where)
+	builder.WriteString(orderBy.String())
+	return builder.String(), args, nil
+}
+
+// selectEntities selects entities, partial fields are loaded only when not nil
+func (T *EntityDef) selectEntities(ctx context.Context, partial []*FieldDef, filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) (result []*Entity, pagesCount int, err error) {
 	if sorts == nil {
 		// sort by ref by default
 		sorts = []*SortItem{{Field: T.RefField, Asc: true}}
@@ -298,167 +444,128 @@ func (T *EntityDef) SelectEntities(filters []*Filter, sorts []*SortItem, pageNo
 	if pageNo > 0 && pageSize > 0 && len(sorts) == 0 {
 		return nil, 0, fmt.Errorf("EntityDef.SelectEntities: pagination is only supported with sorting")
 	}
-	result = make([]*Entity, 0)
-	pagesCount = 0
-	getSql := func(totals bool) (string, error) {
-		var builder strings.Builder
-		fields := ""
-		if totals {
-			fields = "count(*) as total"
-		} else {
-			fnames := make([]string, 0, len(T.FieldDefs))
-			for _, v := range T.FieldDefs {
-				coln, err := v.SqlColumnName()
-				if err != nil {
-					return "", fmt.Errorf("EntityDef.SelectEntities: failed to get SQL column name for field %s: %w", v.Name, err)
-				}
-				fnames = append(fnames, coln)
-			}
-			fields = strings.Join(fnames, ", ")
-		}
-		builder.WriteString("select ")
-		builder.WriteString(fields)
-		builder.WriteString(" from ")
-		tablename, err := T.SqlTableName()
-		if err != nil {
-			return "", fmt.Errorf("EntityDef.SelectEntities: failed to get SQL table name: %w", err)
-		}
-		builder.WriteString(tablename)
-		for i := len(filters) - 1; i >= 0; i-- {
-			if filters[i] == nil {
-				filters = append(filters[:i], filters[i+1:]...)
-			}
-		}
-
-		if len(filters) > 0 {
-			builder.WriteString(" where ")
-			for i, f := range filters {
-				if i > 0 {
-					builder.WriteString(" and ")
-				}
-				clause, err := f.renderWhereClause(T.Factory)
-				if err != nil {
-					return "", fmt.Errorf("EntityDef.SelectEntities: failed to render where clause: %w", err)
-				}
-				builder.WriteString(clause)
-			}
-		}
-
-		generated := builder.String()
-		isdn, err := T.IsDeletedField.SqlColumnName()
+	filters = slices.DeleteFunc(slices.Clone(filters), func(f *Filter) bool { return f == nil })
+	result, err = T.fetchEntities(ctx, partial, filters, sorts, pageNo, pageSize)
+	if err != nil {
+		return result, 0, fmt.Errorf("EntityDef.SelectEntities: %w", err)
+	}
+	if pageNo > 0 && pageSize > 0 {
+		total, err := T.countEntities(ctx, filters)
 		if err != nil {
-			return "", fmt.Errorf("EntityDef.SelectEntities: failed to get IsDeleted column name: %w", err)
-		}
-		if T.UseSoftDelete && strings.LastIndex(generated, isdn) < strings.LastIndex(generated, "from") { // we have no "isdeleted" filter after "from"
-			if len(filters) > 0 {
-				builder.WriteString(" and ")
-			} else {
-				builder.WriteString(" where ")
-			}
-			flt := AddFilterEQ(T.IsDeletedField, false)
-			clause, err := flt.renderWhereClause(T.Factory)
-			if err != nil {
-				return "", fmt.Errorf("EntityDef.SelectEntities: failed to render soft delete where clause: %w", err)
-			}
-			builder.WriteString(clause)
-		}
-		if len(sorts) > 0 && !totals {
-			builder.WriteString(" order by ")
-			sortClauses := make([]string, 0, len(sorts))
-			for _, s := range sorts {
-				if s.Field == nil {
-					continue
-				}
-				coln, err := s.Field.SqlColumnName()
-				if err != nil {
-					return "", fmt.Errorf("EntityDef.SelectEntities: failed to get SQL column name for sort field: %w", err)
-				}
-				order := "ASC"
-				if !s.Asc {
-					order = "DESC"
-				}
-				sortClauses = append(sortClauses, fmt.Sprintf("%s %s", coln, order))
-			}
-			builder.WriteString(strings.Join(sortClauses, ", "))
-			if pageNo > 0 && pageSize > 0 {
-				switch T.Factory.DbDialect() {
-				case DbDialectSQLite, DbDialectPostgres:
-					builder.WriteString(fmt.Sprintf(" limit %d offset %d", pageSize, (pageNo-1)*pageSize))
-				case DbDialectMySQL:
-					builder.WriteString(fmt.Sprintf(" limit %d, %d", (pageNo-1)*pageSize, pageSize))
-				case DbDialectMSSQL:
-					builder.WriteString(fmt.Sprintf(" offset %d rows fetch next %d rows only", (pageNo-1)*pageSize, pageSize))
-				}
-			}
+			return result, 0, fmt.Errorf("EntityDef.SelectEntities: %w", err)
 		}
-		return builder.String(), nil
+		pagesCount = (total + pageSize - 1) / pageSize // calculate total pages
 	}
+	return result, pagesCount, nil
+}
 
-	query, err := getSql(false)
+// fetchEntities runs select query and loads table parts of selected entities. Partial entities aren't cached.
+func (T *EntityDef) fetchEntities(ctx context.Context, partial []*FieldDef, filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) ([]*Entity, error) {
+	loadFields := T.FieldDefs
+	if partial != nil {
+		loadFields = partial
+	}
+	result := make([]*Entity, 0)
+	query, args, err := T.selectSql(loadFields, filters, sorts, pageNo, pageSize, false)
 	if err != nil {
-		return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: failed to get SQL query: %w", err)
+		return result, fmt.Errorf("EntityDef.fetchEntities: failed to get SQL query: %w", err)
 	}
 
-	rows, err := T.Factory.Query(query)
+	rows, err := T.Factory.QueryContext(ctx, query, args...)
 	if err != nil {
-		return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: failed to execute query '%s': %w", query, err)
+		return result, fmt.Errorf("EntityDef.fetchEntities: failed to execute query '%s': %w", query, err)
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
 
-	fp := make([]any, len(T.FieldDefs))
+	withoutTableParts := make([]*Entity, 0)
 	for rows.Next() {
-
-		res, err := T.Factory.CreateEntity(T)
+		res, err := T.scanEntity(rows, loadFields)
 		if err != nil {
-			return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: failed to create entity: %w", err)
+			return result, fmt.Errorf("EntityDef.fetchEntities: %w", err)
 		}
-		for i, v := range T.FieldDefs {
-			fp[i] = res.Values[v.Name].(any)
-		}
-
-		err = rows.Scan(fp...)
-		if err != nil {
-			return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: failed to scan row: %w", err)
-		}
-		res.isNew = false
-
-		cached, ok := T.Factory.loadedEntities.Get(res.RefString())
-		if ok {
-			if cached.dataVersion.v == res.dataVersion.v {
-				res = cached
-			} else {
-				T.Factory.loadedEntities.Remove(res.RefString())
+		if partial != nil {
+			res.partial = make(map[*FieldDef]bool, len(partial))
+			for _, fd := range partial {
+				res.partial[fd] = true
 			}
+			result = append(result, res)
+			continue
 		}
 
-		T.Factory.loadedEntities.Add(res.RefString(), res)
+		res, fresh := T.cacheSelected(res)
+		if len(T.TablePartDefs) > 0 && (fresh || !res.tablePartsLoaded()) {
+			withoutTableParts = append(withoutTableParts, res)
+		}
 		result = append(result, res)
 	}
+	if err = rows.Err(); err != nil {
+		return result, fmt.Errorf("EntityDef.fetchEntities: rows error: %w", err)
+	}
+	_ = rows.Close()
+	if err = T.loadTableParts(ctx, withoutTableParts); err != nil {
+		return result, fmt.Errorf("EntityDef.fetchEntities: %w", err)
+	}
+	return result, nil
+}
 
-	if pageNo > 0 && pageSize > 0 {
-		countQuery, err := getSql(true)
-		if err != nil {
-			return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: failed to get count SQL query: %w", err)
-		}
-		countRows, err := T.Factory.Query(countQuery)
-		if err != nil {
-			return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: failed to execute count query '%s': %w", countQuery, err)
-		}
-		defer func() {
-			_ = countRows.Close()
-		}()
-		if countRows.Next() {
-			err = countRows.Scan(&pagesCount)
-			if err != nil {
-				return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: failed to scan count row: %w", err)
-			}
-			pagesCount = (pagesCount + pageSize - 1) / pageSize // calculate total pages
+// countEntities returns number of entities matching filters
+func (T *EntityDef) countEntities(ctx context.Context, filters []*Filter) (int, error) {
+	countQuery, countArgs, err := T.selectSql(nil, filters, nil, 0, 0, true)
+	if err != nil {
+		return 0, fmt.Errorf("EntityDef.countEntities: failed to get count SQL query: %w", err)
+	}
+	countRows, err := T.Factory.QueryContext(ctx, countQuery, countArgs...)
+	if err != nil {
+		return 0, fmt.Errorf("EntityDef.countEntities: failed to execute count query '%s': %w", countQuery, err)
+	}
+	defer func() {
+		_ = countRows.Close()
+	}()
+	if !countRows.Next() {
+		return 0, fmt.Errorf("EntityDef.countEntities: no rows returned for count query '%s'", countQuery)
+	}
+	total := 0
+	if err = countRows.Scan(&total); err != nil {
+		return 0, fmt.Errorf("EntityDef.countEntities: failed to scan count row: %w", err)
+	}
+	return total, nil
+}
+
+// scanEntity creates entity from current row of select query
+func (T *EntityDef) scanEntity(rows *sql.Rows, loadFields []*FieldDef) (*Entity, error) {
+	res, err := T.Factory.CreateEntity(T)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.scanEntity: failed to create entity: %w", err)
+	}
+	fp := make([]any, len(loadFields))
+	for i, v := range loadFields {
+		fp[i] = res.Values[v.Name].(any)
+	}
+	if err = rows.Scan(fp...); err != nil {
+		return nil, fmt.Errorf("EntityDef.scanEntity: failed to scan row: %w", err)
+	}
+	res.isNew = false
+	for _, tp := range res.tableParts {
+		tp.loaded = false // rows are loaded by caller or on first access
+	}
+	return res, nil
+}
+
+// cacheSelected returns cached entity when it has the same data version as selected one (fresh is false then),
+// otherwise selected entity is put into cache
+func (T *EntityDef) cacheSelected(res *Entity) (*Entity, bool) {
+	fresh := true
+	cached, ok := T.Factory.loadedEntities.Get(res.RefString())
+	if ok {
+		if cached.dataVersion.v == res.dataVersion.v {
+			res = cached
+			fresh = false
 		} else {
-			return result, pagesCount, fmt.Errorf("EntityDef.SelectEntities: no rows returned for count query '%s'", countQuery)
+			T.Factory.loadedEntities.Remove(res.RefString())
 		}
 	}
-
-	return result, pagesCount, nil
+	T.Factory.loadedEntities.Add(res.RefString(), res)
+	return res, fresh
 }
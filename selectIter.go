@@ -0,0 +1,60 @@
+package elorm
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+)
+
+// SelectEntitiesIter is like SelectEntitiesContext, but it returns entities one by one while reading query rows,
+// so large results aren't collected in memory:
+//
+//	for good, err := range DB.GoodDef.SelectEntitiesIter(ctx, filters, nil, true) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// When bypassCache is true, entities are neither taken from nor put into entity cache. Table parts of entities
+// are loaded on first access. Query rows are closed when iteration completes or the loop is stopped. Database
+// connection is busy during iteration, so don't query database inside the loop with the same transaction context.
+func (T *EntityDef) SelectEntitiesIter(ctx context.Context, filters []*Filter, sorts []*SortItem, bypassCache bool) iter.Seq2[*Entity, error] {
+	return func(yield func(*Entity, error) bool) {
+		filters := slices.DeleteFunc(slices.Clone(filters), func(f *Filter) bool { return f == nil })
+		if sorts == nil {
+			// sort by ref by default
+			sorts = []*SortItem{{Field: T.RefField, Asc: true}}
+		}
+		query, args, err := T.selectSql(T.FieldDefs, filters, sorts, 0, 0, false)
+		if err != nil {
+			yield(nil, fmt.Errorf("EntityDef.SelectEntitiesIter: failed to get SQL query: %w", err))
+			return
+		}
+		rows, err := T.Factory.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(nil, fmt.Errorf("EntityDef.SelectEntitiesIter: failed to execute query '%s': %w", query, err))
+			return
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		for rows.Next() {
+			res, err := T.scanEntity(rows, T.FieldDefs)
+			if err != nil {
+				yield(nil, fmt.Errorf("EntityDef.SelectEntitiesIter: %w", err))
+				return
+			}
+			if !bypassCache {
+				res, _ = T.cacheSelected(res)
+			}
+			if !yield(res, nil) {
+				return
+			}
+		}
+		if err = rows.Err(); err != nil {
+			yield(nil, fmt.Errorf("EntityDef.SelectEntitiesIter: rows error: %w", err))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package elorm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntityDef_SelectEntitiesIter(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "iter.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	goodDef, _ := factory.CreateEntityDef("ItGood", "ItGoods")
+	qty, _ := goodDef.AddIntFieldDef("Qty")
+	lines, _ := goodDef.AddTablePartDef("Lines", "ItGoodLines")
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	for i := range 10 {
+		e, _ := factory.CreateEntity(goodDef)
+		e.Values["Qty"].(*FieldValueInt).Set(int64(i))
+		_, _ = e.TablePart(lines.Name).AddRow()
+		if err = e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	factory.loadedEntities.Purge()
+
+	sorts := []*SortItem{{Field: qty, Asc: false}}
+	var got []int64
+	for e, err := range goodDef.SelectEntitiesIter(ctx, []*Filter{AddFilterGE(qty, int64(5))}, sorts, true) {
+		if err != nil {
+			t.Fatalf("SelectEntitiesIter() error = %v", err)
+		}
+		got = append(got, e.Values["Qty"].(*FieldValueInt).Get())
+		if factory.loadedEntities.Contains(e.RefString()) {
+			t.Errorf("SelectEntitiesIter() with bypassCache put entity into cache")
+		}
+		rows, err := e.TablePart(lines.Name).Rows()
+		if err != nil || len(rows) != 1 {
+			t.Errorf("table part rows = %d, %v", len(rows), err)
+		}
+	}
+	if len(got) != 5 || got[0] != 9 || got[4] != 5 {
+		t.Errorf("SelectEntitiesIter() = %v", got)
+	}
+
+	// stop early
+	count := 0
+	for e, err := range goodDef.SelectEntitiesIter(ctx, nil, nil, false) {
+		if err != nil {
+			t.Fatalf("SelectEntitiesIter() error = %v", err)
+		}
+		if !factory.loadedEntities.Contains(e.RefString()) {
+			t.Errorf("SelectEntitiesIter() didn't put entity into cache")
+		}
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("iterations = %d, want 3", count)
+	}
+	if inUse := factory.db.Stats().InUse; inUse != 0 {
+		t.Errorf("connections in use after break = %d, rows are not closed", inUse)
+	}
+
+	// errors are yielded
+	otherDef, _ := factory.CreateEntityDef("ItOther", "ItOthers")
+	otherField, _ := otherDef.AddIntFieldDef("Qty")
+	failed := false
+	for _, err := range goodDef.SelectEntitiesIter(ctx, []*Filter{AddFilterEQ(otherField, int64(1)).Through(qty)}, nil, true) {
+		failed = err != nil
+	}
+	if !failed {
+		t.Errorf("SelectEntitiesIter() with invalid path should yield error")
+	}
+}
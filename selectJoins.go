@@ -0,0 +1,117 @@
+package elorm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Alias of queried table when SelectEntities joins tables by ref paths. Joined tables are aliased by
+// this alias and column names of ref fields of the path, e.g. "t_ownershop_city".
+const selectMainAlias = "t"
+
+// Through sets path of ref fields from queried entity to filter field, e.g. filter
+// AddFilterLIKE(ShopDef.Caption, "A%").Through(GoodDef.OwnerShop) selects goods whose shop caption starts with "A".
+func (T *Filter) Through(path ...*FieldDef) *Filter {
+	if T == nil {
+		return nil
+	}
+	T.Path = path
+	return T
+}
+
+// sqlJoins collects LEFT JOINs required by ref paths of filters and sorts. Nil sqlJoins renders plain column names.
+type sqlJoins struct {
+	def     *EntityDef
+	aliases []string
+	clauses []string
+}
+
+// newSqlJoins returns nil when filters and sorts have no paths, so query is rendered without aliases
+func newSqlJoins(def *EntityDef, filters []*Filter, sorts []*SortItem) *sqlJoins {
+	var hasPath func(filters []*Filter) bool
+	hasPath = func(filters []*Filter) bool {
+		for _, f := range filters {
+			if f != nil && (len(f.Path) > 0 || hasPath(f.Childs)) {
+				return true
+			}
+		}
+		return false
+	}
+	found := hasPath(filters)
+	for _, s := range sorts {
+		found = found || (s != nil && len(s.Path) > 0)
+	}
+	if !found {
+		return nil
+	}
+	return &sqlJoins{def: def}
+}
+
+// column returns column name of field reached by path, qualified by alias of joined table. Joins are added on first use.
+func (T *sqlJoins) column(path []*FieldDef, fd *FieldDef) (string, error) {
+	if fd == nil {
+		return "", fmt.Errorf("sqlJoins.column: field is nil")
+	}
+	if T == nil {
+		if len(path) > 0 {
+			return "", fmt.Errorf("sqlJoins.column: path to %s isn't supported here", fd.Name)
+		}
+		return fd.SqlColumnName()
+	}
+	alias := selectMainAlias
+	def := T.def
+	for _, p := range path {
+		if p == nil || p.Type != FieldDefTypeRef || !slices.Contains(def.FieldDefs, p) {
+			return "", fmt.Errorf("sqlJoins.column: path to %s has field which is not ref field of %s", fd.Name, def.ObjectName)
+		}
+		colname, err := p.SqlColumnName()
+		if err != nil {
+			return "", fmt.Errorf("sqlJoins.column: %w", err)
+		}
+		joined := alias + "_" + colname
+		if !slices.Contains(T.aliases, joined) {
+			tablename, err := p.EntityDef.SqlTableName()
+			if err != nil {
+				return "", fmt.Errorf("sqlJoins.column: %w", err)
+			}
+			refColumn, err := p.EntityDef.RefField.SqlColumnName()
+			if err != nil {
+				return "", fmt.Errorf("sqlJoins.column: %w", err)
+			}
+			T.aliases = append(T.aliases, joined)
+			T.clauses = append(T.clauses, fmt.Sprintf(" left join %s %s on %s.%s = %s.%s", tablename, joined, joined, refColumn, alias, colname))
+		}
+		alias = joined
+		def = p.EntityDef
+	}
+	if len(path) > 0 && !slices.Contains(def.FieldDefs, fd) {
+		return "", fmt.Errorf("sqlJoins.column: field %s is not field of %s", fd.Name, def.ObjectName)
+	}
+	colname, err := fd.SqlColumnName()
+	if err != nil {
+		return "", fmt.Errorf("sqlJoins.column: %w", err)
+	}
+	return alias + "." + colname, nil
+}
+
+// from renders table of entity definition with joins
+func (T *sqlJoins) from(tablename string) string {
+	if T == nil {
+		return tablename
+	}
+	return tablename + " " + selectMainAlias + strings.Join(T.clauses, "")
+}
+
+// hasFilterOn checks if filters (including groups) have condition on field of queried entity
+func hasFilterOn(filters []*Filter, fd *FieldDef) bool {
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		if (f.LeftOp == fd && len(f.Path) == 0) || hasFilterOn(f.Childs, fd) {
+			return true
+		}
+	}
+	return false
+}
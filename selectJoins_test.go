@@ -0,0 +1,126 @@
+package elorm
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEntityDef_SelectEntitiesThroughRefs(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "joins.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	cityDef, _ := factory.CreateEntityDef("JnCity", "JnCities")
+	cityName, _ := cityDef.AddStringFieldDef("Caption", 50)
+	shopDef, _ := factory.CreateEntityDef("JnShop", "JnShops")
+	shopCaption, _ := shopDef.AddStringFieldDef("Caption", 50)
+	shopCity, _ := shopDef.AddRefFieldDef("City", cityDef)
+	goodDef, _ := factory.CreateEntityDef("JnGood", "JnGoods")
+	goodDef.UseSoftDelete = true
+	goodCaption, _ := goodDef.AddStringFieldDef("Caption", 50)
+	goodShop, _ := goodDef.AddRefFieldDef("OwnerShop", shopDef)
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	create := func(def *EntityDef, caption string, refField *FieldDef, ref *Entity) *Entity {
+		e, _ := factory.CreateEntity(def)
+		e.Values["Caption"].(*FieldValueString).Set(caption)
+		if refField != nil && ref != nil {
+			_ = e.Values[refField.Name].(*FieldValueRef).Set(ref)
+		}
+		if err := e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return e
+	}
+	paris := create(cityDef, "Paris", nil, nil)
+	rome := create(cityDef, "Rome", nil, nil)
+	alpha := create(shopDef, "Alpha", shopCity, paris)
+	beta := create(shopDef, "Beta", shopCity, rome)
+	create(goodDef, "g1", goodShop, beta)
+	create(goodDef, "g2", goodShop, alpha)
+	create(goodDef, "g3", goodShop, alpha)
+	create(goodDef, "g4", nil, nil)
+	deleted := create(goodDef, "g5", goodShop, alpha)
+	deleted.SetIsDeleted(true)
+	if err = deleted.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	captions := func(list []*Entity) string {
+		res := make([]string, 0, len(list))
+		for _, e := range list {
+			res = append(res, e.Values["Caption"].(*FieldValueString).Get())
+		}
+		return strings.Join(res, ",")
+	}
+
+	tests := []struct {
+		name    string
+		filters []*Filter
+		sorts   []*SortItem
+		want    string
+	}{
+		{
+			name:    "filter by shop caption",
+			filters: []*Filter{AddFilterLIKE(shopCaption, "A%").Through(goodShop)},
+			sorts:   []*SortItem{{Field: goodCaption, Asc: true}},
+			want:    "g2,g3",
+		},
+		{
+			name:    "filter by shop city caption",
+			filters: []*Filter{AddFilterEQ(cityName, "Rome").Through(goodShop, shopCity)},
+			want:    "g1",
+		},
+		{
+			name: "same path in group and sort",
+			filters: []*Filter{AddOrGroup(
+				AddFilterEQ(cityName, "Paris").Through(goodShop, shopCity),
+				AddFilterEQ(shopCaption, "Beta").Through(goodShop))},
+			sorts: []*SortItem{{Field: cityName, Path: []*FieldDef{goodShop, shopCity}, Asc: false}, {Field: goodCaption, Asc: true}},
+			want:  "g1,g2,g3",
+		},
+		{
+			name:  "sort by shop caption",
+			sorts: []*SortItem{{Field: shopCaption, Path: []*FieldDef{goodShop}, Asc: false}, {Field: goodCaption, Asc: true}},
+			want:  "g1,g2,g3,g4",
+		},
+		{
+			name:    "deleted goods are visible with isdeleted filter",
+			filters: []*Filter{AddFilterEQ(goodDef.IsDeletedField, true)},
+			want:    "g5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := goodDef.SelectEntities(tt.filters, tt.sorts, 0, 0)
+			if err != nil {
+				t.Fatalf("SelectEntities() error = %v", err)
+			}
+			if captions(got) != tt.want {
+				t.Errorf("SelectEntities() = %s, want %s", captions(got), tt.want)
+			}
+		})
+	}
+
+	page, pages, err := goodDef.SelectEntities(
+		[]*Filter{AddFilterIsNOTNULL(shopCaption).Through(goodShop)},
+		[]*SortItem{{Field: shopCaption, Path: []*FieldDef{goodShop}, Asc: true}, {Field: goodCaption, Asc: true}}, 2, 2)
+	if err != nil {
+		t.Fatalf("SelectEntities() with paging error = %v", err)
+	}
+	if captions(page) != "g1" || pages != 2 {
+		t.Errorf("SelectEntities() page 2 = %s of %d pages, want g1 of 2", captions(page), pages)
+	}
+
+	if _, _, err = goodDef.SelectEntities([]*Filter{AddFilterEQ(cityName, "Rome").Through(shopCity)}, nil, 0, 0); err == nil {
+		t.Errorf("SelectEntities() with path through foreign field should fail")
+	}
+	if _, _, err = goodDef.SelectEntities([]*Filter{AddFilterEQ(goodCaption, "g1").Through(goodShop)}, nil, 0, 0); err == nil {
+		t.Errorf("SelectEntities() with field not belonging to path end should fail")
+	}
+}
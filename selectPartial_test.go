@@ -0,0 +1,120 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntityDef_SelectPartial(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "partial.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	goodDef, _ := factory.CreateEntityDef("PtGood", "PtGoods")
+	caption, _ := goodDef.AddStringFieldDef("Caption", 50)
+	price, _ := goodDef.AddNumericFieldDef("Price", 10, 2)
+	_, _ = goodDef.AddStringFieldDef("Description", 4096)
+	otherDef, _ := factory.CreateEntityDef("PtOther", "PtOthers")
+	otherCaption, _ := otherDef.AddStringFieldDef("Caption", 50)
+	otherDef.UseSoftDelete = true
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	for i, c := range []string{"pear", "apple"} {
+		e, _ := factory.CreateEntity(goodDef)
+		e.Values["Caption"].(*FieldValueString).Set(c)
+		e.Values["Price"].(*FieldValueNumeric).Set(float64(i + 1))
+		e.Values["Description"].(*FieldValueString).Set("long text")
+		if err = e.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	factory.loadedEntities.Purge()
+
+	list, pages, err := goodDef.SelectPartial([]*FieldDef{caption, price}, nil, []*SortItem{{Field: caption, Asc: true}}, 1, 10)
+	if err != nil {
+		t.Fatalf("SelectPartial() error = %v", err)
+	}
+	if len(list) != 2 || pages != 1 {
+		t.Fatalf("SelectPartial() returned %d entities of %d pages", len(list), pages)
+	}
+	e := list[0]
+	if !e.IsPartial() || e.IsNew() || e.RefString() == "" {
+		t.Errorf("SelectPartial() entity partial=%v new=%v ref=%s", e.IsPartial(), e.IsNew(), e.RefString())
+	}
+	if e.Values["Caption"].(*FieldValueString).Get() != "apple" || e.Values["Price"].(*FieldValueNumeric).Get() != 2 {
+		t.Errorf("SelectPartial() loaded values = %s, %v", e.Values["Caption"].AsString(), e.Values["Price"].AsString())
+	}
+	if e.Values["Description"].(*FieldValueString).Get() != "" {
+		t.Errorf("SelectPartial() loaded not requested field")
+	}
+	if factory.loadedEntities.Contains(e.RefString()) {
+		t.Errorf("SelectPartial() entity is cached")
+	}
+	if err = e.Save(ctx); err == nil {
+		t.Errorf("Save() of partial entity should fail")
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var parsed map[string]any
+	_ = json.Unmarshal(data, &parsed)
+	if len(parsed) != 3 || parsed["Caption"] != "apple" || parsed[RefFieldName] != e.RefString() {
+		t.Errorf("MarshalJSON() of partial entity = %s", data)
+	}
+
+	// full entity isn't affected by partial one
+	full, err := factory.LoadEntity(e.RefString())
+	if err != nil {
+		t.Fatalf("LoadEntity() error = %v", err)
+	}
+	if full.IsPartial() || full.Values["Description"].(*FieldValueString).Get() != "long text" {
+		t.Errorf("LoadEntity() after SelectPartial returned partial entity")
+	}
+	e.Values["Caption"].(*FieldValueString).Set("green apple")
+	if err = full.LoadFrom(e, false); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+	if full.Values["Caption"].(*FieldValueString).Get() != "green apple" || full.Values["Description"].(*FieldValueString).Get() != "long text" {
+		t.Errorf("LoadFrom() partial entity should copy only loaded fields")
+	}
+
+	if _, _, err = goodDef.SelectPartial([]*FieldDef{otherCaption}, nil, nil, 0, 0); err == nil {
+		t.Errorf("SelectPartial() with field of other entity should fail")
+	}
+
+	// LoadEntityPartial doesn't use cached full entity
+	full.Values["Description"].(*FieldValueString).Set("changed")
+	loaded, err := factory.LoadEntityPartial(full.RefString(), caption)
+	if err != nil {
+		t.Fatalf("LoadEntityPartial() error = %v", err)
+	}
+	if !loaded.IsPartial() || loaded == full || loaded.Values["Caption"].AsString() != "apple" || loaded.Values["Description"].AsString() != "" {
+		t.Errorf("LoadEntityPartial() = partial %v, Caption %s, Description %s", loaded.IsPartial(), loaded.Values["Caption"].AsString(), loaded.Values["Description"].AsString())
+	}
+	if cached, _ := factory.loadedEntities.Peek(full.RefString()); cached != full {
+		t.Errorf("LoadEntityPartial() replaced cached entity")
+	}
+	if _, err = factory.LoadEntityPartial(full.RefString(), otherCaption); err == nil {
+		t.Errorf("LoadEntityPartial() with field of other entity should fail")
+	}
+	unsaved, _ := factory.CreateEntity(goodDef)
+	if _, err = factory.LoadEntityPartial(unsaved.RefString(), caption); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadEntityPartial() of missing entity error = %v, want %v", err, ErrNotFound)
+	}
+	other, _ := factory.CreateEntity(otherDef)
+	other.SetIsDeleted(true)
+	if err = other.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err = factory.LoadEntityPartial(other.RefString(), otherCaption); err != nil {
+		t.Errorf("LoadEntityPartial() of soft deleted entity error = %v", err)
+	}
+}
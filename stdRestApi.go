@@ -3,16 +3,39 @@ package elorm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
+// problemDetails is error response body (RFC 7807)
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
 func sendHttpError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/problem+json")
 	w.WriteHeader(statusCode)
-	_, _ = w.Write([]byte(html.EscapeString(message)))
+	_ = json.NewEncoder(w).Encode(problemDetails{Type: "about:blank", Title: http.StatusText(statusCode), Status: statusCode, Detail: message})
+}
+
+// errorStatus returns HTTP status code for err wrapping elorm errors (ErrValidation, ErrNotFound...) or defaultStatus
+func errorStatus(err error, defaultStatus int) int {
+	switch {
+	case errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrConcurrentUpdate), errors.Is(err, ErrReferenced):
+		return http.StatusConflict
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrInvalidRef):
+		return http.StatusNotFound
+	}
+	return defaultStatus
 }
 
 // RestApiConfig is a configuration for standard REST API operations.
@@ -27,6 +50,10 @@ type RestApiConfig[T IEntity] struct {
 	// Typically, it's SelectEntities from generated code, e.g. DB.ShopDef.SelectEntities
 	SelectEntitiesFunc func(filters []*Filter, sorts []*SortItem, pageNo int, pageSize int) (result []T, pagesCount int, err error)
 
+	// Typically, it's SelectEntitiesPage of entity definition, e.g. DB.ShopDef.SelectEntitiesPage. Cursor pagination
+	// works only when it is set, it should restrict rows like SelectEntitiesFunc does (e.g. by tenant)
+	SelectEntitiesPageFunc func(ctx context.Context, filters []*Filter, sorts []*SortItem, cursor string, pageSize int, count bool) (*CursorPage, error)
+
 	// Typically, it's CreateXXX from generated code, e.g. DB.CreateShop
 	CreateEntityFunc func() (T, error)
 
@@ -54,6 +81,25 @@ type RestApiConfig[T IEntity] struct {
 	// Enable DELETE request to remove an entity
 	EnableDelete bool
 
+	// Enable PATCH request to change some fields of an existing entity (JSON merge patch, see Entity.ApplyMergePatch).
+	// It is false by default, so configs which disable PUT to make endpoint read-only don't accept PATCH writes
+	EnablePatch bool
+
+	// Enable batch requests: POST with ParamBatch query parameter and array of RestApiBatchItem in body. Operations
+	// are executed in one transaction and response is array of RestApiBatchResult
+	EnableBatch bool
+
+	// Batch is all-or-nothing: the first failed operation rolls back the whole batch. Otherwise failed operations
+	// are rolled back alone and other ones are committed
+	BatchAllOrNothing bool
+
+	// Maximum number of operations in batch request, 0 means no limit
+	MaxBatchSize int
+
+	// Reject PUT, PATCH and DELETE requests without If-Match header (428 Precondition Required). If-Match is checked
+	// against ETag of entity (see EntityETag) when present anyway
+	RequireIfMatch bool
+
 	// Query parameter name for entity reference, "ref" by default
 	ParamRef string
 
@@ -66,6 +112,38 @@ type RestApiConfig[T IEntity] struct {
 	// Query parameter name for sorting, "sortby" by default
 	ParamSortBy string
 
+	// Use cursor (keyset) pagination for lists even when request has no cursor parameter, see SelectEntitiesPageFunc
+	CursorPaging bool
+
+	// Query parameter name for page cursor, "cursor" by default. Request with this parameter uses cursor pagination
+	ParamCursor string
+
+	// Query parameter name to request total count with cursor pagination ("count=true"), "count" by default
+	ParamCount string
+
+	// Query parameter name for filter expression (see EntityDef.ParseFilter), "filter" by default
+	ParamFilter string
+
+	// Query parameter name for batch requests (see EnableBatch), "batch" by default
+	ParamBatch string
+
+	// Query parameter name for list of fields in GET responses (e.g. "fields=Caption,Price"), "fields" by default
+	ParamFields string
+
+	// Query parameter name for refs expanded into nested objects in GET responses (e.g. "expand=OwnerShop(Caption),CreatedBy"),
+	// "expand" by default
+	ParamExpand string
+
+	// Paths of fields of referenced entities allowed in expand parameter, e.g. "OwnerShop.Caption", "OwnerShop.City.Name"
+	// or "OwnerShop.*" for all fields of OwnerShop. Nil disables expand parameter
+	ExpandFields []string
+
+	// Maximum nesting of refs in expand parameter, DefaultMaxExpandDepth by default
+	MaxExpandDepth int
+
+	// Names of fields and ref paths (e.g. "OwnerShop.Caption") allowed in filter expression, nil allows all fields without paths
+	FilterFields []string
+
 	// Middleware function to execute before processing the request, returns true to continue or false to stop
 	BeforeMiddleware func(http.ResponseWriter, *http.Request) bool
 
@@ -82,6 +160,9 @@ type RestApiConfig[T IEntity] struct {
 // DefaultPageSize is the default number of items per page in REST API responses.
 const DefaultPageSize = 20
 
+// DefaultMaxBatchSize is the default maximum number of operations in REST API batch request.
+const DefaultMaxBatchSize = 100
+
 // CreateStdRestApiConfig creates a new RestApiConfig for standard REST API operations.
 func CreateStdRestApiConfig[T IEntity](
 	def *EntityDef,
@@ -103,10 +184,20 @@ func CreateStdRestApiConfig[T IEntity](
 		EnablePut:     true,
 		EnableDelete:  true,
 
+		BatchAllOrNothing: true,
+		MaxBatchSize:      DefaultMaxBatchSize,
+		MaxExpandDepth:    DefaultMaxExpandDepth,
+
 		ParamRef:      "ref",
 		ParamPageNo:   "pageno",
 		ParamPageSize: "pagesize",
 		ParamSortBy:   "sortby",
+		ParamCursor:   "cursor",
+		ParamCount:    "count",
+		ParamFilter:   "filter",
+		ParamBatch:    "batch",
+		ParamFields:   "fields",
+		ParamExpand:   "expand",
 	}
 }
 
@@ -145,6 +236,10 @@ func HandleRestApi[T IEntity](config RestApiConfig[T]) func(w http.ResponseWrite
 				return
 			}
 		case http.MethodPost:
+			if config.EnableBatch && r.URL.Query().Has(config.ParamBatch) {
+				responseBatch(config, r, w)
+				return
+			}
 			if config.EnablePost {
 				responsePost(config, w, r)
 			} else {
@@ -161,6 +256,16 @@ func HandleRestApi[T IEntity](config RestApiConfig[T]) func(w http.ResponseWrite
 			} else {
 				sendHttpError(w, "", http.StatusNotFound)
 			}
+		case http.MethodPatch:
+			if config.EnablePatch {
+				if r.URL.Query().Has(config.ParamRef) {
+					responsePatch(config, r, w)
+				} else {
+					sendHttpError(w, fmt.Sprintf("Missing '%s' parameter", config.ParamRef), http.StatusBadRequest)
+				}
+			} else {
+				sendHttpError(w, "", http.StatusNotFound)
+			}
 		case http.MethodDelete:
 			if config.EnableDelete {
 				responseDelete(config, w, r)
@@ -183,35 +288,105 @@ func responsePut[T IEntity](config RestApiConfig[T], r *http.Request, w http.Res
 
 	dbRecord, err := config.LoadEntityFunc(ref)
 	if err != nil {
-		sendHttpError(w, fmt.Sprintf("%sfailed to load entity: %v", methodPrefix, err), http.StatusInternalServerError)
+		sendHttpError(w, fmt.Sprintf("%sfailed to load entity: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if !checkIfMatch(config, r, w, dbRecord) {
 		return
 	}
 
+	ctx := r.Context()
+	if config.Context != nil {
+		ctx = config.Context(r)
+	}
+
 	reqRecord, err := config.CreateEntityFunc()
 	if err != nil {
 		sendHttpError(w, fmt.Sprintf("%sfailed to create entity: %v", methodPrefix, err), http.StatusInternalServerError)
 		return
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&reqRecord)
+	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		sendHttpError(w, fmt.Sprintf("%sinvalid request data: %v", methodPrefix, err), http.StatusBadRequest)
+		sendHttpError(w, fmt.Sprintf("%sfailed to read request: %v", methodPrefix, err), http.StatusBadRequest)
+		return
+	}
+	err = decodeEntity(ctx, data, &reqRecord)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sinvalid request data: %v", methodPrefix, err), errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
-	err = dbRecord.LoadFrom(reqRecord, false)
+	err = loadEntityFrom(ctx, dbRecord, reqRecord)
 	if err != nil {
 		sendHttpError(w, fmt.Sprintf("%sfailed to load data into entity: %v", methodPrefix, err), http.StatusInternalServerError)
 		return
 	}
 
+	err = dbRecord.Save(ctx)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to save entity: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.Header().Set("ETag", EntityETag(dbRecord))
+}
+
+func responsePatch[T IEntity](config RestApiConfig[T], r *http.Request, w http.ResponseWriter) {
+	const methodPrefix = "RestApiConfig.responsePatch: "
+	ref := r.URL.Query().Get(config.ParamRef)
+	if ref == "" {
+		sendHttpError(w, fmt.Sprintf("%smissing ref parameter", methodPrefix), http.StatusBadRequest)
+		return
+	}
+
+	dbRecord, err := config.LoadEntityFunc(ref)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to load entity: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if !checkIfMatch(config, r, w, dbRecord) {
+		return
+	}
+	entity, ok := any(dbRecord).(interface{ baseEntity() *Entity })
+	if !ok {
+		sendHttpError(w, fmt.Sprintf("%sentity doesn't support merge patch", methodPrefix), http.StatusInternalServerError)
+		return
+	}
+
 	ctx := r.Context()
 	if config.Context != nil {
 		ctx = config.Context(r)
 	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to read request: %v", methodPrefix, err), http.StatusBadRequest)
+		return
+	}
+	// cached entity is changed in place, rejected values shouldn't stay in cache
+	forget := func() { config.Def.Factory.loadedEntities.Remove(dbRecord.RefString()) }
+	err = entity.baseEntity().ApplyMergePatchContext(ctx, patch)
+	if err != nil {
+		forget()
+		sendHttpError(w, fmt.Sprintf("%sinvalid request data: %v", methodPrefix, err), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
 	err = dbRecord.Save(ctx)
 	if err != nil {
-		sendHttpError(w, fmt.Sprintf("%sfailed to save entity: %v", methodPrefix, err), http.StatusInternalServerError)
+		forget()
+		sendHttpError(w, fmt.Sprintf("%sfailed to save entity: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.Header().Set("ETag", EntityETag(dbRecord))
+	data, err := shapedEntity(ctx, nil, dbRecord)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to convert entity: %v", methodPrefix, err), http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(data)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to encode entity to JSON: %v", methodPrefix, err), http.StatusInternalServerError)
 		return
 	}
 }
@@ -226,28 +401,39 @@ func responsePost[T IEntity](config RestApiConfig[T], w http.ResponseWriter, r *
 		return
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&newRecord)
-	if err != nil {
-		sendHttpError(w, fmt.Sprintf("%sinvalid request data: %v", methodPrefix, err), http.StatusBadRequest)
-		return
-	}
-
 	ctx := r.Context()
 	if config.Context != nil {
 		ctx = config.Context(r)
 	}
 
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to read request: %v", methodPrefix, err), http.StatusBadRequest)
+		return
+	}
+	err = decodeEntity(ctx, data, &newRecord)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sinvalid request data: %v", methodPrefix, err), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
 	// override generated Ref after load from request body
 	if refFld.v == "" {
 		refFld.v = oldRef
 	}
 	err = newRecord.Save(ctx)
 	if err != nil {
-		sendHttpError(w, fmt.Sprintf("%sfailed to save entity: %v", methodPrefix, err), http.StatusInternalServerError)
+		sendHttpError(w, fmt.Sprintf("%sfailed to save entity: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.Header().Set("ETag", EntityETag(newRecord))
+	result, err := shapedEntity(ctx, nil, newRecord)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to convert entity: %v", methodPrefix, err), http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(newRecord)
+	err = json.NewEncoder(w).Encode(result)
 	if err != nil {
 		sendHttpError(w, fmt.Sprintf("%sfailed to encode entity to JSON: %v", methodPrefix, err), http.StatusInternalServerError)
 		return
@@ -264,34 +450,54 @@ func responseDelete[T IEntity](config RestApiConfig[T], w http.ResponseWriter, r
 			ctx = config.Context(r)
 		}
 
-		if config.Def.UseSoftDelete {
-			ent, err := config.Def.Factory.LoadEntity(ref)
+		var ent *Entity
+		if config.RequireIfMatch || r.Header.Get("If-Match") != "" {
+			var err error
+			ent, err = config.Def.Factory.LoadEntityContext(ctx, ref)
 			if err != nil {
-				sendHttpError(w, fmt.Sprintf("%sfailed to load entity: %v", methodPrefix, err), http.StatusNotFound)
+				sendHttpError(w, fmt.Sprintf("%sfailed to load entity: %v", methodPrefix, err), errorStatus(err, http.StatusNotFound))
 				return
 			}
-			if !ent.IsDeleted() {
-				ent.SetIsDeleted(true)
-				err = ent.Save(ctx)
-				if err != nil {
-					sendHttpError(w, fmt.Sprintf("%sfailed to soft delete entity: %v", methodPrefix, err), http.StatusInternalServerError)
-					return
-				}
-			}
-		} else {
-			err := config.Def.Factory.DeleteEntity(ctx, ref)
-			if err != nil {
-				sendHttpError(w, fmt.Sprintf("%sfailed to delete entity: %v", methodPrefix, err), http.StatusNotFound)
+			if !checkIfMatch(config, r, w, ent) {
 				return
 			}
 		}
 
+		// entities with UseSoftDelete are marked as deleted by DeleteEntity, after the same handlers and delete rules
+		err := config.Def.Factory.DeleteEntity(ctx, ref)
+		if err != nil {
+			sendHttpError(w, fmt.Sprintf("%sfailed to delete entity: %v", methodPrefix, err), errorStatus(err, http.StatusNotFound))
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 	} else {
 		sendHttpError(w, fmt.Sprintf("%smissing ref parameter", methodPrefix), http.StatusNotFound)
 	}
 }
 
+// decodeEntity reads record from JSON of request, with access to fields decided for ctx
+func decodeEntity[T IEntity](ctx context.Context, data []byte, record *T) error {
+	if base, ok := any(*record).(interface{ baseEntity() *Entity }); ok {
+		return base.baseEntity().UnmarshalJSONContext(ctx, data)
+	}
+	return json.Unmarshal(data, record)
+}
+
+// loadEntityFrom copies fields of request from src into dest like LoadFrom, with access to fields decided for ctx
+func loadEntityFrom[T IEntity](ctx context.Context, dest T, src T) error {
+	if base, ok := any(dest).(interface{ baseEntity() *Entity }); ok {
+		e := base.baseEntity()
+		return e.loadFrom(src, false, e.Factory.writableFields(ctx))
+	}
+	return dest.LoadFrom(src, false)
+}
+
+// requestShape returns shape of JSON output defined by fields and expand parameters of request, nil when there are none
+func requestShape[T IEntity](config RestApiConfig[T], r *http.Request) (*jsonShape, error) {
+	return jsonShapeOf(config.Def, r.URL.Query().Get(config.ParamFields), r.URL.Query().Get(config.ParamExpand), config.ExpandFields, config.MaxExpandDepth)
+}
+
 func responseGet[T IEntity](config RestApiConfig[T], r *http.Request, w http.ResponseWriter) {
 	const methodPrefix = "RestApiConfig.responseGet: "
 	ref := r.URL.Query().Get(config.ParamRef)
@@ -299,18 +505,52 @@ func responseGet[T IEntity](config RestApiConfig[T], r *http.Request, w http.Res
 		sendHttpError(w, fmt.Sprintf("%smissing ref parameter", methodPrefix), http.StatusBadRequest)
 		return
 	}
+	shape, err := requestShape(config, r)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sinvalid fields or expand: %v", methodPrefix, err), http.StatusBadRequest)
+		return
+	}
 
 	record, err := config.LoadEntityFunc(ref)
 	if err != nil {
-		sendHttpError(w, fmt.Sprintf("%sfailed to load entity: %v", methodPrefix, err), http.StatusInternalServerError)
+		sendHttpError(w, fmt.Sprintf("%sfailed to load entity: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(record)
+	// shaped representation depends on parameters, caller and referenced entities, not only on DataVersion of entity
+	shaped := shape != nil || hasFieldAccessPolicy(record)
+	if !shaped {
+		etag := EntityETag(record)
+		w.Header().Set("ETag", etag)
+		if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	if config.Context != nil {
+		ctx = config.Context(r)
+	}
+	data, err := shapedEntity(ctx, shape, record)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to expand entity: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	body, err := json.Marshal(data)
 	if err != nil {
 		sendHttpError(w, fmt.Sprintf("%sfailed to encode entity to JSON: %v", methodPrefix, err), http.StatusInternalServerError)
 		return
 	}
+	if shaped {
+		etag := representationETag(body)
+		w.Header().Set("ETag", etag)
+		if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	_, _ = w.Write(append(body, '\n'))
 }
 
 func responseGetList[T IEntity](config RestApiConfig[T], r *http.Request, w http.ResponseWriter) {
@@ -328,14 +568,21 @@ func responseGetList[T IEntity](config RestApiConfig[T], r *http.Request, w http
 		pageSize = config.DefaultPageSize
 	}
 
+	ctx := r.Context()
+	if config.Context != nil {
+		ctx = config.Context(r)
+	}
+	factory := config.Def.Factory
+
 	filters := make([]*Filter, 0)
 	if config.AutoFilters {
 		for k, v := range r.URL.Query() {
-			if k == config.ParamRef || k == config.ParamPageNo || k == config.ParamPageSize {
+			if k == config.ParamRef || k == config.ParamPageNo || k == config.ParamPageSize || k == config.ParamCursor || k == config.ParamCount || k == config.ParamFilter ||
+				k == config.ParamFields || k == config.ParamExpand {
 				continue
 			}
 			fd := config.Def.FieldDefByName(k)
-			if fd != nil {
+			if fd != nil && factory.canReadField(ctx, fd) {
 				filters = append(filters, AddFilterEQ(fd, v[0]))
 			}
 		}
@@ -348,7 +595,7 @@ func responseGetList[T IEntity](config RestApiConfig[T], r *http.Request, w http
 	if config.DefaultSorts != nil {
 		sorts, err = config.DefaultSorts(r)
 		if err != nil {
-			sendHttpError(w, fmt.Sprintf("%sfailed to get default sorts: %v", methodPrefix, err), http.StatusInternalServerError)
+			sendHttpError(w, fmt.Sprintf("%sfailed to get default sorts: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
 			return
 		}
 	} else {
@@ -365,7 +612,7 @@ func responseGetList[T IEntity](config RestApiConfig[T], r *http.Request, w http
 			parts := strings.Split(token, " ")
 			if len(parts) == 2 {
 				def := config.Def.FieldDefByName(parts[0])
-				if def != nil {
+				if def != nil && factory.canReadField(ctx, def) {
 					asc := true
 					if strings.ToLower(parts[1]) == "desc" {
 						asc = false
@@ -382,7 +629,7 @@ func responseGetList[T IEntity](config RestApiConfig[T], r *http.Request, w http
 	if config.AdditionalFilter != nil {
 		aFilters, err := config.AdditionalFilter(r)
 		if err != nil {
-			sendHttpError(w, fmt.Sprintf("%sfailed to get additional filters: %v", methodPrefix, err), http.StatusInternalServerError)
+			sendHttpError(w, fmt.Sprintf("%sfailed to get additional filters: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
 			return
 		}
 		for _, f := range aFilters {
@@ -405,17 +652,52 @@ func responseGetList[T IEntity](config RestApiConfig[T], r *http.Request, w http
 		}
 	}
 
+	// filter expression is added after merge with additional filters, it shouldn't replace them
+	if expr := r.URL.Query().Get(config.ParamFilter); expr != "" {
+		filterFields := config.FilterFields
+		if filterFields == nil { // fields which aren't readable shouldn't be guessed by filters
+			filterFields = make([]string, 0, len(config.Def.FieldDefs))
+			for _, fd := range config.Def.FieldDefs {
+				if factory.canReadField(ctx, fd) {
+					filterFields = append(filterFields, fd.Name)
+				}
+			}
+		}
+		filter, err := config.Def.ParseFilter(expr, filterFields)
+		if err != nil {
+			sendHttpError(w, fmt.Sprintf("%sinvalid filter: %v", methodPrefix, err), http.StatusBadRequest)
+			return
+		}
+		filters = append(filters, filter)
+	}
+
+	shape, err := requestShape(config, r)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sinvalid fields or expand: %v", methodPrefix, err), http.StatusBadRequest)
+		return
+	}
+
+	if config.CursorPaging || r.URL.Query().Has(config.ParamCursor) {
+		responseGetPage(config, r, w, filters, sorts, pageSize, shape)
+		return
+	}
+
 	records, pagesCount, err := config.SelectEntitiesFunc(filters, sorts, pageNo, pageSize)
 	if err != nil {
-		sendHttpError(w, fmt.Sprintf("%sfailed to fetch list: %v", methodPrefix, err), http.StatusInternalServerError)
+		sendHttpError(w, fmt.Sprintf("%sfailed to fetch list: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	data, err := shapedList(ctx, shape, records)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to expand list: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
 	response := struct {
-		Data       []T
+		Data       any
 		PagesCount int
 	}{
-		Data:       records,
+		Data:       data,
 		PagesCount: pagesCount,
 	}
 	err = json.NewEncoder(w).Encode(response)
@@ -424,3 +706,49 @@ func responseGetList[T IEntity](config RestApiConfig[T], r *http.Request, w http
 		return
 	}
 }
+
+func responseGetPage[T IEntity](config RestApiConfig[T], r *http.Request, w http.ResponseWriter, filters []*Filter, sorts []*SortItem, pageSize int, shape *jsonShape) {
+	const methodPrefix = "RestApiConfig.responseGetPage: "
+	ctx := r.Context()
+	if config.Context != nil {
+		ctx = config.Context(r)
+	}
+	if config.SelectEntitiesPageFunc == nil {
+		if config.CursorPaging {
+			sendHttpError(w, methodPrefix+"CursorPaging requires SelectEntitiesPageFunc in config", http.StatusInternalServerError)
+		} else {
+			sendHttpError(w, methodPrefix+"cursor pagination is not enabled", http.StatusBadRequest)
+		}
+		return
+	}
+	countParam := strings.ToLower(r.URL.Query().Get(config.ParamCount))
+	page, err := config.SelectEntitiesPageFunc(ctx, filters, sorts, r.URL.Query().Get(config.ParamCursor), pageSize, countParam == "true" || countParam == "1")
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to fetch page: %v", methodPrefix, err), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+	data, err := shapedList(ctx, shape, page.Data)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to expand page: %v", methodPrefix, err), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	response := struct {
+		Data       any
+		NextCursor string
+		PrevCursor string
+		Total      *int `json:",omitempty"`
+	}{
+		Data:       data,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	if page.Total >= 0 {
+		response.Total = &page.Total
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		sendHttpError(w, fmt.Sprintf("%sfailed to encode page to JSON: %v", methodPrefix, err), http.StatusInternalServerError)
+		return
+	}
+}
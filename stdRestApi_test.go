@@ -2,11 +2,10 @@ package elorm
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -43,17 +42,10 @@ func TestStdRestApi(t *testing.T) {
 
 	router.HandleFunc("/api/orders", HandleRestApi(shopsRestApiConfig))
 
-	server := &http.Server{
-		Addr:    "localhost:8081",
-		Handler: router,
-	}
-
-	go func() {
-		err := server.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			panic("Failed to start server: " + err.Error())
-		}
-	}()
+	// server listens before it returns, so requests don't race with server start
+	server := httptest.NewServer(router)
+	defer server.Close()
+	api := server.URL + "/api/orders"
 
 	type reqLine struct {
 		method string
@@ -84,16 +76,16 @@ func TestStdRestApi(t *testing.T) {
 	order9.RefString()
 
 	urls := map[string]reqLine{
-		"http://localhost:8081/api/orders":                                         {method: "GET", body: ""},
-		"http://localhost:8081/api/orders?page=2&pagesize=5":                       {method: "GET", body: ""},
-		"http://localhost:8081/api/orders?page=2&sortby=Ref":                       {method: "GET", body: ""},
-		"http://localhost:8081/api/orders?page=2&sortby=Ref%20desc":                {method: "GET", body: ""},
-		"http://localhost:8081/api/orders?page=2&sortby=Ref%20desc&OrderNbr=123":   {method: "GET", body: ""},
-		fmt.Sprintf("http://localhost:8081/api/orders?ref=%s", refs[seedCount-5]):  {method: "GET", body: ""},
-		fmt.Sprintf("http://localhost:8081/api/orders?ref=%s", refs[seedCount-10]): {method: "DELETE", body: ""},
-		fmt.Sprintf("http://localhost:8081/api/orders?ref=%s", refs[seedCount-15]): {method: "DELETE", body: ""},
-		"http://localhost:8081/api/orders?q=1":                                     {method: "POST", body: newBuf.String()},
-		fmt.Sprintf("http://localhost:8081/api/orders?ref=%s", order9.RefString()): {method: "PUT", body: putBuf.String()},
+		api:                               {method: "GET", body: ""},
+		api + "?page=2&pagesize=5":        {method: "GET", body: ""},
+		api + "?page=2&sortby=Ref":        {method: "GET", body: ""},
+		api + "?page=2&sortby=Ref%20desc": {method: "GET", body: ""},
+		api + "?page=2&sortby=Ref%20desc&OrderNbr=123": {method: "GET", body: ""},
+		api + "?ref=" + refs[seedCount-5]:              {method: "GET", body: ""},
+		api + "?ref=" + refs[seedCount-10]:             {method: "DELETE", body: ""},
+		api + "?ref=" + refs[seedCount-15]:             {method: "DELETE", body: ""},
+		api + "?q=1":                                   {method: "POST", body: newBuf.String()},
+		api + "?ref=" + order9.RefString():             {method: "PUT", body: putBuf.String()},
 	}
 
 	var r *http.Response
@@ -140,9 +132,4 @@ func TestStdRestApi(t *testing.T) {
 		}
 	}
 
-	err = server.Shutdown(context.Background())
-	if err != nil {
-		t.Fatalf("Failed to shutdown server: %v", err)
-	}
-
 }
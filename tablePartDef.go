@@ -0,0 +1,333 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Predefined fields of table part rows
+const (
+	TablePartOwnerFieldName  = "Owner"
+	TablePartLineNoFieldName = "LineNo"
+)
+
+const tablePartLoadChunk = 500 // max owners loaded by one query, MSSQL supports up to 2100 parameters
+
+// TablePartDef describes owned ordered collection of rows (e.g. lines of Order). Rows are stored in their own table,
+// loaded with the owner, saved in the owner transaction and deleted with the owner.
+// Row fields are added to RowDef by usual AddXXXFieldDef methods.
+type TablePartDef struct {
+	Name        string
+	Owner       *EntityDef // entity definition the table part belongs to
+	RowDef      *EntityDef // entity definition of rows
+	OwnerField  *FieldDef  // ref to owner entity
+	LineNoField *FieldDef  // row position in the table part, starting from 1
+}
+
+// AddTablePartDef adds table part to this entity definition. Rows are stored in tableName table.
+func (T *EntityDef) AddTablePartDef(name string, tableName string) (*TablePartDef, error) {
+	if err := T.checkName(name); err != nil {
+		return nil, fmt.Errorf("EntityDef.AddTablePartDef: %w", err)
+	}
+	rowDef, err := T.Factory.CreateEntityDef(T.ObjectName+name, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.AddTablePartDef: failed to create row definition: %w", err)
+	}
+	rowDef.DataVersionCheckMode = DataVersionCheckNever // rows are changed with the owner only
+	nr := &TablePartDef{Name: name, Owner: T, RowDef: rowDef}
+	rowDef.TablePartOf = nr
+	nr.OwnerField, err = rowDef.AddRefFieldDef(TablePartOwnerFieldName, T)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.AddTablePartDef: failed to create owner field: %w", err)
+	}
+	nr.LineNoField, err = rowDef.AddIntFieldDef(TablePartLineNoFieldName)
+	if err != nil {
+		return nil, fmt.Errorf("EntityDef.AddTablePartDef: failed to create line number field: %w", err)
+	}
+	if err = rowDef.AddIndex(false, nr.OwnerField, nr.LineNoField); err != nil {
+		return nil, fmt.Errorf("EntityDef.AddTablePartDef: failed to create owner index: %w", err)
+	}
+	T.TablePartDefs = append(T.TablePartDefs, nr)
+	return nr, nil
+}
+
+// TablePartDefByName returns the table part definition with the specified name.
+func (T *EntityDef) TablePartDefByName(name string) *TablePartDef {
+	for _, v := range T.TablePartDefs {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// TablePart is the table part of an entity, ordered list of row entities.
+type TablePart struct {
+	Def     *TablePartDef
+	owner   *Entity
+	rows    []*Entity
+	loaded  bool // false when rows should be loaded from database before use
+	changed bool // rows were added, removed or assigned, used by Entity.LoadFrom
+}
+
+func (T *TablePart) load(ctx context.Context) error {
+	if T.loaded {
+		return nil
+	}
+	return T.Def.load(ctx, []*Entity{T.owner})
+}
+
+// Rows returns rows of the table part in order.
+func (T *TablePart) Rows() ([]*Entity, error) {
+	if err := T.load(context.Background()); err != nil {
+		return nil, fmt.Errorf("TablePart.Rows: %w", err)
+	}
+	return slices.Clone(T.rows), nil
+}
+
+// AddRow appends new row to the table part. It is stored by owner Save.
+func (T *TablePart) AddRow() (*Entity, error) {
+	if err := T.load(context.Background()); err != nil {
+		return nil, fmt.Errorf("TablePart.AddRow: %w", err)
+	}
+	row, err := T.owner.Factory.CreateEntity(T.Def.RowDef)
+	if err != nil {
+		return nil, fmt.Errorf("TablePart.AddRow: failed to create row: %w", err)
+	}
+	T.rows = append(T.rows, row)
+	T.changed = true
+	return row, nil
+}
+
+// RemoveRow removes row by its index. It is deleted from database by owner Save.
+func (T *TablePart) RemoveRow(idx int) error {
+	if err := T.load(context.Background()); err != nil {
+		return fmt.Errorf("TablePart.RemoveRow: %w", err)
+	}
+	if idx < 0 || idx >= len(T.rows) {
+		return fmt.Errorf("TablePart.RemoveRow: index %d is out of range [0, %d)", idx, len(T.rows))
+	}
+	T.rows = slices.Delete(T.rows, idx, idx+1)
+	T.changed = true
+	return nil
+}
+
+// Clear removes all rows. They are deleted from database by owner Save.
+func (T *TablePart) Clear() {
+	T.rows = make([]*Entity, 0)
+	T.loaded = true
+	T.changed = true
+}
+
+// load loads rows of owners and replaces their table parts content
+func (T *TablePartDef) load(ctx context.Context, owners []*Entity) error {
+	byOwner := make(map[string]*TablePart, len(owners))
+	for _, o := range owners {
+		tp := o.tableParts[T.Name]
+		tp.rows = make([]*Entity, 0)
+		tp.loaded = true
+		tp.changed = false
+		if !o.isNew {
+			byOwner[o.RefString()] = tp
+		}
+	}
+	refs := make([]any, 0, len(byOwner))
+	for ref := range byOwner {
+		refs = append(refs, ref)
+	}
+	sorts := []*SortItem{{Field: T.OwnerField, Asc: true}, {Field: T.LineNoField, Asc: true}}
+	for chunk := range slices.Chunk(refs, tablePartLoadChunk) {
+		rows, _, err := T.RowDef.SelectEntitiesContext(ctx, []*Filter{AddFilterIN(T.OwnerField, chunk...)}, sorts, 0, 0)
+		if err != nil {
+			return fmt.Errorf("TablePartDef.load: failed to load rows of %s: %w", T.Name, err)
+		}
+		for _, row := range rows {
+			tp := byOwner[row.Values[TablePartOwnerFieldName].(*FieldValueRef).AsString()]
+			if tp != nil {
+				tp.rows = append(tp.rows, row)
+			}
+		}
+	}
+	return nil
+}
+
+// TablePart returns table part of the entity by name, or nil when it isn't defined.
+func (T *Entity) TablePart(name string) *TablePart {
+	return T.tableParts[name]
+}
+
+// loadTableParts loads table parts of owners by one query per table part (and per chunk of owners)
+func (T *EntityDef) loadTableParts(ctx context.Context, owners []*Entity) error {
+	if len(owners) == 0 {
+		return nil
+	}
+	for _, tpd := range T.TablePartDefs {
+		if err := tpd.load(ctx, owners); err != nil {
+			return fmt.Errorf("EntityDef.loadTableParts: %w", err)
+		}
+	}
+	return nil
+}
+
+// tablePartsLoaded returns false when any table part should be loaded from database
+func (T *Entity) tablePartsLoaded() bool {
+	for _, tp := range T.tableParts {
+		if !tp.loaded {
+			return false
+		}
+	}
+	return true
+}
+
+// saveTableParts stores loaded table parts inside the owner transaction: removed rows are deleted, others are numbered,
+// new and changed rows are saved.
+func (T *Entity) saveTableParts(ctx context.Context) error {
+	for _, tpd := range T.entityDef.TablePartDefs {
+		tp := T.tableParts[tpd.Name]
+		if !tp.loaded {
+			continue
+		}
+		tableName, err := tpd.RowDef.SqlTableName()
+		if err != nil {
+			return fmt.Errorf("Entity.saveTableParts: %w", err)
+		}
+		ownerColumn, err := tpd.OwnerField.SqlColumnName()
+		if err != nil {
+			return fmt.Errorf("Entity.saveTableParts: %w", err)
+		}
+		args := []any{T.RefString()}
+		query := fmt.Sprintf("delete from %s where %s=$1", tableName, ownerColumn)
+		if len(tp.rows) > 0 {
+			keep := make([]any, 0, len(tp.rows))
+			for _, row := range tp.rows {
+				if !row.isNew {
+					keep = append(keep, row.RefString())
+				}
+			}
+			if len(keep) > 0 {
+				clause, err := AddFilterNOTIN(tpd.RowDef.RefField, keep...).renderWhereClause(T.Factory, &args)
+				if err != nil {
+					return fmt.Errorf("Entity.saveTableParts: %w", err)
+				}
+				query += " and " + clause
+			}
+		}
+		if _, err = T.Factory.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("Entity.saveTableParts: failed to delete removed rows of %s: %w", tpd.Name, err)
+		}
+		for i, row := range tp.rows {
+			if err := row.Values[TablePartOwnerFieldName].(*FieldValueRef).Set(T); err != nil {
+				return fmt.Errorf("Entity.saveTableParts: %w", err)
+			}
+			row.Values[TablePartLineNoFieldName].(*FieldValueInt).Set(int64(i + 1))
+			if !row.isNew && !row.isChanged() {
+				continue
+			}
+			if err := row.Save(ctx); err != nil {
+				return fmt.Errorf("Entity.saveTableParts: failed to save row %d of %s: %w", i+1, tpd.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteTableParts deletes rows of all table parts of the owner
+func (T *EntityDef) deleteTableParts(ctx context.Context, ownerRef string) error {
+	for _, tpd := range T.TablePartDefs {
+		tableName, err := tpd.RowDef.SqlTableName()
+		if err != nil {
+			return fmt.Errorf("EntityDef.deleteTableParts: %w", err)
+		}
+		ownerColumn, err := tpd.OwnerField.SqlColumnName()
+		if err != nil {
+			return fmt.Errorf("EntityDef.deleteTableParts: %w", err)
+		}
+		if _, err = T.Factory.ExecContext(ctx, fmt.Sprintf("delete from %s where %s=$1", tableName, ownerColumn), ownerRef); err != nil {
+			return fmt.Errorf("EntityDef.deleteTableParts: failed to delete rows of %s: %w", tpd.Name, err)
+		}
+	}
+	return nil
+}
+
+// tablePartsToMap serializes loaded table parts as arrays of rows, without owner field
+func (T *Entity) tablePartsToMap(ctx context.Context, vm map[string]any) error {
+	for _, tpd := range T.entityDef.TablePartDefs {
+		rows, err := T.tableParts[tpd.Name].Rows()
+		if err != nil {
+			return fmt.Errorf("Entity.tablePartsToMap: %w", err)
+		}
+		list := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			rm, err := row.valuesToMap(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("Entity.tablePartsToMap: failed to convert row of %s: %w", tpd.Name, err)
+			}
+			delete(rm, TablePartOwnerFieldName)
+			list = append(list, rm)
+		}
+		vm[tpd.Name] = list
+	}
+	return nil
+}
+
+// tablePartsFromMap replaces table parts which are present in vm. Rows are matched to existing rows of this owner by Ref,
+// rows with other refs are new ones with fresh Ref, so rows of other owners can't be taken over.
+// Table parts which are absent in vm stay as is, they aren't changed by Save.
+func (T *Entity) tablePartsFromMap(ctx context.Context, vm map[string]any) error {
+	for _, tpd := range T.entityDef.TablePartDefs {
+		tp := T.tableParts[tpd.Name]
+		val, ok := vm[tpd.Name]
+		if !ok {
+			if !T.isNew && len(tp.rows) == 0 {
+				tp.loaded = false
+			}
+			continue
+		}
+		items, ok := val.([]any)
+		if !ok && val != nil {
+			return fmt.Errorf("Entity.tablePartsFromMap: expected array for table part %s, got %T", tpd.Name, val)
+		}
+		if !T.isNew && !tp.changed && len(tp.rows) == 0 { // e.g. entity decoded from PUT request, rows aren't read yet
+			if err := tpd.load(ctx, []*Entity{T}); err != nil {
+				return fmt.Errorf("Entity.tablePartsFromMap: %w", err)
+			}
+		}
+		existing := make(map[string]*Entity, len(tp.rows))
+		for _, row := range tp.rows {
+			existing[row.RefString()] = row
+		}
+		rows := make([]*Entity, 0, len(items))
+		for _, item := range items {
+			im, ok := item.(map[string]any)
+			if !ok {
+				return fmt.Errorf("Entity.tablePartsFromMap: expected object for row of %s, got %T", tpd.Name, item)
+			}
+			delete(im, TablePartOwnerFieldName)
+			delete(im, TablePartLineNoFieldName)
+			ref, _ := im[RefFieldName].(string)
+			row := existing[ref]
+			if row == nil {
+				delete(im, RefFieldName)
+				var err error
+				row, err = T.Factory.CreateEntity(tpd.RowDef)
+				if err != nil {
+					return fmt.Errorf("Entity.tablePartsFromMap: failed to create row of %s: %w", tpd.Name, err)
+				}
+			}
+			b, err := json.Marshal(im)
+			if err != nil {
+				return fmt.Errorf("Entity.tablePartsFromMap: failed to read row of %s: %w", tpd.Name, err)
+			}
+			if err = row.UnmarshalJSONContext(ctx, b); err != nil {
+				return fmt.Errorf("Entity.tablePartsFromMap: failed to read row of %s: %w", tpd.Name, err)
+			}
+			rows = append(rows, row)
+		}
+		tp.rows = rows
+		tp.loaded = true
+		tp.changed = true
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package elorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntity_TableParts(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "tableparts.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	docDef, _ := factory.CreateEntityDef("TpDoc", "TpDocs")
+	_, _ = docDef.AddStringFieldDef("Number", 10)
+	lines, err := docDef.AddTablePartDef("Lines", "TpDocLines")
+	if err != nil {
+		t.Fatalf("AddTablePartDef() error = %v", err)
+	}
+	_, _ = lines.RowDef.AddStringFieldDef("Product", 20)
+	if _, err = docDef.AddTablePartDef("Lines", "TpDocLines2"); err == nil {
+		t.Errorf("AddTablePartDef() with duplicate name should fail")
+	}
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+
+	ctx := context.Background()
+	products := func(e *Entity) string {
+		rows, err := e.TablePart("Lines").Rows()
+		if err != nil {
+			t.Fatalf("Rows() error = %v", err)
+		}
+		res := ""
+		for _, r := range rows {
+			res += fmt.Sprintf("%d:%s;", r.Values["LineNo"].(*FieldValueInt).Get(), r.Values["Product"].(*FieldValueString).Get())
+		}
+		return res
+	}
+	reload := func(e *Entity) *Entity {
+		factory.loadedEntities.Purge()
+		loaded, err := factory.LoadEntity(e.RefString())
+		if err != nil {
+			t.Fatalf("LoadEntity() error = %v", err)
+		}
+		return loaded
+	}
+
+	doc, _ := factory.CreateEntity(docDef)
+	for _, p := range []string{"apple", "pear", "plum"} {
+		row, err := doc.TablePart("Lines").AddRow()
+		if err != nil {
+			t.Fatalf("AddRow() error = %v", err)
+		}
+		row.Values["Product"].(*FieldValueString).Set(p)
+	}
+	if err = doc.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded := reload(doc)
+	if got := products(loaded); got != "1:apple;2:pear;3:plum;" {
+		t.Errorf("loaded rows = %s", got)
+	}
+
+	// rows are replaced and renumbered
+	tp := loaded.TablePart("Lines")
+	if err = tp.RemoveRow(0); err != nil {
+		t.Fatalf("RemoveRow() error = %v", err)
+	}
+	row, _ := tp.AddRow()
+	row.Values["Product"].(*FieldValueString).Set("kiwi")
+	if err = loaded.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	selected, _, err := docDef.SelectEntities(nil, nil, 0, 0)
+	if err != nil || len(selected) != 1 {
+		t.Fatalf("SelectEntities() = %v, %v", selected, err)
+	}
+	factory.loadedEntities.Purge()
+	selected, _, _ = docDef.SelectEntities(nil, nil, 0, 0)
+	if got := products(selected[0]); got != "1:pear;2:plum;3:kiwi;" {
+		t.Errorf("selected rows = %s", got)
+	}
+
+	// failed row save rolls back owner
+	err = factory.AddBeforeSaveHandler(lines.RowDef, func(ctx context.Context, entity any) error {
+		return fmt.Errorf("rejected")
+	})
+	if err != nil {
+		t.Fatalf("AddBeforeSaveHandler() error = %v", err)
+	}
+	loaded = reload(doc)
+	loaded.Values["Number"].(*FieldValueString).Set("X")
+	if rows, _ := loaded.TablePart("Lines").Rows(); len(rows) > 0 {
+		rows[0].Values["Product"].(*FieldValueString).Set("fig") // unchanged rows aren't saved
+	}
+	if err = loaded.Save(ctx); err == nil {
+		t.Fatalf("Save() with failing row should fail")
+	}
+	lines.RowDef.beforeSaveHandlers = nil
+	if got := reload(doc).Values["Number"].(*FieldValueString).Get(); got != "" {
+		t.Errorf("owner change is not rolled back, Number = %s", got)
+	}
+
+	// JSON
+	loaded = reload(doc)
+	data, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var parsed map[string]any
+	_ = json.Unmarshal(data, &parsed)
+	if arr, ok := parsed["Lines"].([]any); !ok || len(arr) != 3 {
+		t.Fatalf("MarshalJSON() Lines = %v", parsed["Lines"])
+	}
+	parsed["Lines"] = parsed["Lines"].([]any)[1:]
+	data, _ = json.Marshal(parsed)
+	req, _ := factory.CreateEntity(docDef)
+	if err = json.Unmarshal(data, req); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if err = loaded.LoadFrom(req, false); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+	if err = loaded.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := products(reload(doc)); got != "1:plum;2:kiwi;" {
+		t.Errorf("rows after JSON update = %s", got)
+	}
+
+	// JSON without table part keeps rows
+	req, _ = factory.CreateEntity(docDef)
+	if err = json.Unmarshal([]byte(fmt.Sprintf(`{"Ref":"%s","Number":"N1"}`, doc.RefString())), req); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	loaded = reload(doc)
+	_ = loaded.LoadFrom(req, false)
+	if err = loaded.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := products(reload(doc)); got != "1:plum;2:kiwi;" {
+		t.Errorf("rows after JSON update without table part = %s", got)
+	}
+
+	// deleted with owner
+	if err = factory.DeleteEntity(ctx, doc.RefString()); err != nil {
+		t.Fatalf("DeleteEntity() error = %v", err)
+	}
+	rowsLeft, _, err := lines.RowDef.SelectEntities(nil, nil, 0, 0)
+	if err != nil || len(rowsLeft) != 0 {
+		t.Errorf("rows after owner delete = %d, %v", len(rowsLeft), err)
+	}
+}
+
+func TestEntity_TableParts_OtherOwnerRows(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "tpowner.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	docDef, _ := factory.CreateEntityDef("ToDoc", "ToDocs")
+	lines, _ := docDef.AddTablePartDef("Lines", "ToDocLines")
+	_, _ = lines.RowDef.AddStringFieldDef("Product", 20)
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	ctx := context.Background()
+	newDoc := func(product string) (*Entity, *Entity) {
+		doc, _ := factory.CreateEntity(docDef)
+		row, _ := doc.TablePart("Lines").AddRow()
+		row.Values["Product"].(*FieldValueString).Set(product)
+		if err := doc.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return doc, row
+	}
+	docA, rowA := newDoc("apple")
+	docB, rowB := newDoc("pear")
+	ownRows := func(doc *Entity) []*Entity {
+		factory.loadedEntities.Purge()
+		rows, _, err := lines.RowDef.SelectEntities([]*Filter{AddFilterEQ(lines.OwnerField, doc.RefString())}, nil, 0, 0)
+		if err != nil {
+			t.Fatalf("SelectEntities() error = %v", err)
+		}
+		return rows
+	}
+	check := func(step string) {
+		if rows := ownRows(docB); len(rows) != 1 || rows[0].RefString() != rowB.RefString() || rows[0].Values["Product"].AsString() != "pear" {
+			t.Errorf("%s took over row of other owner", step)
+		}
+		rows := ownRows(docA)
+		if len(rows) != 2 || rows[0].RefString() != rowA.RefString() || rows[1].RefString() == rowB.RefString() {
+			t.Errorf("%s: rows of owner = %v", step, rows)
+		}
+	}
+	body := fmt.Sprintf(`{"Ref": "%s", "Lines": [{"Ref": "%s", "Product": "apple"}, {"Ref": "%s", "Product": "stolen"}]}`,
+		docA.RefString(), rowA.RefString(), rowB.RefString())
+
+	// like PUT
+	req, _ := factory.CreateEntity(docDef)
+	if err = json.Unmarshal([]byte(body), req); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	loaded, _ := factory.LoadEntity(docA.RefString())
+	if err = loaded.LoadFrom(req, false); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+	if err = loaded.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	check("PUT")
+
+	// like PATCH
+	loaded, _ = factory.LoadEntity(docA.RefString())
+	if err = loaded.ApplyMergePatch([]byte(body)); err != nil {
+		t.Fatalf("ApplyMergePatch() error = %v", err)
+	}
+	if err = loaded.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	check("PATCH")
+}
+
+func TestEntity_TableParts_FailedSave(t *testing.T) {
+	factory, err := CreateFactory("sqlite", "file:"+filepath.Join(t.TempDir(), "tpfailed.db"))
+	if err != nil {
+		t.Fatalf("CreateFactory() error = %v", err)
+	}
+	docDef, _ := factory.CreateEntityDef("TfDoc", "TfDocs")
+	_, _ = docDef.AddStringFieldDef("Number", 10)
+	lines, _ := docDef.AddTablePartDef("Lines", "TfDocLines")
+	_, _ = lines.RowDef.AddStringFieldDef("Product", 20)
+	if err = factory.EnsureDBStructure(); err != nil {
+		t.Fatalf("EnsureDBStructure() error = %v", err)
+	}
+	failRows := false
+	rowSaves := 0
+	_ = factory.AddBeforeSaveHandler(lines.RowDef, func(ctx context.Context, entity any) error {
+		rowSaves++
+		if failRows {
+			return fmt.Errorf("row is locked")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	newDoc, _ := factory.CreateEntity(docDef)
+	row, _ := newDoc.TablePart("Lines").AddRow()
+	row.Values["Product"].(*FieldValueString).Set("apple")
+	doc, _ := factory.CreateEntity(docDef)
+	if err = doc.Save(ctx); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	row, _ = doc.TablePart("Lines").AddRow()
+	row.Values["Product"].(*FieldValueString).Set("pear")
+	doc.Values["Number"].(*FieldValueString).Set("2")
+
+	// table part fails after insert or update of owner
+	failRows = true
+	for _, e := range []*Entity{newDoc, doc} {
+		if err = e.Save(ctx); err == nil {
+			t.Fatalf("Save() with failing table part should fail")
+		}
+	}
+	if !newDoc.IsNew() {
+		t.Errorf("failed Save() of new entity should keep it new")
+	}
+	failRows = false
+	for _, e := range []*Entity{newDoc, doc} {
+		if err = e.Save(ctx); err != nil {
+			t.Errorf("Save() after failed Save() error = %v", err)
+		}
+	}
+
+	// only new and changed rows are saved
+	for _, p := range []string{"plum", "kiwi"} {
+		row, _ = doc.TablePart("Lines").AddRow()
+		row.Values["Product"].(*FieldValueString).Set(p)
+	}
+	rowSaves = 0
+	if err = doc.Save(ctx); err != nil || rowSaves != 2 {
+		t.Errorf("Save() with new rows: rows saved = %d, error = %v", rowSaves, err)
+	}
+	doc.Values["Number"].(*FieldValueString).Set("3")
+	rowSaves = 0
+	if err = doc.Save(ctx); err != nil || rowSaves != 0 {
+		t.Errorf("Save() without row changes: rows saved = %d, error = %v", rowSaves, err)
+	}
+	rows, _ := doc.TablePart("Lines").Rows()
+	rows[0].Values["Product"].(*FieldValueString).Set("fig")
+	if err = doc.TablePart("Lines").RemoveRow(1); err != nil {
+		t.Fatalf("RemoveRow() error = %v", err)
+	}
+	rowSaves = 0
+	if err = doc.Save(ctx); err != nil || rowSaves != 2 { // changed row and renumbered one
+		t.Errorf("Save() with changed rows: rows saved = %d, error = %v", rowSaves, err)
+	}
+	factory.loadedEntities.Purge()
+	loaded, _ := factory.LoadEntity(doc.RefString())
+	if rows, _ = loaded.TablePart("Lines").Rows(); len(rows) != 2 || rows[0].Values["Product"].AsString() != "fig" || rows[1].Values["Product"].AsString() != "kiwi" || rows[1].Values["LineNo"].(*FieldValueInt).Get() != 2 {
+		t.Errorf("rows after Save() = %v", rows)
+	}
+}
@@ -0,0 +1,287 @@
+package elorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Tx is a database transaction handle. BeginTranContext binds it to the returned context, so Save, DeleteEntity, LoadEntity,
+// SelectEntities, Query, Exec and event handlers called with that context run inside the transaction.
+// Nested BeginTranContext calls (with context already carrying transaction of the same factory) create savepoints
+// in the outer transaction, so nested rollback undoes only the work done after nested BeginTranContext.
+type Tx struct {
+	factory    *Factory
+	tx         *sql.Tx
+	parent     *Tx      // outer transaction for nested ones, nil for top-level
+	savepoint  string   // savepoint name for nested transactions
+	savepoints int      // savepoints counter, used by top-level transaction to generate unique names
+	done       bool     // committed or rolled back
+	onRollback []func() // run when work of this transaction is rolled back, passed to parent on nested commit
+}
+
+// TxHandle is a transaction accepted by CommitTran and RollbackTran: *Tx returned by BeginTranContext
+// or *sql.Tx returned by deprecated BeginTran.
+type TxHandle interface {
+	Commit() error
+	Rollback() error
+}
+
+type txContextKey struct{}
+
+func (T *Tx) root() *Tx {
+	r := T
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// Query executes a query inside the transaction. It accepts parameters in Postgres style ($1, $2, ...) like Factory.Query.
+func (T *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return T.tx.Query(T.factory.PrepareSql(query, args...), args...)
+}
+
+// Exec executes a query without returning any rows inside the transaction.
+func (T *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return T.tx.Exec(T.factory.PrepareSql(query, args...), args...)
+}
+
+func (T *Tx) execSavepointSql(query string) error {
+	if query == "" {
+		return nil
+	}
+	_, err := T.tx.Exec(query)
+	return err
+}
+
+// afterRollback registers fn to run when work done in this transaction is rolled back, by this or any outer transaction.
+// Save uses it to evict entities with uncommitted values from factory cache.
+func (T *Tx) afterRollback(fn func()) {
+	T.onRollback = append(T.onRollback, fn)
+}
+
+func (T *Tx) rolledBack() {
+	for i := len(T.onRollback) - 1; i >= 0; i-- {
+		T.onRollback[i]()
+	}
+	T.onRollback = nil
+}
+
+// finish ends top-level transaction and releases SQLite writer lock
+func (T *Tx) finish(commit bool) error {
+	T.done = true
+	defer T.factory.unlockWriter()
+	if commit {
+		err := T.tx.Commit()
+		if err != nil {
+			T.rolledBack()
+		}
+		return err
+	}
+	T.rolledBack()
+	return T.tx.Rollback()
+}
+
+// lockWriter waits for the writer lock of single writer dialects until ctx is done. The lock isn't reentrant,
+// so top-level transaction started without the context of active one in the same goroutine waits until ctx is done.
+func (f *Factory) lockWriter(ctx context.Context) error {
+	if !f.dialect.SingleWriter() {
+		return nil
+	}
+	select {
+	case f.writerLock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Factory.lockWriter: waiting for active transaction to finish: %w", ctx.Err())
+	}
+}
+
+func (f *Factory) unlockWriter() {
+	if f.dialect.SingleWriter() {
+		<-f.writerLock
+	}
+}
+
+// txFromContext returns transaction of this factory carried by context, if any.
+func (f *Factory) txFromContext(ctx context.Context) *Tx {
+	if ctx == nil {
+		return nil
+	}
+	tx, ok := ctx.Value(txContextKey{}).(*Tx)
+	if !ok || tx.factory != f {
+		return nil
+	}
+	return tx
+}
+
+// BeginTranContext begins a database transaction and returns context bound to it. Pass that context to all operations
+// which should be part of the transaction. If ctx already carries a transaction of this factory, the new one is nested
+// into it using savepoint: nested commit releases savepoint and nested rollback rolls back to it, keeping outer work.
+// For single writer dialects (SQLite) top-level transactions are serialized between goroutines.
+func (f *Factory) BeginTranContext(ctx context.Context) (context.Context, *Tx, error) {
+	return f.beginTran(ctx, nil)
+}
+
+// BeginTran begins a top-level database transaction. Finish it with CommitTran or RollbackTran.
+//
+// Deprecated: transaction isn't carried by context, so Save, DeleteEntity and other factory operations run outside of it.
+// Use BeginTranContext or WithTx instead.
+func (f *Factory) BeginTran() (*sql.Tx, error) {
+	_, tx, err := f.beginTran(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	f.legacyTxs.Store(tx.tx, tx)
+	return tx.tx, nil
+}
+
+// txHandle returns *Tx for *sql.Tx started by deprecated BeginTran, so its SQLite writer lock is released on finish
+func (f *Factory) txHandle(tx TxHandle) TxHandle {
+	if sqlTx, ok := tx.(*sql.Tx); ok && sqlTx != nil {
+		if res, ok := f.legacyTxs.LoadAndDelete(sqlTx); ok {
+			return res.(*Tx)
+		}
+	}
+	return tx
+}
+
+// beginTran begins transaction, top-level one is started on conn when it isn't nil
+func (f *Factory) beginTran(ctx context.Context, conn *sql.Conn) (context.Context, *Tx, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if outer := f.txFromContext(ctx); outer != nil {
+		if outer.done || outer.root().done {
+			return nil, nil, fmt.Errorf("Factory.BeginTranContext: outer transaction is already finished")
+		}
+		root := outer.root()
+		root.savepoints++
+		tx := &Tx{factory: f, tx: outer.tx, parent: outer, savepoint: fmt.Sprintf("elorm_sp%d", root.savepoints)}
+		if err := tx.execSavepointSql(f.dialect.SavepointSql(tx.savepoint)); err != nil {
+			return nil, nil, fmt.Errorf("Factory.BeginTranContext: failed to create savepoint: %w", err)
+		}
+		return context.WithValue(ctx, txContextKey{}, tx), tx, nil
+	}
+
+	if err := f.lockWriter(ctx); err != nil {
+		return nil, nil, fmt.Errorf("Factory.BeginTranContext: %w", err)
+	}
+	var sqlTx *sql.Tx
+	var err error
+	if conn != nil {
+		sqlTx, err = conn.BeginTx(ctx, nil)
+	} else {
+		sqlTx, err = f.db.BeginTx(ctx, nil)
+	}
+	if err != nil {
+		f.unlockWriter()
+		return nil, nil, fmt.Errorf("Factory.BeginTranContext: failed to begin transaction: %w", err)
+	}
+	tx := &Tx{factory: f, tx: sqlTx}
+	return context.WithValue(ctx, txContextKey{}, tx), tx, nil
+}
+
+// Commit commits top-level transaction. For nested transaction it releases its savepoint.
+func (T *Tx) Commit() error {
+	if T == nil {
+		return fmt.Errorf("Tx.Commit: tx is nil")
+	}
+	if T.done {
+		return fmt.Errorf("Tx.Commit: transaction is already finished")
+	}
+	if T.parent != nil {
+		if T.root().done {
+			return fmt.Errorf("Tx.Commit: outer transaction is already finished")
+		}
+		T.done = true
+		if err := T.execSavepointSql(T.factory.dialect.ReleaseSavepointSql(T.savepoint)); err != nil {
+			T.rolledBack()
+			return fmt.Errorf("Tx.Commit: failed to release savepoint: %w", err)
+		}
+		T.parent.onRollback = append(T.parent.onRollback, T.onRollback...)
+		T.onRollback = nil
+		return nil
+	}
+	err := T.finish(true)
+	if err != nil {
+		return fmt.Errorf("Tx.Commit: failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Rollback rolls back the transaction. For nested transaction it rolls back to its savepoint, outer transaction stays active.
+// It returns an error when transaction is already finished, so it is safe to defer it right after BeginTranContext.
+func (T *Tx) Rollback() error {
+	if T == nil {
+		return fmt.Errorf("Tx.Rollback: tx is nil")
+	}
+	if T.done {
+		return fmt.Errorf("Tx.Rollback: transaction is already finished")
+	}
+	if T.parent != nil {
+		T.done = true
+		T.rolledBack()
+		if T.root().done {
+			return nil
+		}
+		if err := T.execSavepointSql(T.factory.dialect.RollbackToSavepointSql(T.savepoint)); err != nil {
+			return fmt.Errorf("Tx.Rollback: failed to rollback to savepoint: %w", err)
+		}
+		if err := T.execSavepointSql(T.factory.dialect.ReleaseSavepointSql(T.savepoint)); err != nil {
+			return fmt.Errorf("Tx.Rollback: failed to release savepoint: %w", err)
+		}
+		return nil
+	}
+	err := T.finish(false)
+	if err != nil {
+		return fmt.Errorf("Tx.Rollback: failed to rollback transaction: %w", err)
+	}
+	return nil
+}
+
+// CommitTran commits transaction started by BeginTranContext or BeginTran, see Tx.Commit.
+func (f *Factory) CommitTran(tx TxHandle) error {
+	if tx == nil {
+		return fmt.Errorf("Factory.CommitTran: tx is nil")
+	}
+	if err := f.txHandle(tx).Commit(); err != nil {
+		return fmt.Errorf("Factory.CommitTran: %w", err)
+	}
+	return nil
+}
+
+// RollbackTran rolls back transaction started by BeginTranContext or BeginTran, see Tx.Rollback.
+// It returns an error when transaction is already finished, so it is safe to defer it right after BeginTranContext.
+func (f *Factory) RollbackTran(tx TxHandle) error {
+	if tx == nil {
+		return fmt.Errorf("Factory.RollbackTran: tx is nil")
+	}
+	if err := f.txHandle(tx).Rollback(); err != nil {
+		return fmt.Errorf("Factory.RollbackTran: %w", err)
+	}
+	return nil
+}
+
+// WithTx runs fn inside transaction. Transaction is committed when fn returns nil and rolled back otherwise (including panics).
+// Context passed to fn carries the transaction.
+func (f *Factory) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return f.withTx(ctx, nil, fn)
+}
+
+func (f *Factory) withTx(ctx context.Context, conn *sql.Conn, fn func(ctx context.Context) error) error {
+	txCtx, tx, err := f.beginTran(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("Factory.WithTx: %w", err)
+	}
+	defer func() {
+		if !tx.done {
+			_ = f.RollbackTran(tx)
+		}
+	}()
+	if err = fn(txCtx); err != nil {
+		_ = f.RollbackTran(tx)
+		return err
+	}
+	return f.CommitTran(tx)
+}
@@ -0,0 +1,286 @@
+package elorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFactory_WithTx(t *testing.T) {
+	factory := mockFactory()
+	ordersDef := mockEntityDef_Orders(factory)
+	Nbr := ordersDef.FieldDefByName("OrderNbr")
+
+	mock_ClearEntities(t)
+
+	countByNbr := func(ctx context.Context, nbr string) int {
+		res, _, err := ordersDef.SelectEntitiesContext(ctx, []*Filter{AddFilterEQ(Nbr, nbr)}, nil, 0, 0)
+		if err != nil {
+			t.Fatalf("SelectEntitiesContext() error = %v", err)
+		}
+		return len(res)
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		err := factory.WithTx(context.Background(), func(ctx context.Context) error {
+			ent := mockEntity_Order()
+			ent.Values["OrderNbr"].(*FieldValueString).Set("tx_commit")
+			if err := ent.Save(ctx); err != nil {
+				return err
+			}
+			if countByNbr(ctx, "tx_commit") != 1 {
+				t.Errorf("saved entity is not visible inside transaction")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithTx() error = %v", err)
+		}
+		if countByNbr(context.Background(), "tx_commit") != 1 {
+			t.Errorf("entity was not committed")
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		var ref string
+		err := factory.WithTx(context.Background(), func(ctx context.Context) error {
+			ent := mockEntity_Order()
+			ent.Values["OrderNbr"].(*FieldValueString).Set("tx_rollback")
+			if err := ent.Save(ctx); err != nil {
+				return err
+			}
+			ref = ent.RefString()
+			return fmt.Errorf("fail")
+		})
+		if err == nil {
+			t.Fatalf("WithTx() error expected")
+		}
+		if countByNbr(context.Background(), "tx_rollback") != 0 {
+			t.Errorf("entity was not rolled back")
+		}
+		if _, err := factory.LoadEntity(ref); err == nil {
+			t.Errorf("LoadEntity() returned rolled back entity")
+		}
+	})
+
+	t.Run("nested rollback", func(t *testing.T) {
+		ctx, tx, err := factory.BeginTranContext(context.Background())
+		if err != nil {
+			t.Fatalf("BeginTranContext() error = %v", err)
+		}
+		defer func() { _ = factory.RollbackTran(tx) }()
+
+		ent := mockEntity_Order()
+		ent.Values["OrderNbr"].(*FieldValueString).Set("tx_outer")
+		if err = ent.Save(ctx); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		nestedCtx, nested, err := factory.BeginTranContext(ctx)
+		if err != nil {
+			t.Fatalf("nested BeginTranContext() error = %v", err)
+		}
+		ent2 := mockEntity_Order()
+		ent2.Values["OrderNbr"].(*FieldValueString).Set("tx_nested")
+		if err = ent2.Save(nestedCtx); err != nil {
+			t.Fatalf("nested Save() error = %v", err)
+		}
+		if err = factory.RollbackTran(nested); err != nil {
+			t.Fatalf("nested RollbackTran() error = %v", err)
+		}
+		if countByNbr(ctx, "tx_nested") != 0 {
+			t.Errorf("nested work is visible after nested rollback")
+		}
+
+		_, nested2, err := factory.BeginTranContext(ctx)
+		if err != nil {
+			t.Fatalf("second nested BeginTranContext() error = %v", err)
+		}
+		if err = factory.CommitTran(nested2); err != nil {
+			t.Fatalf("nested CommitTran() error = %v", err)
+		}
+
+		if err = factory.CommitTran(tx); err != nil {
+			t.Fatalf("CommitTran() error = %v", err)
+		}
+		if countByNbr(context.Background(), "tx_outer") != 1 {
+			t.Errorf("outer work was lost after nested rollback")
+		}
+		if countByNbr(context.Background(), "tx_nested") != 0 {
+			t.Errorf("nested work was committed after nested rollback")
+		}
+	})
+
+	t.Run("failed nested delete", func(t *testing.T) {
+		linesDef := mockEntityDef_OrderLines(factory)
+		type failKey struct{}
+		err := factory.AddBeforeDeleteHandler(linesDef, func(ctx context.Context, ent any) error {
+			if ctx.Value(failKey{}) != nil {
+				return fmt.Errorf("line can't be deleted")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("AddBeforeDeleteHandler() error = %v", err)
+		}
+
+		line := mockEntity_OrderLine()
+		if err = line.Save(context.Background()); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		err = factory.WithTx(context.WithValue(context.Background(), failKey{}, true), func(ctx context.Context) error {
+			ent := mockEntity_Order()
+			ent.Values["OrderNbr"].(*FieldValueString).Set("tx_delete_outer")
+			if err := ent.Save(ctx); err != nil {
+				return err
+			}
+			if err := factory.DeleteEntity(ctx, line.RefString()); err == nil {
+				t.Errorf("DeleteEntity() error expected")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithTx() error = %v", err)
+		}
+		if countByNbr(context.Background(), "tx_delete_outer") != 1 {
+			t.Errorf("outer work was lost after failed nested delete")
+		}
+		if _, err = factory.LoadEntity(line.RefString()); err != nil {
+			t.Errorf("line was deleted: %v", err)
+		}
+		if err = factory.DeleteEntity(context.Background(), line.RefString()); err != nil {
+			t.Errorf("DeleteEntity() error = %v", err)
+		}
+	})
+
+	t.Run("nested save with outer rollback", func(t *testing.T) {
+		saved := mockEntity_Order()
+		saved.Values["OrderNbr"].(*FieldValueString).Set("tx_saved")
+		if err := saved.Save(context.Background()); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		created := mockEntity_Order()
+		created.Values["OrderNbr"].(*FieldValueString).Set("tx_created")
+
+		ctx, tx, err := factory.BeginTranContext(context.Background())
+		if err != nil {
+			t.Fatalf("BeginTranContext() error = %v", err)
+		}
+		nestedCtx, nested, err := factory.BeginTranContext(ctx)
+		if err != nil {
+			t.Fatalf("nested BeginTranContext() error = %v", err)
+		}
+		saved.Values["OrderNbr"].(*FieldValueString).Set("tx_changed")
+		for _, e := range []*Entity{saved, created} {
+			if err = e.Save(nestedCtx); err != nil {
+				t.Fatalf("nested Save() error = %v", err)
+			}
+		}
+		if err = factory.CommitTran(nested); err != nil {
+			t.Fatalf("nested CommitTran() error = %v", err)
+		}
+		if err = factory.RollbackTran(tx); err != nil {
+			t.Fatalf("RollbackTran() error = %v", err)
+		}
+
+		if loaded, err := factory.LoadEntity(saved.RefString()); err != nil || loaded.Values["OrderNbr"].AsString() != "tx_saved" {
+			t.Errorf("LoadEntity() after rollback = %v, %v, want committed values", loaded, err)
+		}
+		if _, err = factory.LoadEntity(created.RefString()); err == nil {
+			t.Errorf("LoadEntity() returned rolled back entity")
+		}
+		if !created.IsNew() {
+			t.Errorf("rolled back entity should stay new")
+		}
+		saved.Values["OrderNbr"].(*FieldValueString).Set("tx_changed")
+		for _, e := range []*Entity{saved, created} {
+			if err = e.Save(context.Background()); err != nil {
+				t.Errorf("Save() after rollback error = %v", err)
+			}
+		}
+	})
+
+	t.Run("writer lock is not reentrant", func(t *testing.T) {
+		err := factory.WithTx(context.Background(), func(ctx context.Context) error {
+			ent := mockEntity_Order()
+			waitCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			return ent.Save(waitCtx) // context without transaction starts new top-level one
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Save() with other context inside transaction error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("deprecated BeginTran", func(t *testing.T) {
+		table, _ := ordersDef.SqlTableName()
+		rename := "update " + table + " set OrderNbr='tx_legacy' where OrderNbr='tx_commit'"
+		tx, err := factory.BeginTran()
+		if err != nil {
+			t.Fatalf("BeginTran() error = %v", err)
+		}
+		if _, err = tx.Exec(rename); err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+		if err = factory.RollbackTran(tx); err != nil {
+			t.Fatalf("RollbackTran() error = %v", err)
+		}
+		if countByNbr(context.Background(), "tx_commit") != 1 {
+			t.Errorf("work was not rolled back")
+		}
+
+		if tx, err = factory.BeginTran(); err != nil {
+			t.Fatalf("BeginTran() error = %v", err)
+		}
+		if _, err = tx.Exec(rename); err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+		if err = factory.CommitTran(tx); err != nil {
+			t.Fatalf("CommitTran() error = %v", err)
+		}
+		if countByNbr(context.Background(), "tx_legacy") != 1 {
+			t.Errorf("work was not committed")
+		}
+		if err = factory.RollbackTran(tx); err == nil {
+			t.Errorf("RollbackTran() of committed transaction should fail")
+		}
+	})
+
+	t.Run("goroutines", func(t *testing.T) {
+		const workers = 8
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for i := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- factory.WithTx(context.Background(), func(ctx context.Context) error {
+					ent, err := factory.CreateEntity(ordersDef)
+					if err != nil {
+						return err
+					}
+					ent.Values["OrderNbr"].(*FieldValueString).Set(fmt.Sprintf("tx_worker_%d", i))
+					return ent.Save(ctx)
+				})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("WithTx() in goroutine error = %v", err)
+			}
+		}
+		res, _, err := ordersDef.SelectEntities([]*Filter{AddFilterLIKE(Nbr, "tx_worker_%")}, nil, 0, 0)
+		if err != nil {
+			t.Fatalf("SelectEntities() error = %v", err)
+		}
+		if len(res) != workers {
+			t.Errorf("SelectEntities() returned %d rows, want %d", len(res), workers)
+		}
+	})
+}